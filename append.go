@@ -0,0 +1,171 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"txt2html/pkg/txt2html"
+)
+
+const appendStateFileName = ".txt2html-state.json" // -append 模式记录转换进度的文件名
+
+// -append 模式下保存在输出目录中的转换进度，下次运行时只处理之后新增的内容
+type appendState struct {
+	Offset   int64                     `json:"offset"`   // 已处理的输入字节数
+	Encoding string                    `json:"encoding"` // 实际使用的输入编码，新增内容沿用
+	Lines    int                       `json:"lines"`    // 已处理的行数
+	Chunks   []appendChunk             `json:"chunks"`
+	Headings []txt2html.ChapterHeading `json:"headings"`
+}
+
+// 已生成的一块，重新生成目录页和清单时使用
+type appendChunk struct {
+	File      string `json:"file"`
+	CharCount int    `json:"charCount"`
+	WordCount int    `json:"wordCount"`
+	Chapter   string `json:"chapter,omitempty"`
+	Size      int64  `json:"size,omitempty"`     // 分块文件的字节数
+	GzipSize  int64  `json:"gzipSize,omitempty"` // 预压缩副本的字节数，没有副本时省略
+}
+
+// 输入编码中的换行符：UTF-16 的换行占两个字节，开头的 BOM 优先于指定的编码（与转换时的处理相同）
+func appendNewline(f io.ReaderAt, encodingName string) []byte {
+	bom := make([]byte, 2)
+	n, _ := f.ReadAt(bom, 0)
+	switch {
+	case bytes.Equal(bom[:n], []byte{0xFF, 0xFE}):
+		encodingName = "utf-16le"
+	case bytes.Equal(bom[:n], []byte{0xFE, 0xFF}):
+		encodingName = "utf-16be"
+	}
+	switch encodingName {
+	case "utf-16", "utf-16le":
+		return []byte{'\n', 0}
+	case "utf-16be":
+		return []byte{0, '\n'}
+	}
+	return []byte{'\n'}
+}
+
+// 输入中 [start, end) 范围内最后一个完整行的结束位置（换行符之后），没有完整的行时返回 start。
+// 从后向前分段查找换行符 newline；两个字节的换行符只在偶数位置上才算，避免把其他字符的一部分当作换行
+func completeLinesEnd(f io.ReaderAt, start, end int64, newline []byte) (int64, error) {
+	const blockSize = 64 * 1024
+	buf := make([]byte, blockSize+len(newline)-1)
+	for hi := end; hi > start; {
+		lo := max(start, hi-blockSize)
+		// 多读 len(newline)-1 个字节，跨越两段的换行符也能找到
+		n, err := f.ReadAt(buf[:min(int64(len(buf)), end-lo)], lo)
+		if err != nil && err != io.EOF {
+			return 0, err
+		}
+		block := buf[:n]
+		for {
+			i := bytes.LastIndex(block, newline)
+			if i < 0 {
+				break
+			}
+			if pos := lo + int64(i); len(newline) == 1 || pos%2 == 0 {
+				return pos + int64(len(newline)), nil
+			}
+			block = block[:i+len(newline)-1]
+		}
+		hi = lo
+	}
+	return start, nil
+}
+
+// 读取输出目录中的转换进度，之前没有以 -append 转换过时返回 nil
+func loadAppendState(outputDir string) (*appendState, error) {
+	path := filepath.Join(outputDir, appendStateFileName)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var state appendState
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("无法解析 %s: %w", path, err)
+	}
+	return &state, nil
+}
+
+func (s *appendState) save(outputDir string) error {
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, appendStateFileName), content, 0644)
+}
+
+// 已有各块的文件名
+func (s *appendState) files() []string {
+	files := make([]string, len(s.Chunks))
+	for i, c := range s.Chunks {
+		files[i] = c.File
+	}
+	return files
+}
+
+// 把本次新生成的块和标题记入进度，offset 为已处理到的输入位置，sizes 为新块写出的字节数
+func (s *appendState) add(book *txt2html.Book, offset int64, sizes chunkSizes) {
+	s.Offset = offset
+	s.Encoding = book.Encoding
+	s.Lines = book.Lines
+	for i, data := range book.Chunks {
+		s.Chunks = append(s.Chunks, appendChunk{
+			File:      data.OutputFile,
+			CharCount: data.CharCount,
+			WordCount: data.WordCount,
+			Chapter:   book.Stats[i].Chapter,
+			Size:      sizes.html[i],
+		})
+		if sizes.gzip != nil {
+			s.Chunks[len(s.Chunks)-1].GzipSize = sizes.gzip[i]
+		}
+	}
+	s.Headings = append(s.Headings, book.Headings...)
+}
+
+// 全书所有块的页面数据、统计信息和文件大小，已有的块排在新块前面。已有块的文件保持不变，
+// 它们的数据只用于生成目录页和清单，只含进度中记录的字段，全书的设置取自新块。sizes 为新块写出的字节数
+func (s *appendState) allChunks(book *txt2html.Book, outputDir string, sizes chunkSizes) ([]txt2html.TemplateData, []txt2html.ChunkStats, chunkSizes) {
+	existing := len(s.Chunks) - len(book.Chunks)
+	chunkData := make([]txt2html.TemplateData, 0, len(s.Chunks))
+	stats := make([]txt2html.ChunkStats, 0, len(s.Chunks))
+	all := chunkSizes{html: make([]int64, 0, len(s.Chunks))}
+	if sizes.gzip != nil {
+		all.gzip = make([]int64, 0, len(s.Chunks))
+	}
+	for i, c := range s.Chunks[:existing] {
+		chunkData = append(chunkData, txt2html.TemplateData{
+			CurrentChunk: i + 1,
+			OutputFile:   c.File,
+			CharCount:    c.CharCount,
+			WordCount:    c.WordCount,
+			Chapter:      c.Chapter,
+		})
+		stats = append(stats, txt2html.ChunkStats{CharCount: c.CharCount, WordCount: c.WordCount, Chapter: c.Chapter})
+		// 旧版本的进度中没有记录大小，只能读取文件
+		size, gzipSize := c.Size, c.GzipSize
+		if size == 0 {
+			size = getFileSize(filepath.Join(outputDir, c.File))
+		}
+		all.html = append(all.html, size)
+		if all.gzip != nil {
+			if gzipSize == 0 {
+				gzipSize = getFileSize(filepath.Join(outputDir, c.File+gzipSuffix))
+			}
+			all.gzip = append(all.gzip, gzipSize)
+		}
+	}
+	all.html = append(all.html, sizes.html...)
+	all.gzip = append(all.gzip, sizes.gzip...)
+	return append(chunkData, book.Chunks...), append(stats, book.Stats...), all
+}
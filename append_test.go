@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// -append 不修改已有的分块文件：原来的最后一块仍没有“下一页”，显示的仍是上次的总块数；
+// 新块能链接回已有的块，目录页覆盖全部的块
+func TestConvertAppendKeepsExistingChunks(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(input, []byte("一\n二\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-quiet", "-append", "-lines", "2", "-out", out, input}
+	if err := run(args); err != nil {
+		t.Fatalf("第一次转换: %v", err)
+	}
+	first := filepath.Join(out, "book_chunk_1.html")
+	before, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	file, err := os.OpenFile(input, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("三\n四\n")
+	file.Close()
+	if err := run(args); err != nil {
+		t.Fatalf("追加转换: %v", err)
+	}
+
+	after, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(after) != string(before) {
+		t.Errorf("追加后已有的 %s 被修改", first)
+	}
+	if strings.Contains(string(after), `rel="next"`) || !strings.Contains(string(after), `max="1"`) {
+		t.Errorf("已有的最后一块应保持没有下一页、总块数为 1")
+	}
+	second, err := os.ReadFile(filepath.Join(out, "book_chunk_2.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(second), `href="book_chunk_1.html" rel="prev"`) || !strings.Contains(string(second), `max="2"`) {
+		t.Errorf("新块应链接回第 1 块，总块数为 2")
+	}
+	index, err := os.ReadFile(filepath.Join(out, indexFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"book_chunk_1.html", "book_chunk_2.html"} {
+		if !strings.Contains(string(index), name) {
+			t.Errorf("目录页中没有 %s", name)
+		}
+	}
+}
+
+func TestCompleteLinesEnd(t *testing.T) {
+	long := strings.Repeat("a", 70*1024)
+	tests := []struct {
+		name    string
+		content string
+		start   int64
+		newline string
+		want    int64
+	}{
+		{"以换行结尾", "一\n二\n", 0, "\n", 8},
+		{"末尾的行未写完", "一\n二", 0, "\n", 4},
+		{"没有完整的行", "一二", 0, "\n", 0},
+		{"从 start 之后查找", "一\n二", 4, "\n", 4},
+		{"换行在前一段中", "a\n" + long, 0, "\n", 2},
+		{"UTF-16 小端", "\xff\xfea\x00\n\x00b\x00", 0, "\n\x00", 6},
+		// 0x0A 0x00 出现在奇数位置上，分属前后两个字符
+		{"UTF-16 不在字符边界上的字节", "\xff\xfe\x00\x0a\x00b", 0, "\n\x00", 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := completeLinesEnd(strings.NewReader(tt.content), tt.start, int64(len(tt.content)), []byte(tt.newline))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.want {
+				t.Errorf("completeLinesEnd = %d，期望 %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// 末尾还没写完的一行不转换，下次追加时与之后写入的内容一起作为完整的一行转换
+func TestConvertAppendWaitsForCompleteLine(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	out := filepath.Join(dir, "out")
+	if err := os.WriteFile(input, []byte("第一行\n第二"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	args := []string{"-quiet", "-append", "-lines", "1", "-out", out, input}
+	if err := run(args); err != nil {
+		t.Fatalf("第一次转换: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(out, "book_chunk_2.html")); !os.IsNotExist(err) {
+		t.Fatalf("未写完的一行也被转换了")
+	}
+
+	file, err := os.OpenFile(input, os.O_APPEND|os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file.WriteString("行\n")
+	file.Close()
+	if err := run(args); err != nil {
+		t.Fatalf("追加转换: %v", err)
+	}
+	second, err := os.ReadFile(filepath.Join(out, "book_chunk_2.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(second), "第二行") {
+		t.Errorf("第 2 块中没有完整的“第二行”")
+	}
+	if _, err := os.Stat(filepath.Join(out, "book_chunk_3.html")); !os.IsNotExist(err) {
+		t.Errorf("同一行被拆到了两次转换中")
+	}
+}
+
+// 两次追加之后，目录页和清单中第 1 块的条目与第一次转换时相同，不会带上新块的数据
+func TestConvertAppendExistingIndexEntries(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	out := filepath.Join(dir, "out")
+	args := []string{"-quiet", "-append", "-out", out, input}
+	entryRe := regexp.MustCompile(`(?s)<li>\s*<a href="book_chunk_1.html">.*?</li>`)
+	// 第 1 块的目录页条目和清单条目
+	chunkOne := func() (string, ChunkInfo) {
+		t.Helper()
+		index, err := os.ReadFile(filepath.Join(out, indexFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		content, err := os.ReadFile(filepath.Join(out, manifestFileName))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var infos []ChunkInfo
+		if err := json.Unmarshal(content, &infos); err != nil {
+			t.Fatal(err)
+		}
+		return entryRe.FindString(string(index)), infos[0]
+	}
+
+	if err := os.WriteFile(input, []byte("第一章 开始\n第一章的内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run(args); err != nil {
+		t.Fatalf("第一次转换: %v", err)
+	}
+	wantEntry, wantInfo := chunkOne()
+	if wantEntry == "" || wantInfo.Chapter != "第一章 开始" {
+		t.Fatalf("第一次转换后第 1 块的条目 = %q、%+v", wantEntry, wantInfo)
+	}
+	for i, text := range []string{"第二章 继续\n更多的内容，比第一章长得多。\n", "第三章 结束\n最后的内容\n"} {
+		file, err := os.OpenFile(input, os.O_APPEND|os.O_WRONLY, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		file.WriteString(text)
+		file.Close()
+		if err := run(args); err != nil {
+			t.Fatalf("第 %d 次追加: %v", i+1, err)
+		}
+	}
+	entry, info := chunkOne()
+	if entry != wantEntry {
+		t.Errorf("目录页中第 1 块的条目 = %q，期望 %q", entry, wantEntry)
+	}
+	if info != wantInfo {
+		t.Errorf("清单中第 1 块的条目 = %+v，期望 %+v", info, wantInfo)
+	}
+	if _, err := os.Stat(filepath.Join(out, "book_chunk_3.html")); err != nil {
+		t.Errorf("两次追加后没有第 3 块: %v", err)
+	}
+}
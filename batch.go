@@ -0,0 +1,170 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"txt2html/pkg/txt2html"
+)
+
+// 批量转换目录中的 .txt 和 .txt.gz 文件（-recursive 时包括子目录），每个文件按其相对路径
+// 输出到 opts.outputDir 下的 <文件名>_html_chunks 子目录（EPUB 格式时为 <文件名>.epub）。未指定编码时逐个文件自动检测。
+// 单个文件失败不会中断批量转换，全部处理完后统一汇总报告。每转换完一个文件都记入输出目录中的进度，
+// -resume 时跳过上次已完成且之后未修改的文件
+func convertDir(opts *options) error {
+	if opts.title != "" {
+		return fmt.Errorf("-title 只能用于单个文件或标准输入，不能用于目录")
+	}
+	// 编码对所有文件都一样，不支持时不必逐个文件报错
+	if name := opts.encodingName; name != "" && txt2html.CanonicalEncoding(name) == "" {
+		return fmt.Errorf("不支持的编码: %s（使用 -list-encodings 查看支持的编码）", name)
+	}
+
+	root := opts.inputPath
+	outputRoot, err := filepath.Abs(opts.outputDir)
+	if err != nil {
+		return fmt.Errorf("无法解析输出目录 %s: %w", opts.outputDir, err)
+	}
+
+	var files []string
+	var errs []error
+	walkErr := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			// 无法读取的子目录记为失败，继续处理其他文件
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			return nil
+		}
+		if d.IsDir() {
+			if path == root {
+				return nil
+			}
+			// 输出目录位于输入目录内时跳过，避免处理自己生成的文件
+			if abs, err := filepath.Abs(path); err == nil && abs == outputRoot {
+				return filepath.SkipDir
+			}
+			if !opts.recursive {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if strings.EqualFold(filepath.Ext(trimGzipSuffix(path)), ".txt") {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if walkErr != nil {
+		return fmt.Errorf("无法读取目录 %s: %w", root, walkErr)
+	}
+	if len(files) == 0 && len(errs) == 0 {
+		return fmt.Errorf("目录中没有 .txt 文件: %s", root)
+	}
+
+	if opts.report != nil {
+		opts.report.Output = opts.outputDir
+	}
+	// 试运行时只读取已有的进度，不创建输出目录，也不记录进度
+	if !opts.dryRun {
+		if err := os.MkdirAll(opts.outputDir, 0755); err != nil {
+			return fmt.Errorf("无法创建输出目录 %s: %w", opts.outputDir, err)
+		}
+	}
+	state := &batchState{Files: map[string]batchFile{}}
+	if opts.resumeBatch {
+		if state, err = loadBatchState(opts.outputDir); err != nil {
+			return err
+		}
+	} else if !opts.dryRun {
+		// 不继续上次的进度时从头记录，旧的记录留着会让之后的 -resume 跳过本次失败的文件
+		os.Remove(filepath.Join(opts.outputDir, batchStateFileName))
+	}
+
+	succeeded, skipped := 0, 0
+	for _, path := range files {
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		info, err := os.Stat(path)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		stateKey := filepath.ToSlash(rel)
+		if opts.resumeBatch && state.done(stateKey, info) {
+			slog.Info(fmt.Sprintf("跳过已转换的文件: %s", path))
+			skipped++
+			if opts.report != nil {
+				opts.report.add(&report{Status: "skipped", Input: path, Output: state.Files[stateKey].Output})
+			}
+			continue
+		}
+		fileOpts := *opts
+		fileOpts.inputPath = path
+		rel = trimGzipSuffix(rel)
+		fileOpts.fileName = filepath.Base(rel)
+		rel = filepath.Join(filepath.Dir(rel), fileOpts.outputName())
+		if opts.single {
+			fileOpts.outputDir = filepath.Join(opts.outputDir, strings.TrimSuffix(rel, filepath.Ext(rel))+".html")
+		} else if opts.format == formatEPUB {
+			fileOpts.outputDir = filepath.Join(opts.outputDir, strings.TrimSuffix(rel, filepath.Ext(rel))+".epub")
+		} else {
+			fileOpts.outputDir = filepath.Join(opts.outputDir, rel+"_html_chunks")
+		}
+		if fileOpts.encodingName == "" {
+			fileOpts.encodingName = txt2html.AutoEncoding
+		}
+		var start time.Time
+		if opts.report != nil {
+			start = time.Now()
+			fileOpts.report = &report{Input: path}
+		}
+		err = convert(&fileOpts)
+		if opts.report != nil {
+			fileOpts.report.finish(err, start)
+			opts.report.add(fileOpts.report)
+		}
+		if err != nil {
+			slog.Warn(fmt.Sprintf("转换失败: %s: %v", path, err))
+			errs = append(errs, fmt.Errorf("%s: %w", path, err))
+			continue
+		}
+		succeeded++
+		if opts.dryRun {
+			continue
+		}
+
+		output := fileOpts.outputDir
+		if opts.zipOnly {
+			output = filepath.Clean(output) + ".zip"
+		}
+		if abs, err := filepath.Abs(output); err == nil {
+			output = abs
+		}
+		// 进度保存失败只影响之后的 -resume，不中断本次转换
+		if err := state.complete(opts.outputDir, stateKey, info, output); err != nil {
+			slog.Warn(fmt.Sprintf("无法保存批量转换进度: %v", err))
+		}
+	}
+
+	if opts.dryRun {
+		slog.Info(fmt.Sprintf("试运行完成: 切分成功 %d 个，跳过 %d 个，失败 %d 个，未写入任何文件", succeeded, skipped, len(errs)))
+	} else if skipped > 0 {
+		slog.Info(fmt.Sprintf("批量转换完成: 成功 %d 个，跳过 %d 个，失败 %d 个，保存到 %s", succeeded, skipped, len(errs), opts.outputDir))
+	} else {
+		slog.Info(fmt.Sprintf("批量转换完成: 成功 %d 个，失败 %d 个，保存到 %s", succeeded, len(errs), opts.outputDir))
+	}
+	if len(errs) > 0 {
+		return fmt.Errorf("%d 个文件转换失败:\n%w", len(errs), errors.Join(errs...))
+	}
+	return nil
+}
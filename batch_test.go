@@ -0,0 +1,56 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// -resume 时跳过上次已完成且未修改的文件，修改过的文件重新转换
+func TestConvertDirResume(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "in")
+	if err := os.MkdirAll(input, 0755); err != nil {
+		t.Fatal(err)
+	}
+	write := func(name, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(input, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.txt", "甲文件\n")
+	write("b.txt", "乙文件\n")
+	if err := run([]string{"-quiet", "-out", "out", input}); err != nil {
+		t.Fatalf("批量转换: %v", err)
+	}
+
+	// 重新转换会清空输出目录，留下的文件说明跳过了该文件
+	kept := filepath.Join(dir, "out", "a.txt_html_chunks", "kept")
+	if err := os.WriteFile(kept, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	write("b.txt", "乙文件修改后的内容\n")
+	if err := run([]string{"-quiet", "-resume", "-out", "out", input}); err != nil {
+		t.Fatalf("继续转换: %v", err)
+	}
+	if _, err := os.Stat(kept); err != nil {
+		t.Errorf("未修改的 a.txt 被重新转换: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "out", "b.txt_html_chunks", "b_chunk_1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "乙文件修改后的内容") {
+		t.Error("修改过的 b.txt 没有重新转换")
+	}
+
+	// 不指定 -resume 时全部重新转换
+	if err := run([]string{"-quiet", "-out", "out", input}); err != nil {
+		t.Fatalf("重新批量转换: %v", err)
+	}
+	if _, err := os.Stat(kept); !os.IsNotExist(err) {
+		t.Error("没有 -resume 时跳过了已转换的文件")
+	}
+}
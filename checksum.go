@@ -0,0 +1,69 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+)
+
+const checksumFileName = "checksums.txt" // -checksum 生成的 SHA-256 清单文件名
+
+// 输出文件的 SHA-256 清单，可在多个 goroutine 中同时记录
+type checksums struct {
+	mu   sync.Mutex
+	sums map[string]string // 相对于输出目录的文件名 → 十六进制哈希
+}
+
+func newChecksums() *checksums {
+	return &checksums{sums: make(map[string]string)}
+}
+
+// 写入 w 的同时计算哈希，写完后调用 done 记为文件 name 的哈希
+func (c *checksums) tee(name string, w io.Writer) (io.Writer, func()) {
+	h := sha256.New()
+	return io.MultiWriter(w, h), func() { c.add(name, h) }
+}
+
+func (c *checksums) add(name string, h hash.Hash) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.sums[name] = hex.EncodeToString(h.Sum(nil))
+}
+
+// 读取输出目录中已写好的文件计算哈希，用于不经 tee 写入的文件（目录页、预压缩副本、追加时已有的分块等）
+func (c *checksums) addFile(dir, name string) error {
+	file, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return err
+	}
+	c.add(name, h)
+	return nil
+}
+
+// 在输出目录中写出清单，每行为“哈希  文件名”，按文件名排序，可在输出目录中用 sha256sum -c 校验
+func (c *checksums) write(dir string) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	names := make([]string, 0, len(c.sums))
+	for name := range c.sums {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	var b strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&b, "%s  %s\n", c.sums[name], filepath.ToSlash(name))
+	}
+	return os.WriteFile(filepath.Join(dir, checksumFileName), []byte(b.String()), 0644)
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 校验清单列出输出目录中除自身和隐藏的进度记录外的每个文件，格式与 sha256sum 相同，哈希与文件内容一致
+func TestConvertChecksum(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte(strings.Repeat("第一章 开始\n内容\n", 3)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := run([]string{"-quiet", "-checksum", "-gzip", "-copy-assets", "-out", out, input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(out, checksumFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var listed []string
+	for _, line := range strings.Split(strings.TrimSuffix(string(content), "\n"), "\n") {
+		sum, name, ok := strings.Cut(line, "  ")
+		if !ok {
+			t.Fatalf("无效的行: %q", line)
+		}
+		data, err := os.ReadFile(filepath.Join(out, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := sha256.Sum256(data); hex.EncodeToString(got[:]) != sum {
+			t.Errorf("%s 的哈希为 %x，清单中为 %s", name, got, sum)
+		}
+		listed = append(listed, name)
+	}
+
+	entries, err := os.ReadDir(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var want []string
+	for _, entry := range entries {
+		if name := entry.Name(); name != checksumFileName && !strings.HasPrefix(name, ".") {
+			want = append(want, name)
+		}
+	}
+	if !slices.Equal(listed, want) {
+		t.Errorf("清单中的文件 = %q，期望 %q", listed, want)
+	}
+	if !slices.Contains(listed, "book_chunk_1.html.gz") || !slices.Contains(listed, indexFileName) {
+		t.Errorf("清单中缺少分块的预压缩副本或目录页: %q", listed)
+	}
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+const configFileName = ".txt2html.json" // 每本书的配置文件，放在输入文件所在的目录中（目录输入时放在该目录中）
+
+// 配置文件中的设置，键名与对应的命令行选项相同（不带 -）。
+// 只有命令行中没有指定的选项才使用配置文件中的值，两者都没有时为选项的默认值
+type config struct {
+	Encoding     *string `json:"encoding"` // 同命令行中的 [编码] 参数
+	Title        *string `json:"title"`
+	Format       *string `json:"format"`
+	Size         *int    `json:"size"` // KB
+	Lines        *int    `json:"lines"`
+	Overlap      *int    `json:"overlap"`
+	RevealLines  *int    `json:"reveal-lines"`
+	Balance      *bool   `json:"balance"`
+	Width        *int    `json:"width"`
+	Columns      *int    `json:"columns"`
+	TabWidth     *int    `json:"tab-width"`
+	WrapAt       *int    `json:"wrap-at"`
+	Justify      *bool   `json:"justify"`
+	Indent       *bool   `json:"first-line-indent"`
+	Paragraphs   *string `json:"paragraphs"`
+	Theme        *string `json:"theme"`
+	UILang       *string `json:"ui-lang"`
+	NoGradient   *bool   `json:"no-gradient"`
+	Minimal      *bool   `json:"minimal"`
+	Minify       *bool   `json:"minify"`
+	Markdown     *bool   `json:"markdown"`
+	Sanitize     *bool   `json:"sanitize"`
+	Template     *string `json:"template"` // 相对路径相对于配置文件所在的目录
+	Favicon      *string `json:"favicon"`  // 同 template
+	Header       *string `json:"header"`
+	Footer       *string `json:"footer"`
+	NamePattern  *string `json:"name-pattern"`
+	VolumeRegex  *string `json:"volume-regex"`
+	ChapterRegex *string `json:"chapter-regex"`
+	SectionRegex *string `json:"section-regex"`
+	TOCDepth     *int    `json:"toc-depth"`
+	SplitMarker  *string `json:"split-marker"`
+}
+
+// 命令行中只指定了其中一个时，配置文件中的另一个不再生效，否则两者会冲突
+var configExclusive = map[string]string{"size": "lines", "lines": "size"}
+
+// 输入 inputPath 旁的配置文件，不存在时返回空字符串
+func findConfig(inputPath string) string {
+	dir := filepath.Dir(inputPath)
+	if info, err := os.Stat(inputPath); err == nil && info.IsDir() {
+		dir = inputPath
+	}
+	path := filepath.Join(dir, configFileName)
+	if _, err := os.Stat(path); err != nil {
+		return ""
+	}
+	return path
+}
+
+// 读取配置文件，拒绝未知的键，避免写错的键名被悄悄忽略
+func loadConfig(path string) (*config, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法读取配置文件: %w", err)
+	}
+	defer f.Close()
+	cfg := &config{}
+	decoder := json.NewDecoder(f)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(cfg); err != nil {
+		return nil, fmt.Errorf("无法解析配置文件 %s: %w", path, err)
+	}
+	for _, file := range []*string{cfg.Template, cfg.Favicon} {
+		if file != nil && *file != "" && !filepath.IsAbs(*file) {
+			*file = filepath.Join(filepath.Dir(path), *file)
+		}
+	}
+	return cfg, nil
+}
+
+// 按选项名列出配置文件中出现的设置，值为命令行中的写法
+func (c *config) flagValues() map[string]string {
+	values := map[string]string{}
+	text := func(name string, v *string) {
+		if v != nil {
+			values[name] = *v
+		}
+	}
+	number := func(name string, v *int) {
+		if v != nil {
+			values[name] = strconv.Itoa(*v)
+		}
+	}
+	boolean := func(name string, v *bool) {
+		if v != nil {
+			values[name] = strconv.FormatBool(*v)
+		}
+	}
+	text("title", c.Title)
+	text("format", c.Format)
+	number("size", c.Size)
+	number("lines", c.Lines)
+	number("overlap", c.Overlap)
+	number("reveal-lines", c.RevealLines)
+	boolean("balance", c.Balance)
+	number("width", c.Width)
+	number("columns", c.Columns)
+	number("tab-width", c.TabWidth)
+	number("wrap-at", c.WrapAt)
+	boolean("justify", c.Justify)
+	boolean("first-line-indent", c.Indent)
+	text("paragraphs", c.Paragraphs)
+	text("theme", c.Theme)
+	text("ui-lang", c.UILang)
+	boolean("no-gradient", c.NoGradient)
+	boolean("minimal", c.Minimal)
+	boolean("minify", c.Minify)
+	boolean("markdown", c.Markdown)
+	boolean("sanitize", c.Sanitize)
+	text("template", c.Template)
+	text("favicon", c.Favicon)
+	text("header", c.Header)
+	text("footer", c.Footer)
+	text("name-pattern", c.NamePattern)
+	text("volume-regex", c.VolumeRegex)
+	text("chapter-regex", c.ChapterRegex)
+	text("section-regex", c.SectionRegex)
+	number("toc-depth", c.TOCDepth)
+	text("split-marker", c.SplitMarker)
+	return values
+}
+
+// 把配置文件中的设置填入命令行中没有指定的选项
+func (c *config) apply(fs *flag.FlagSet) error {
+	explicit := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, value := range c.flagValues() {
+		if explicit[name] || explicit[configExclusive[name]] {
+			continue
+		}
+		if err := fs.Set(name, value); err != nil {
+			return fmt.Errorf("配置文件中的 %s 无效: %w", name, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 输入旁的配置文件填入命令行中没有指定的选项，命令行中指定的选项和编码优先
+func TestParseOptionsConfig(t *testing.T) {
+	dir := t.TempDir()
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	config := `{"encoding": "gbk", "size": 512, "width": 900, "theme": "sepia", "justify": true}`
+	if err := os.WriteFile(filepath.Join(dir, configFileName), []byte(config), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	opts, err := parseOptions([]string{"-width", "700", input})
+	if err != nil {
+		t.Fatalf("parseOptions: %v", err)
+	}
+	if opts.encodingName != "gbk" || opts.targetSize != 512*1024 || opts.theme != "sepia" || !opts.justify {
+		t.Errorf("配置文件没有生效: 编码 %q，大小 %d，主题 %q，两端对齐 %v", opts.encodingName, opts.targetSize, opts.theme, opts.justify)
+	}
+	if opts.width != 700 {
+		t.Errorf("width = %d，期望命令行中的 700", opts.width)
+	}
+
+	// 命令行中的编码和 -lines 优先，配置文件中的 -size 不再与 -lines 冲突
+	opts, err = parseOptions([]string{"-lines", "100", input, "utf-8"})
+	if err != nil {
+		t.Fatalf("parseOptions: %v", err)
+	}
+	if opts.encodingName != "utf-8" || opts.linesPerChunk != 100 {
+		t.Errorf("命令行没有优先: 编码 %q，行数 %d", opts.encodingName, opts.linesPerChunk)
+	}
+
+	// 写错的键名报错而不是被忽略
+	bad := filepath.Join(dir, "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"sise": 512}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := parseOptions([]string{"-config", bad, input}); err == nil || !strings.Contains(err.Error(), "sise") {
+		t.Errorf("未知的键: err = %v，期望报错", err)
+	}
+}
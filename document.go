@@ -0,0 +1,548 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// 可以提取文本的文档格式，两者都是 zip 文件，按其中的文件区分
+const (
+	documentEPUB = "EPUB"
+	documentDOCX = "DOCX"
+)
+
+var zipMagic = []byte("PK\x03\x04") // zip 文件开头的四个字节
+
+// 按文件头和 zip 中的文件判断 f 是否为 EPUB 或 DOCX，是时同时返回打开的 zip，其他文件返回空字符串。
+// 扩展名为 .epub、.docx 却不是有效的 zip 时报错，避免把压缩数据当作文本转换
+func detectDocument(f *os.File, size int64) (string, *zip.Reader, error) {
+	magic := make([]byte, len(zipMagic))
+	n, _ := f.ReadAt(magic, 0)
+	ext := strings.ToLower(path.Ext(f.Name()))
+	if !bytes.Equal(magic[:n], zipMagic) {
+		if ext == ".epub" || ext == ".docx" {
+			return "", nil, fmt.Errorf("%s 不是有效的 %s 文件", f.Name(), strings.ToUpper(ext[1:]))
+		}
+		return "", nil, nil
+	}
+	zr, err := zip.NewReader(f, size)
+	if err != nil {
+		return "", nil, fmt.Errorf("无法读取 %s: %w", f.Name(), err)
+	}
+	for _, file := range zr.File {
+		switch file.Name {
+		case "META-INF/container.xml":
+			return documentEPUB, zr, nil
+		case "word/document.xml":
+			return documentDOCX, zr, nil
+		}
+	}
+	return "", nil, nil
+}
+
+// 从文档中提取的纯文本，每段一行。titles 为文档自带的章节标题（EPUB 的目录、DOCX 中使用标题样式的段落），
+// 用来识别正文中不符合章节正则的标题行；title 为文档属性中的书名，没有时为空
+type extractedText struct {
+	text   []byte
+	lines  int
+	titles []string
+	title  string
+}
+
+func extractDocument(zr *zip.Reader, kind string) (*extractedText, error) {
+	var doc *extractedText
+	var err error
+	if kind == documentEPUB {
+		doc, err = extractEPUB(zr)
+	} else {
+		doc, err = extractDOCX(zr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("无法从 %s 中提取文本: %w", kind, err)
+	}
+	return doc, nil
+}
+
+// 读取 EPUB 输入用的 container.xml 和 OPF，只取用到的字段
+type containerXML struct {
+	Rootfiles []struct {
+		FullPath string `xml:"full-path,attr"`
+	} `xml:"rootfiles>rootfile"`
+}
+
+type opfPackage struct {
+	Title    string `xml:"metadata>title"`
+	Manifest []struct {
+		ID         string `xml:"id,attr"`
+		Href       string `xml:"href,attr"`
+		MediaType  string `xml:"media-type,attr"`
+		Properties string `xml:"properties,attr"`
+	} `xml:"manifest>item"`
+	Spine struct {
+		TOC      string `xml:"toc,attr"`
+		ItemRefs []struct {
+			IDRef string `xml:"idref,attr"`
+		} `xml:"itemref"`
+	} `xml:"spine"`
+}
+
+// EPUB 2 目录（NCX）中的一项，可以嵌套
+type ncxPoint struct {
+	Label  string     `xml:"navLabel>text"`
+	Points []ncxPoint `xml:"navPoint"`
+}
+
+// 按书脊（spine）顺序提取各章的文字，章与章之间空一行。目录优先取 EPUB 3 的导航文档，没有时取 NCX
+func extractEPUB(zr *zip.Reader) (*extractedText, error) {
+	var container containerXML
+	if err := decodeZipXML(zr, "META-INF/container.xml", &container); err != nil {
+		return nil, err
+	}
+	if len(container.Rootfiles) == 0 {
+		return nil, fmt.Errorf("container.xml 中没有 rootfile")
+	}
+	opfPath := container.Rootfiles[0].FullPath
+	var pkg opfPackage
+	if err := decodeZipXML(zr, opfPath, &pkg); err != nil {
+		return nil, err
+	}
+	// 清单中的路径相对于 OPF 文件，可能经过 URL 编码
+	resolve := func(href string) string {
+		if i := strings.IndexByte(href, '#'); i >= 0 {
+			href = href[:i]
+		}
+		if unescaped, err := url.PathUnescape(href); err == nil {
+			href = unescaped
+		}
+		return path.Join(path.Dir(opfPath), href)
+	}
+	items := map[string]string{}
+	var navPath, ncxPath string
+	for _, item := range pkg.Manifest {
+		items[item.ID] = resolve(item.Href)
+		switch {
+		case strings.Contains(" "+item.Properties+" ", " nav "):
+			navPath = resolve(item.Href)
+		case item.ID == pkg.Spine.TOC, item.MediaType == "application/x-dtbncx+xml" && ncxPath == "":
+			ncxPath = resolve(item.Href)
+		}
+	}
+
+	doc := &lineWriter{}
+	for _, ref := range pkg.Spine.ItemRefs {
+		name, ok := items[ref.IDRef]
+		if !ok {
+			continue
+		}
+		if doc.lines > 0 {
+			doc.blank()
+		}
+		if err := withZipFile(zr, name, func(r io.Reader) error {
+			return xhtmlText(r, doc)
+		}); err != nil {
+			return nil, err
+		}
+	}
+
+	result := &extractedText{text: doc.buf.Bytes(), lines: doc.lines, title: strings.TrimSpace(pkg.Title)}
+	switch {
+	case navPath != "":
+		titles, err := epubNavTitles(zr, navPath)
+		if err != nil {
+			return nil, err
+		}
+		result.titles = titles
+	case ncxPath != "":
+		var ncx struct {
+			Points []ncxPoint `xml:"navMap>navPoint"`
+		}
+		if err := decodeZipXML(zr, ncxPath, &ncx); err != nil {
+			return nil, err
+		}
+		var collect func(points []ncxPoint)
+		collect = func(points []ncxPoint) {
+			for _, p := range points {
+				result.titles = append(result.titles, p.Label)
+				collect(p.Points)
+			}
+		}
+		collect(ncx.Points)
+	}
+	return result, nil
+}
+
+// EPUB 3 导航文档中 <nav epub:type="toc"> 里各链接的文字
+func epubNavTitles(zr *zip.Reader, name string) ([]string, error) {
+	var titles []string
+	err := withZipFile(zr, name, func(r io.Reader) error {
+		d := newHTMLDecoder(r)
+		inTOC := false
+		var link *strings.Builder
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("无法解析 %s: %w", name, err)
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				switch strings.ToLower(t.Name.Local) {
+				case "nav":
+					for _, attr := range t.Attr {
+						if attr.Name.Local == "type" && strings.Contains(" "+attr.Value+" ", " toc ") {
+							inTOC = true
+						}
+					}
+				case "a":
+					if inTOC {
+						link = &strings.Builder{}
+					}
+				}
+			case xml.EndElement:
+				switch strings.ToLower(t.Name.Local) {
+				case "nav":
+					inTOC = false
+				case "a":
+					if link != nil {
+						titles = append(titles, link.String())
+						link = nil
+					}
+				}
+			case xml.CharData:
+				if link != nil {
+					link.Write(t)
+				}
+			}
+		}
+	})
+	return titles, err
+}
+
+// 块级元素的开头和结尾都换行，其余元素中的文字接在当前行后面
+var blockElements = map[string]bool{
+	"address": true, "article": true, "aside": true, "blockquote": true, "body": true, "dd": true, "div": true,
+	"dl": true, "dt": true, "figcaption": true, "figure": true, "footer": true, "h1": true, "h2": true,
+	"h3": true, "h4": true, "h5": true, "h6": true, "header": true, "hr": true, "li": true, "main": true,
+	"nav": true, "ol": true, "p": true, "pre": true, "section": true, "table": true, "td": true, "th": true,
+	"tr": true, "ul": true,
+}
+
+// 其中的文字不属于正文：页面标题、样式、脚本和注音
+var skippedElements = map[string]bool{"head": true, "script": true, "style": true, "rt": true, "rp": true}
+
+// 把 XHTML 正文的文字按块写成行，空白合并为一个空格；<pre> 中保持原样
+func xhtmlText(r io.Reader, w *lineWriter) error {
+	d := newHTMLDecoder(r)
+	skip, pre := 0, 0
+	for {
+		tok, err := d.Token()
+		if err == io.EOF {
+			w.endLine()
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch t := tok.(type) {
+		case xml.StartElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case skippedElements[name]:
+				skip++
+			case name == "br":
+				w.endLine()
+			case blockElements[name]:
+				w.endLine()
+				if name == "pre" {
+					pre++
+				}
+			}
+		case xml.EndElement:
+			name := strings.ToLower(t.Name.Local)
+			switch {
+			case skippedElements[name]:
+				skip = max(skip-1, 0)
+			case blockElements[name]:
+				w.endLine()
+				if name == "pre" {
+					pre = max(pre-1, 0)
+				}
+			}
+		case xml.CharData:
+			switch {
+			case skip > 0:
+			case pre > 0:
+				for i, part := range strings.Split(string(t), "\n") {
+					if i > 0 {
+						w.endLine()
+					}
+					w.writeRaw(part)
+				}
+			default:
+				// 文字中的换行按换行处理：按 pre-wrap 排版的 EPUB（例如本工具生成的）把一段段文字写在同一个元素中，
+				// 合并成一行会丢失分段。标签之间用于缩进的换行前后没有文字，不产生空行
+				for i, part := range strings.Split(string(t), "\n") {
+					if i > 0 {
+						w.endLine()
+					}
+					w.writeCollapsed(part)
+				}
+			}
+		}
+	}
+}
+
+// EPUB 中的 XHTML 不一定严格合法，按 HTML 的方式宽松解析，并识别 &nbsp; 等 HTML 实体
+func newHTMLDecoder(r io.Reader) *xml.Decoder {
+	d := xml.NewDecoder(r)
+	d.Strict = false
+	d.AutoClose = xml.HTMLAutoClose
+	d.Entity = xml.HTMLEntity
+	return d
+}
+
+// 按段落提取 document.xml 的文字，每段一行，连续的空段落只保留一个空行；
+// 使用标题样式（styles.xml 中名为 heading N 或 Title）或设置了大纲级别的段落作为章节标题
+func extractDOCX(zr *zip.Reader) (*extractedText, error) {
+	headingStyles, err := docxHeadingStyles(zr)
+	if err != nil {
+		return nil, err
+	}
+	doc := &lineWriter{}
+	var titles []string
+	heading := false
+	var paragraph strings.Builder
+	err = withZipFile(zr, "word/document.xml", func(r io.Reader) error {
+		d := xml.NewDecoder(r)
+		inRun, inText := false, false // 制表符和换行只在文字段（w:r）中有效，段落属性中的 w:tab 是制表位
+		for {
+			tok, err := d.Token()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("无法解析 document.xml: %w", err)
+			}
+			switch t := tok.(type) {
+			case xml.StartElement:
+				switch t.Name.Local {
+				case "p":
+					heading = false
+					paragraph.Reset()
+				case "pStyle":
+					heading = heading || headingStyles[xmlAttr(t, "val")]
+				case "outlineLvl":
+					// 大纲级别 9 表示正文
+					heading = heading || xmlAttr(t, "val") < "9"
+				case "r":
+					inRun = true
+				case "t":
+					inText = true
+				case "tab":
+					if inRun {
+						paragraph.WriteByte('\t')
+					}
+				case "br", "cr":
+					if inRun {
+						doc.writeRaw(paragraph.String())
+						doc.endLine()
+						paragraph.Reset()
+					}
+				}
+			case xml.EndElement:
+				switch t.Name.Local {
+				case "r":
+					inRun = false
+				case "t":
+					inText = false
+				case "p":
+					text := strings.TrimSpace(paragraph.String())
+					if text == "" {
+						doc.blank()
+						continue
+					}
+					if heading {
+						titles = append(titles, text)
+					}
+					doc.writeRaw(text)
+					doc.endLine()
+				}
+			case xml.CharData:
+				if inText {
+					paragraph.Write(t)
+				}
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	// 书名在 docProps/core.xml 中，没有这个文件时不影响转换
+	var core struct {
+		Title string `xml:"title"`
+	}
+	if err := decodeZipXML(zr, "docProps/core.xml", &core); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+	return &extractedText{text: doc.buf.Bytes(), lines: doc.lines, titles: titles, title: strings.TrimSpace(core.Title)}, nil
+}
+
+// styles.xml 中标题样式的 ID。中文版 Word 的样式 ID 是“1”“2”这样的数字，样式名仍为英文的 heading 1
+func docxHeadingStyles(zr *zip.Reader) (map[string]bool, error) {
+	var styles struct {
+		Styles []struct {
+			ID   string `xml:"styleId,attr"`
+			Name struct {
+				Val string `xml:"val,attr"`
+			} `xml:"name"`
+		} `xml:"style"`
+	}
+	if err := decodeZipXML(zr, "word/styles.xml", &styles); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	ids := map[string]bool{}
+	for _, s := range styles.Styles {
+		name := strings.ToLower(s.Name.Val)
+		if strings.HasPrefix(name, "heading ") || name == "title" {
+			ids[s.ID] = true
+		}
+	}
+	return ids, nil
+}
+
+func xmlAttr(e xml.StartElement, local string) string {
+	for _, attr := range e.Attr {
+		if attr.Name.Local == local {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// 逐行写出提取的文本，连续的空行只保留一个，开头不写空行
+type lineWriter struct {
+	buf     bytes.Buffer
+	line    strings.Builder
+	lines   int
+	blanked bool // 最后写出的是空行
+	space   bool // 合并空白时有待写出的空格
+	last    rune // 当前行的最后一个字符
+}
+
+func (w *lineWriter) writeRaw(s string) {
+	w.flushSpace(' ')
+	w.line.WriteString(s)
+	if s != "" {
+		w.last = []rune(s)[len([]rune(s))-1]
+	}
+}
+
+// 写入文字，连续的空白合并为一个空格；空白两侧都是汉字等全角字符时不加空格
+func (w *lineWriter) writeCollapsed(s string) {
+	for _, r := range s {
+		if unicode.IsSpace(r) {
+			w.space = w.line.Len() > 0
+			continue
+		}
+		w.flushSpace(r)
+		w.line.WriteRune(r)
+		w.last = r
+	}
+}
+
+func (w *lineWriter) flushSpace(next rune) {
+	if w.space && !(isWide(w.last) && isWide(next)) {
+		w.line.WriteByte(' ')
+	}
+	w.space = false
+}
+
+// 结束当前行，空行不写出
+func (w *lineWriter) endLine() {
+	text := strings.TrimRightFunc(w.line.String(), unicode.IsSpace)
+	w.line.Reset()
+	w.space = false
+	w.last = 0
+	if strings.TrimSpace(text) == "" {
+		return
+	}
+	w.buf.WriteString(text)
+	w.buf.WriteByte('\n')
+	w.lines++
+	w.blanked = false
+}
+
+func (w *lineWriter) blank() {
+	w.endLine()
+	if w.lines == 0 || w.blanked {
+		return
+	}
+	w.buf.WriteByte('\n')
+	w.lines++
+	w.blanked = true
+}
+
+// 汉字、假名、全角标点等，相邻时中间不需要空格
+func isWide(r rune) bool {
+	return r >= 0x2E80
+}
+
+// 打开 zip 中的文件交给 read，name 为 zip 中的路径
+func withZipFile(zr *zip.Reader, name string, read func(r io.Reader) error) error {
+	f, err := zr.Open(name)
+	if err != nil {
+		return fmt.Errorf("缺少 %s: %w", name, err)
+	}
+	defer f.Close()
+	return read(f)
+}
+
+func decodeZipXML(zr *zip.Reader, name string, v any) error {
+	return withZipFile(zr, name, func(r io.Reader) error {
+		if err := xml.NewDecoder(r).Decode(v); err != nil {
+			return fmt.Errorf("无法解析 %s: %w", name, err)
+		}
+		return nil
+	})
+}
+
+// 匹配文档自带章节标题的正则：标题行与其中之一相同即可，空白的有无和多少不计。
+// 过长的“标题”多半是整段文字，不参与匹配
+func documentTitlePattern(titles []string) *regexp.Regexp {
+	seen := map[string]bool{}
+	var alternatives []string
+	for _, title := range titles {
+		words := strings.Fields(title)
+		if len(words) == 0 || len([]rune(title)) > 100 {
+			continue
+		}
+		for i, word := range words {
+			words[i] = regexp.QuoteMeta(word)
+		}
+		pattern := strings.Join(words, `[\s　]*`)
+		if !seen[pattern] {
+			seen[pattern] = true
+			alternatives = append(alternatives, pattern)
+		}
+	}
+	if len(alternatives) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`^[\s　]*(?:` + strings.Join(alternatives, "|") + `)[\s　]*$`)
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// 把 files 写成 zip 文件 path，键为 zip 中的路径
+func writeZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	zw := zip.NewWriter(f)
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	for _, name := range names {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := w.Write([]byte(files[name])); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// EPUB 输入按书脊顺序提取文字，每段一行；导航文档中的标题即使不符合章节正则也识别为章节，书名取自元数据
+func TestConvertEPUBInput(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.epub")
+	writeZip(t, input, map[string]string{
+		"mimetype":               "application/epub+zip",
+		"META-INF/container.xml": `<container><rootfiles><rootfile full-path="OEBPS/content.opf"/></rootfiles></container>`,
+		"OEBPS/content.opf": `<package xmlns="http://www.idpf.org/2007/opf"><metadata xmlns:dc="http://purl.org/dc/elements/1.1/"><dc:title>测试书名</dc:title></metadata>
+<manifest><item id="nav" href="nav.xhtml" properties="nav"/><item id="a" href="text/a%20b.xhtml"/><item id="c" href="text/c.xhtml"/></manifest>
+<spine><itemref idref="a"/><itemref idref="c"/></spine></package>`,
+		"OEBPS/nav.xhtml": `<html xmlns:epub="http://www.idpf.org/2007/ops"><body><nav epub:type="toc"><ol>
+<li><a href="text/a%20b.xhtml">序章</a></li><li><a href="text/c.xhtml">尾声</a></li></ol></nav></body></html>`,
+		"OEBPS/text/a b.xhtml": `<html><head><title>不是正文</title><style>p{}</style></head><body>
+<h1>序章</h1>
+<p>第一段，
+换行的文字&nbsp;继续。</p>
+<p>第二段 with <em>English</em>
+ words.</p>
+</body></html>`,
+		"OEBPS/text/c.xhtml": `<html><body><h2>尾声</h2><div>最后一段<br/>另起一行</div></body></html>`,
+	})
+	if err := run([]string{"-quiet", "-out", "out", input}); err != nil {
+		t.Fatalf("转换 EPUB: %v", err)
+	}
+	// 尾声是新的章节，从第二块开始
+	var page string
+	for _, name := range []string{"book_chunk_1.html", "book_chunk_2.html"} {
+		content, err := os.ReadFile(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		page += string(content)
+	}
+	for _, want := range []string{
+		"<title>测试书名",
+		`data-chapter="序章"`,
+		`data-chapter="尾声"`,
+		"第一段，\n换行的文字继续。\n",
+		"第二段 with English\nwords.\n",
+		"最后一段\n另起一行\n",
+	} {
+		if !strings.Contains(page, want) {
+			t.Errorf("页面中缺少 %q", want)
+		}
+	}
+	if strings.Contains(page, "不是正文") {
+		t.Error("页面标题被当作正文")
+	}
+}
+
+// DOCX 每段一行，制表符和段内换行保留，使用标题样式的段落作为章节标题
+func TestExtractDOCX(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "a.docx")
+	writeZip(t, path, map[string]string{
+		"word/styles.xml": `<w:styles xmlns:w="w"><w:style w:styleId="1"><w:name w:val="heading 1"/></w:style><w:style w:styleId="a"><w:name w:val="Normal"/></w:style></w:styles>`,
+		"word/document.xml": `<w:document xmlns:w="w"><w:body>
+<w:p><w:pPr><w:pStyle w:val="1"/></w:pPr><w:r><w:t>开篇</w:t></w:r></w:p>
+<w:p><w:pPr><w:tabs><w:tab w:val="left"/></w:tabs></w:pPr><w:r><w:t xml:space="preserve">甲 </w:t></w:r><w:r><w:tab/><w:t>乙</w:t><w:br/><w:t>丙</w:t></w:r></w:p>
+<w:p/><w:p/>
+<w:p><w:r><w:t>丁</w:t></w:r></w:p>
+</w:body></w:document>`,
+	})
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		t.Fatal(err)
+	}
+	kind, zr, err := detectDocument(f, info.Size())
+	if err != nil || kind != documentDOCX {
+		t.Fatalf("detectDocument = %q, %v，期望 DOCX", kind, err)
+	}
+	doc, err := extractDocument(zr, kind)
+	if err != nil {
+		t.Fatalf("extractDocument: %v", err)
+	}
+	if want := "开篇\n甲 \t乙\n丙\n\n丁\n"; string(doc.text) != want {
+		t.Errorf("提取的文本 = %q，期望 %q", doc.text, want)
+	}
+	if !slices.Equal(doc.titles, []string{"开篇"}) {
+		t.Errorf("titles = %q，期望 [开篇]", doc.titles)
+	}
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"archive/zip"
+	"crypto/rand"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"time"
+
+	"txt2html/pkg/txt2html"
+)
+
+// EPUB 内的文件路径
+const (
+	epubContentDir = "OEBPS"
+	epubStyleFile  = txt2html.XHTMLStyleFile
+	epubNavFile    = "nav.xhtml"
+	epubOPFFile    = "content.opf"
+)
+
+// EPUB 内的分块文件名，不使用 -name-pattern
+var epubNamePattern, _ = txt2html.ParseNamePattern("chunk_{n}.xhtml")
+
+const epubContainer = `<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="` + epubContentDir + `/` + epubOPFFile + `" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+`
+
+// 阅读器自带字号、配色等设置，这里只保留与分块页面一致的基本排版
+const epubStyle = `.content {
+    white-space: pre-wrap;
+    word-wrap: break-word;
+    line-height: 1.6;
+}
+.content.markdown {
+    white-space: normal;
+}
+.content.markdown pre {
+    white-space: pre-wrap;
+}
+.chapter-heading {
+    font-weight: bold;
+}
+.indent {
+    display: inline-block;
+    width: 2em;
+}
+.paragraph-gap {
+    display: block;
+    height: 0.5em;
+}
+.content > .paragraph-gap:first-child {
+    display: none;
+}
+.overlap {
+    opacity: 0.45;
+    border-bottom: 1px dashed #999;
+}
+.line-number::before {
+    content: attr(data-line);
+    display: inline-block;
+    min-width: 3em;
+    margin-right: 1em;
+    text-align: right;
+    color: #999;
+}
+.page-header, .page-footer {
+    color: #666;
+    font-size: 0.9em;
+    text-align: center;
+}
+`
+
+// 导航文档：有章节时按层级嵌套列出章节，否则列出每个分块
+const epubNavTemplate = `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" xml:lang="{{.Label "lang"}}" lang="{{.Label "lang"}}">
+<head>
+    <meta charset="UTF-8"/>
+    <title>{{.Title}} - {{.Label "index"}}</title>
+</head>
+<body>
+    <nav epub:type="toc" id="toc">
+        <h1>{{.Label "index"}}</h1>
+        {{template "navList" .Entries}}
+    </nav>
+</body>
+</html>
+{{define "navList"}}<ol>
+{{range .}}<li><a href="{{.Link}}">{{.Text}}</a>{{if .Children}}
+{{template "navList" .Children}}{{end}}</li>
+{{end}}</ol>{{end}}`
+
+const epubOPFTemplate = `<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id" xml:lang="{{.Language}}">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Identifier}}</dc:identifier>
+    <dc:title>{{.Title}}</dc:title>
+    <dc:language>{{.Language}}</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="` + epubNavFile + `" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="style" href="` + epubStyleFile + `" media-type="text/css"/>
+    {{range .Chunks}}<item id="{{.ID}}" href="{{.Href}}" media-type="application/xhtml+xml"/>
+    {{end}}
+  </manifest>
+  <spine>
+    {{range .Chunks}}<itemref idref="{{.ID}}"/>
+    {{end}}
+  </spine>
+</package>
+`
+
+// content.opf 模板数据
+type epubPackage struct {
+	Identifier string
+	Title      string
+	Modified   string // 最后修改时间，EPUB3 要求的 UTC 格式
+	Language   string // 书的语言，与界面文字的语言一致
+	Chunks     []epubItem
+}
+
+// content.opf 中的一个分块文件
+type epubItem struct {
+	ID   string
+	Href string
+}
+
+// 导航文档模板数据
+type epubNav struct {
+	Title   string
+	Entries []*tocEntry
+	UILang  string // 界面文字的语言
+}
+
+// 导航文档模板中使用的界面文字
+func (n epubNav) Label(key string) string {
+	return txt2html.UILabel(n.UILang, key)
+}
+
+var (
+	epubNavTmpl = template.Must(template.New("epubNav").Parse(epubNavTemplate))
+	epubOPFTmpl = template.Must(template.New("epubOPF").Parse(epubOPFTemplate))
+)
+
+// 把切分好的书打包为 EPUB3：mimetype、META-INF/container.xml、
+// 每块一个 XHTML 文件、content.opf 清单与阅读顺序，以及根据章节生成的 nav.xhtml 目录。
+// book 应以 LayoutXHTML 和 epubNamePattern 切分
+func writeEPUB(path string, book *txt2html.Book) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	zw := zip.NewWriter(file)
+	if err := writeEPUBEntries(zw, book); err != nil {
+		zw.Close()
+		file.Close()
+		return err
+	}
+	if err := zw.Close(); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func writeEPUBEntries(zw *zip.Writer, book *txt2html.Book) error {
+	chunkData := book.Chunks
+	// mimetype 必须是第一个文件且不压缩，阅读器靠它识别格式
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	if err := writeZipEntry(zw, "META-INF/container.xml", func(w io.Writer) error {
+		_, err := io.WriteString(w, epubContainer)
+		return err
+	}); err != nil {
+		return err
+	}
+	if err := writeZipEntry(zw, epubContentDir+"/"+epubStyleFile, func(w io.Writer) error {
+		_, err := io.WriteString(w, epubStyle)
+		return err
+	}); err != nil {
+		return err
+	}
+
+	title, uiLang := "", ""
+	chunks := make([]epubItem, len(chunkData))
+	for i, data := range chunkData {
+		title, uiLang = data.Title, data.UILang
+		chunks[i] = epubItem{ID: fmt.Sprintf("chunk-%d", data.CurrentChunk), Href: data.OutputFile}
+		if err := writeZipEntry(zw, epubContentDir+"/"+data.OutputFile, func(w io.Writer) error {
+			return book.Render(data.CurrentChunk, w)
+		}); err != nil {
+			return err
+		}
+	}
+
+	nav := epubNav{Title: title, UILang: uiLang}
+	if len(book.Headings) > 0 {
+		nav.Entries, _ = buildTOCTree(chunkData, book.Headings)
+	} else {
+		for _, data := range chunkData {
+			nav.Entries = append(nav.Entries, &tocEntry{
+				Text: fmt.Sprintf(data.Label("part"), data.CurrentChunk),
+				Link: data.OutputFile,
+			})
+		}
+	}
+	if err := writeZipEntry(zw, epubContentDir+"/"+epubNavFile, func(w io.Writer) error {
+		return executeXML(w, epubNavTmpl, nav)
+	}); err != nil {
+		return err
+	}
+
+	id, err := newUUID()
+	if err != nil {
+		return err
+	}
+	pkg := epubPackage{
+		Identifier: "urn:uuid:" + id,
+		Title:      title,
+		Modified:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		Language:   txt2html.UILabel(uiLang, "lang"),
+		Chunks:     chunks,
+	}
+	return writeZipEntry(zw, epubContentDir+"/"+epubOPFFile, func(w io.Writer) error {
+		return executeXML(w, epubOPFTmpl, pkg)
+	})
+}
+
+// 以压缩方式在压缩包中写入一个文件，内容由 write 生成
+func writeZipEntry(zw *zip.Writer, name string, write func(w io.Writer) error) error {
+	w, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Deflate, Modified: time.Now()})
+	if err != nil {
+		return err
+	}
+	return write(w)
+}
+
+// 输出 XML 声明和模板渲染结果
+func executeXML(w io.Writer, tmpl *template.Template, data any) error {
+	if _, err := io.WriteString(w, txt2html.XMLDeclaration); err != nil {
+		return err
+	}
+	return tmpl.Execute(w, data)
+}
+
+// 生成随机的 UUID（版本4），用作 EPUB 的唯一标识
+func newUUID() (string, error) {
+	var b [16]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", err
+	}
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:16]), nil
+}
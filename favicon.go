@@ -0,0 +1,38 @@
+package main
+
+import (
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 图标以 data URI 复制到每个页面中，太大的图片会让每块都变大
+const maxFaviconSize = 64 * 1024
+
+// 读取 -favicon 指定的图片，返回嵌入页面的 data URI。图片类型按扩展名判断，
+// 扩展名无法识别时按内容判断（SVG 只能按扩展名识别）
+func loadFavicon(path string) (template.URL, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("无法读取图标: %w", err)
+	}
+	if len(data) == 0 {
+		return "", fmt.Errorf("图标文件为空: %s", path)
+	}
+	if len(data) > maxFaviconSize {
+		return "", fmt.Errorf("图标文件太大: %s（%d KB，最多 %d KB），图标会嵌入每个页面", path, len(data)/1024, maxFaviconSize/1024)
+	}
+	mimeType, _, _ := strings.Cut(mime.TypeByExtension(strings.ToLower(filepath.Ext(path))), ";")
+	if !strings.HasPrefix(mimeType, "image/") {
+		mimeType, _, _ = strings.Cut(http.DetectContentType(data), ";")
+	}
+	if !strings.HasPrefix(mimeType, "image/") {
+		return "", fmt.Errorf("图标不是可识别的图片: %s（支持 PNG、ICO、SVG、GIF、JPEG、WebP）", path)
+	}
+	return template.URL("data:" + mimeType + ";base64," + base64.StdEncoding.EncodeToString(data)), nil
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"encoding/base64"
+	"html"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"txt2html/pkg/txt2html"
+)
+
+// -favicon 的图片以 data URI 嵌入分块、目录页和搜索页面，未指定时使用默认图标；不是图片时报错
+func TestConvertFavicon(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("第一章 开始\n内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	png := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR")
+	icon := filepath.Join(dir, "icon.dat") // 扩展名无法识别，按内容判断
+	if err := os.WriteFile(icon, png, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := run([]string{"-quiet", "-favicon", icon, "-out", "out", input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	want := `<link rel="icon" href="data:image/png;base64,` + base64.StdEncoding.EncodeToString(png) + `">`
+	for _, name := range []string{"book_chunk_1.html", indexFileName, searchPageFileName} {
+		content, err := os.ReadFile(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		// 属性值中的 + 等字符可能以字符实体写出
+		if !strings.Contains(html.UnescapeString(string(content)), want) {
+			t.Errorf("%s 中没有嵌入的图标 %s", name, want)
+		}
+	}
+
+	if err := run([]string{"-quiet", "-minimal", "-out", "default", input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(dir, "default", "book_chunk_1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(html.UnescapeString(string(content)), `<link rel="icon" href="`+string(txt2html.DefaultFavicon)+`">`) {
+		t.Error("未指定 -favicon 时页面中没有默认图标")
+	}
+
+	if err := run([]string{"-quiet", "-favicon", input, "-out", "bad", input}); err == nil || !strings.Contains(err.Error(), "不是可识别的图片") {
+		t.Errorf("以文本文件作为图标: %v，期望“不是可识别的图片”", err)
+	}
+}
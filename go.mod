@@ -1,5 +1,8 @@
 module txt2html
 
-go 1.18
+go 1.23.0
 
-require golang.org/x/text v0.27.0
+require (
+	github.com/yuin/goldmark v1.8.6
+	golang.org/x/text v0.27.0
+)
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const gzipSuffix = ".gz" // 预压缩副本的扩展名，也是可以直接读取的压缩输入的扩展名
+
+var gzipMagic = []byte{0x1f, 0x8b} // gzip 数据开头的两个字节
+
+// 文件开头是否为 gzip 头，读取时不移动文件位置
+func isGzipFile(f *os.File) bool {
+	magic := make([]byte, len(gzipMagic))
+	n, _ := f.ReadAt(magic, 0)
+	return bytes.Equal(magic[:n], gzipMagic)
+}
+
+// 去掉压缩输入的 .gz 扩展名，book.txt.gz 的输出与 book.txt 相同
+func trimGzipSuffix(name string) string {
+	if strings.EqualFold(filepath.Ext(name), gzipSuffix) {
+		return name[:len(name)-len(gzipSuffix)]
+	}
+	return name
+}
+
+// 在 path 旁生成 gzip 预压缩副本 <path>.gz，供静态服务器按 Content-Encoding: gzip 直接返回。
+// 返回副本的字节数
+func gzipFile(path string) (int64, error) {
+	src, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer src.Close()
+	info, err := src.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	dst, err := os.Create(path + gzipSuffix)
+	if err != nil {
+		return 0, err
+	}
+	counted := &countingWriter{w: dst}
+	zw, err := gzip.NewWriterLevel(counted, gzip.BestCompression)
+	if err != nil {
+		dst.Close()
+		return 0, err
+	}
+	zw.Name = filepath.Base(path)
+	zw.ModTime = info.ModTime()
+	if _, err := io.Copy(zw, src); err != nil {
+		zw.Close()
+		dst.Close()
+		return 0, err
+	}
+	if err := zw.Close(); err != nil {
+		dst.Close()
+		return 0, err
+	}
+	return counted.n, dst.Close()
+}
+
+// 统计输出目录中 files 及其预压缩副本的总字节数，用于目录页等分块以外的文件
+func gzipTotals(dir string, files []string) (original, compressed int64) {
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		original += getFileSize(path)
+		compressed += getFileSize(path + gzipSuffix)
+	}
+	return original, compressed
+}
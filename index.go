@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bytes"
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"txt2html/pkg/txt2html"
+)
+
+const indexFileName = "index.html" // 目录页文件名
+
+// 目录页中单个分块的条目
+type IndexEntry struct {
+	ChunkNumber int
+	FileName    string
+	SizeKB      float64
+}
+
+// 目录页模板数据结构
+type IndexData struct {
+	FileName        string // 输入文件名，用于读取分块页面保存的阅读位置
+	Title           string // 显示的书名
+	TotalChunks     int
+	Entries         []IndexEntry
+	TOC             template.HTML // 章节目录，未检测到章节时为空
+	CenterMaxWidth  int           // 中央内容区最大宽度（px），与分块页面一致
+	SearchPage      string        // 全书搜索页面的文件名，未生成时为空
+	UILang          string        // 界面文字的语言，与分块页面一致
+	SolidBackground bool          // 纯色背景，与分块页面一致
+	Favicon         template.URL  // 页面图标，与分块页面一致
+}
+
+// 目录页模板中使用的界面文字
+func (d IndexData) Label(key string) string {
+	return txt2html.UILabel(d.UILang, key)
+}
+
+// 章节目录中单个标题的条目，下级标题（卷下的章、章下的节）放在 Children 中
+type tocEntry struct {
+	Text     string
+	Link     string
+	Depth    int // 在目录树中的深度，从1开始
+	Level    int // 标题层级，用于确定上下级关系
+	Children []*tocEntry
+}
+
+// 章节目录模板数据
+type tocData struct {
+	Entries []*tocEntry
+	Depths  []int  // 目录树的各级深度，超过一级时显示按级折叠的按钮
+	UILang  string // 界面文字的语言
+}
+
+// 章节目录模板中使用的界面文字
+func (d tocData) Label(key string) string {
+	return txt2html.UILabel(d.UILang, key)
+}
+
+// 目录页模板 - 与分块页面使用相同的配色变量和居中布局
+const indexTemplate = `<!DOCTYPE html>
+<html lang="{{.Label "lang"}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}} - {{.Label "index"}}</title>
+    {{if .Favicon}}<link rel="icon" href="{{.Favicon}}">{{end}}
+    <style>
+        :root {
+            --left-bg: #f5f5f5;   /* 左侧默认背景 */
+            --center-bg: #ffffff; /* 中央内容背景 */
+            --right-bg: #f5f5f5;  /* 右侧默认背景 */
+            --center-max-width: {{.CenterMaxWidth}}px;
+        }
+        body {
+            --g-left: calc(50% - var(--center-max-width) / 2);
+            --g-right: calc(50% + var(--center-max-width) / 2);
+            {{if .SolidBackground}}background: var(--left-bg);{{else}}background: linear-gradient(to right,
+                        var(--left-bg) 0px var(--g-left),
+                        var(--center-bg) var(--g-left) var(--g-right),
+                        var(--right-bg) var(--g-right) 100%);{{end}}
+            color: #333;
+            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+            padding: 20px;
+            margin: 0;
+            font-size: 16px;
+        }
+        .page-center {
+            max-width: var(--center-max-width);
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .content {
+            padding: 25px;
+            border-radius: 8px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            line-height: 1.6;
+            background-color: var(--center-bg);
+        }
+        .chunk-list {
+            list-style: none;
+            margin: 0;
+            padding: 0;
+        }
+        .chunk-list li {
+            display: flex;
+            justify-content: space-between;
+            padding: 8px 0;
+            border-bottom: 1px solid #eee;
+        }
+        .chunk-list a {
+            color: #0066cc;
+            text-decoration: none;
+        }
+        .chunk-list a:hover {
+            text-decoration: underline;
+        }
+        .chunk-size {
+            color: #666;
+            font-size: 0.9em;
+        }
+        .resume-link {
+            display: inline-block;
+            padding: 8px 16px;
+            border-radius: 4px;
+            background: #0066cc;
+            color: white;
+            text-decoration: none;
+        }
+        .toc {
+            margin: 0 0 20px 0;
+            padding-left: 1.5em;
+        }
+        .toc li {
+            padding: 4px 0;
+        }
+        .toc a {
+            color: #0066cc;
+            text-decoration: none;
+        }
+        .toc a:hover {
+            text-decoration: underline;
+        }
+        .toc .toc {
+            margin: 4px 0 0 0;
+        }
+        .toc summary {
+            cursor: pointer;
+        }
+        .toc-levels {
+            margin-bottom: 10px;
+        }
+        .toc-levels button {
+            padding: 4px 10px;
+            margin-right: 6px;
+            border: none;
+            border-radius: 4px;
+            background: #e0e0e0;
+            cursor: pointer;
+        }
+    </style>
+</head>
+<body>
+    <div class="page-center">
+        <div class="content">
+            <h1>{{.Title}}</h1>
+            <p class="chunk-size">{{printf (.Label "partCount") .TotalChunks}}{{if .SearchPage}} · <a href="{{.SearchPage}}">{{.Label "searchBook"}}</a>{{end}}</p>
+            <p id="resumeReading" hidden><a id="resumeLink" class="resume-link" href="">{{.Label "resumeReading"}}</a></p>
+            {{if .TOC}}
+            <h2>{{.Label "chapters"}}</h2>
+            {{.TOC}}
+            <h2>{{.Label "parts"}}</h2>
+            {{end}}
+            <ul class="chunk-list">
+                {{range .Entries}}
+                <li>
+                    <a href="{{.FileName}}">{{printf ($.Label "part") .ChunkNumber}}</a>
+                    <span class="chunk-size">{{printf ($.Label "partSize") .SizeKB}}</span>
+                </li>
+                {{end}}
+            </ul>
+        </div>
+    </div>
+    <script>
+        // 目录按级折叠：只展开深度小于 depth 的条目
+        function showTOCDepth(depth) {
+            document.querySelectorAll('.toc details').forEach(function(d) {
+                d.open = Number(d.dataset.depth) < depth;
+            });
+        }
+
+        // 继续阅读：分块页面记录的上次阅读位置，只在对应的分块仍在列表中时显示
+        try {
+            const position = JSON.parse(localStorage.getItem('txt2html-position:' + {{.FileName}}));
+            const link = position && Array.prototype.find.call(document.querySelectorAll('.chunk-list a'), function(a) {
+                return a.getAttribute('href') === position.chunkFile;
+            });
+            if (link) {
+                const resumeLink = document.getElementById('resumeLink');
+                resumeLink.href = position.chunkFile + '#resume';
+                resumeLink.textContent = {{.Label "resumeReadingAt"}}.replace('%s', link.textContent + ' · ' + Math.round((Number(position.scrollRatio) || 0) * 100) + '%');
+                document.getElementById('resumeReading').hidden = false;
+            }
+        } catch (e) {
+            // 本地存储不可用时不显示
+        }
+    </script>
+</body>
+</html>`
+
+// 章节目录模板片段：有下级标题的条目用 <details> 包裹，可单独折叠
+const tocTemplate = `{{if gt (len .Depths) 1}}<div class="toc-levels">
+{{range .Depths}}<button onclick="showTOCDepth({{.}})">{{printf ($.Label "tocDepth") .}}</button>
+{{end}}</div>
+{{end}}{{template "tocList" .Entries}}
+{{define "tocList"}}<ul class="toc">
+{{range .}}<li>{{if .Children}}<details open data-depth="{{.Depth}}"><summary><a href="{{.Link}}">{{.Text}}</a></summary>
+{{template "tocList" .Children}}</details>{{else}}<a href="{{.Link}}">{{.Text}}</a>{{end}}</li>
+{{end}}</ul>{{end}}`
+
+// 启动时解析一次的目录页模板
+var (
+	indexTmpl = template.Must(template.New("indexTemplate").Parse(indexTemplate))
+	tocTmpl   = template.Must(template.New("tocTemplate").Parse(tocTemplate))
+)
+
+// 根据检测到的章节标题生成目录HTML片段，每个标题链接到所在分块文件内的锚点
+func generateTOC(data []txt2html.TemplateData, headings []txt2html.ChapterHeading) (template.HTML, error) {
+	if len(headings) == 0 {
+		return "", nil
+	}
+
+	toc := tocData{UILang: data[len(data)-1].UILang}
+	entries, maxDepth := buildTOCTree(data, headings)
+	toc.Entries = entries
+	for depth := 1; depth <= maxDepth; depth++ {
+		toc.Depths = append(toc.Depths, depth)
+	}
+
+	var buf bytes.Buffer
+	if err := tocTmpl.Execute(&buf, toc); err != nil {
+		return "", err
+	}
+	return template.HTML(buf.String()), nil
+}
+
+// 把章节标题按层级组织成目录树，返回顶层条目和树的最大深度。
+// 每个标题挂在它前面最近的一个更高层级的标题下，没有则作为顶层条目
+func buildTOCTree(data []txt2html.TemplateData, headings []txt2html.ChapterHeading) ([]*tocEntry, int) {
+	var entries []*tocEntry
+	var stack []*tocEntry // 当前路径上从顶层到最近一个标题的条目
+	maxDepth := 0
+	for _, h := range headings {
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		entry := &tocEntry{
+			Text:  h.Text,
+			Link:  data[h.Chunk-1].OutputFile + "#" + h.Anchor,
+			Depth: len(stack) + 1,
+			Level: h.Level,
+		}
+		if len(stack) == 0 {
+			entries = append(entries, entry)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, entry)
+		}
+		stack = append(stack, entry)
+		maxDepth = max(maxDepth, entry.Depth)
+	}
+	return entries, maxDepth
+}
+
+// 在输出目录中生成 index.html，列出章节目录以及所有分块文件的链接和大致大小，sizes 为各块的字节数
+func generateIndex(outputDir string, data []txt2html.TemplateData, sizes []int64, headings []txt2html.ChapterHeading) error {
+	// 全书的设置取自最后一块：追加时前面已有的块只有进度中记录的字段
+	last := data[len(data)-1]
+	index := IndexData{
+		TotalChunks:     len(data),
+		FileName:        last.FileName,
+		Title:           last.Title,
+		CenterMaxWidth:  last.CenterMaxWidth,
+		SearchPage:      last.SearchPage,
+		UILang:          last.UILang,
+		SolidBackground: last.SolidBackground,
+		Favicon:         last.Favicon,
+	}
+	for i, d := range data {
+		index.Entries = append(index.Entries, IndexEntry{
+			ChunkNumber: d.CurrentChunk,
+			FileName:    d.OutputFile,
+			SizeKB:      float64(sizes[i]) / 1024,
+		})
+	}
+
+	toc, err := generateTOC(data, headings)
+	if err != nil {
+		return err
+	}
+	index.TOC = toc
+
+	outputFile, err := os.Create(filepath.Join(outputDir, indexFileName))
+	if err != nil {
+		return err
+	}
+	if err := indexTmpl.Execute(outputFile, index); err != nil {
+		outputFile.Close()
+		return err
+	}
+	return outputFile.Close()
+}
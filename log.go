@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 命令行输出使用的 slog 处理器：普通信息只输出消息本身，与进度提示的格式一致；
+// 调试和警告信息加上级别前缀，附带的属性以 key=value 的形式跟在消息后面。
+// 警告和错误写入 errOut，其余写入 out
+type consoleHandler struct {
+	out    io.Writer
+	errOut io.Writer
+	level  slog.Level
+	mu     *sync.Mutex // 并行生成分块时多个 goroutine 同时输出，逐行加锁避免交错
+	attrs  []slog.Attr
+	group  string // WithGroup 设置的属性名前缀
+}
+
+func newConsoleLogger(out, errOut io.Writer, level slog.Level) *slog.Logger {
+	return slog.New(&consoleHandler{out: out, errOut: errOut, level: level, mu: &sync.Mutex{}})
+}
+
+func (h *consoleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= h.level
+}
+
+func (h *consoleHandler) Handle(_ context.Context, r slog.Record) error {
+	var b strings.Builder
+	switch {
+	case r.Level >= slog.LevelError:
+		b.WriteString("错误: ")
+	case r.Level >= slog.LevelWarn:
+		b.WriteString("警告: ")
+	case r.Level < slog.LevelInfo:
+		b.WriteString("调试: ")
+	}
+	b.WriteString(r.Message)
+	for _, a := range h.attrs {
+		writeAttr(&b, "", a)
+	}
+	r.Attrs(func(a slog.Attr) bool {
+		writeAttr(&b, h.group, a)
+		return true
+	})
+	b.WriteByte('\n')
+
+	w := h.out
+	if r.Level >= slog.LevelWarn {
+		w = h.errOut
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func (h *consoleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	h2 := *h
+	for _, a := range attrs {
+		if h.group != "" {
+			a.Key = h.group + a.Key
+		}
+		h2.attrs = append(h2.attrs[:len(h2.attrs):len(h2.attrs)], a)
+	}
+	return &h2
+}
+
+func (h *consoleHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	h2 := *h
+	h2.group = h.group + name + "."
+	return &h2
+}
+
+// 以 " key=value" 的形式写出一个属性，含空白或引号的值加引号
+func writeAttr(b *strings.Builder, prefix string, a slog.Attr) {
+	a.Value = a.Value.Resolve()
+	if a.Equal(slog.Attr{}) {
+		return
+	}
+	if a.Value.Kind() == slog.KindGroup {
+		for _, ga := range a.Value.Group() {
+			writeAttr(b, prefix+a.Key+".", ga)
+		}
+		return
+	}
+	value := a.Value.String()
+	if strings.ContainsAny(value, " \t\n\"=") || value == "" {
+		value = strconv.Quote(value)
+	}
+	fmt.Fprintf(b, " %s%s=%s", prefix, a.Key, value)
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+
+	"txt2html/pkg/txt2html"
+)
+
+const manifestFileName = "manifest.json" // 分块清单文件名
+
+// manifest.json 中单个分块的描述，字段名即对外约定，修改时需保持兼容
+type ChunkInfo struct {
+	File      string `json:"file"`               // 分块文件名
+	Chunk     int    `json:"chunk"`              // 块序号，从1开始
+	Size      int64  `json:"size"`               // 文件字节数
+	Chapter   string `json:"chapter,omitempty"`  // 本块开头处所在的章节，未检测到章节时省略
+	CharCount int    `json:"charCount"`          // 正文字符数（按 Unicode 字符计）
+	GzipFile  string `json:"gzipFile,omitempty"` // 预压缩副本的文件名，未使用 -gzip 时省略
+	GzipSize  int64  `json:"gzipSize,omitempty"` // 预压缩副本的字节数
+}
+
+// 在输出目录中生成 manifest.json，供其他程序读取分块信息而无需解析HTML。
+// sizes 中有预压缩副本的大小时同时列出副本
+func generateManifest(outputDir string, data []txt2html.TemplateData, stats []txt2html.ChunkStats, sizes chunkSizes) error {
+	infos := make([]ChunkInfo, 0, len(data))
+	for i, d := range data {
+		info := ChunkInfo{
+			File:      d.OutputFile,
+			Chunk:     d.CurrentChunk,
+			Size:      sizes.html[i],
+			Chapter:   stats[i].Chapter,
+			CharCount: d.CharCount,
+		}
+		if sizes.gzip != nil {
+			info.GzipFile = d.OutputFile + gzipSuffix
+			info.GzipSize = sizes.gzip[i]
+		}
+		infos = append(infos, info)
+	}
+
+	var buf bytes.Buffer
+	encoder := json.NewEncoder(&buf)
+	encoder.SetEscapeHTML(false) // 章节标题原样输出，不转义 < > &
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(infos); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(outputDir, manifestFileName), buf.Bytes(), 0644)
+}
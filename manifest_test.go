@@ -0,0 +1,40 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// 清单中的大小取自渲染时写出的字节数，与磁盘上的分块文件和预压缩副本一致
+func TestConvertManifestSizes(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("一\n二\n三\n四\n五\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	if err := run([]string{"-quiet", "-gzip", "-lines", "2", "-out", out, input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	content, err := os.ReadFile(filepath.Join(out, manifestFileName))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var infos []ChunkInfo
+	if err := json.Unmarshal(content, &infos); err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 3 {
+		t.Fatalf("清单中有 %d 块，期望 3 块", len(infos))
+	}
+	for _, info := range infos {
+		if size := getFileSize(filepath.Join(out, info.File)); info.Size != size || size == 0 {
+			t.Errorf("%s: 清单中的大小 %d，文件为 %d 字节", info.File, info.Size, size)
+		}
+		if size := getFileSize(filepath.Join(out, info.GzipFile)); info.GzipSize != size || size == 0 {
+			t.Errorf("%s: 清单中的大小 %d，文件为 %d 字节", info.GzipFile, info.GzipSize, size)
+		}
+	}
+}
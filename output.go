@@ -0,0 +1,61 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+const outputMarkerFileName = ".txt2html-source" // 记录输出目录是为哪个输入生成的，重新转换时据此判断能否清空
+
+const stdinSource = "-" // 标准输入在输出目录记录中的标识
+
+// 输出目录记录的输入标识：输入文件为其绝对路径，标准输入为 stdinSource
+func sourceID(opts *options) string {
+	if opts.stdin {
+		return stdinSource
+	}
+	if abs, err := filepath.Abs(opts.inputPath); err == nil {
+		return abs
+	}
+	return opts.inputPath
+}
+
+// 默认输出目录名中区分输入的短哈希，取自输入的绝对路径，同一个文件每次得到的结果相同
+func sourceHash(source string) string {
+	sum := sha256.Sum256([]byte(source))
+	return hex.EncodeToString(sum[:4])
+}
+
+// 删除输出目录 dir 以便重新生成。只删除不存在、为空或由本程序为同一输入 source 生成的目录，
+// 其他目录可能是另一个同名输入的结果或无关的文件，需要 force 才会删除
+func cleanOutputDir(dir, source string, force bool) error {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("无法清理输出目录 %s: %w", dir, err)
+	}
+	if len(entries) > 0 && !force {
+		owner, err := os.ReadFile(filepath.Join(dir, outputMarkerFileName))
+		if err != nil {
+			return fmt.Errorf("输出目录 %s 已存在且不是本程序生成的，为避免误删请用 -out 指定其他目录，或使用 -force 覆盖", dir)
+		}
+		if strings.TrimSpace(string(owner)) != source {
+			return fmt.Errorf("输出目录 %s 是为 %s 生成的，为避免覆盖请用 -out 指定其他目录，或使用 -force 覆盖", dir, strings.TrimSpace(string(owner)))
+		}
+	}
+	if err := os.RemoveAll(dir); err != nil {
+		return fmt.Errorf("无法清理输出目录 %s: %w", dir, err)
+	}
+	return nil
+}
+
+// 在输出目录中记录它是为哪个输入生成的
+func writeOutputMarker(dir, source string) error {
+	return os.WriteFile(filepath.Join(dir, outputMarkerFileName), []byte(source+"\n"), 0644)
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 在临时目录中运行，默认输出目录生成在其中，结束后恢复原来的工作目录
+func chdirTemp(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+	return dir
+}
+
+// 不同目录中的同名文件得到不同的默认输出目录，各自的结果互不覆盖，重新转换同一文件可以覆盖自己的输出
+func TestConvertSameNameInputs(t *testing.T) {
+	dir := chdirTemp(t)
+	inputs := map[string]string{"a": "甲文件的内容\n", "b": "乙文件的内容\n"}
+	var paths []string
+	for sub, content := range inputs {
+		path := filepath.Join(dir, sub, "book.txt")
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		paths = append(paths, path)
+	}
+	for _, path := range append(paths, paths[0]) {
+		if err := run([]string{"-quiet", path}); err != nil {
+			t.Fatalf("转换 %s: %v", path, err)
+		}
+	}
+
+	outputs, err := filepath.Glob(filepath.Join(dir, "book.txt_*_html_chunks"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(outputs) != 2 {
+		t.Fatalf("得到输出目录 %v，期望两个", outputs)
+	}
+	for _, path := range paths {
+		output := defaultOutputPath("book.txt", formatHTML, false, false, path)
+		page, err := os.ReadFile(filepath.Join(dir, output, "book_chunk_1.html"))
+		if err != nil {
+			t.Fatalf("读取 %s 的输出: %v", path, err)
+		}
+		want := inputs[filepath.Base(filepath.Dir(path))]
+		if !strings.Contains(string(page), strings.TrimSpace(want)) {
+			t.Errorf("%s 的输出中没有 %q", output, want)
+		}
+	}
+}
+
+// 已存在且不是为同一输入生成的目录不会被删除，除非 force
+func TestCleanOutputDir(t *testing.T) {
+	dir := t.TempDir()
+	foreign := filepath.Join(dir, "foreign")
+	other := filepath.Join(dir, "other")
+	for _, d := range []string{foreign, other} {
+		if err := os.MkdirAll(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(d, "keep.txt"), []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writeOutputMarker(other, "/other/book.txt"); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, d := range []string{foreign, other} {
+		if err := cleanOutputDir(d, "/src/book.txt", false); err == nil {
+			t.Errorf("清理 %s 成功，期望拒绝", filepath.Base(d))
+		}
+		if _, err := os.Stat(filepath.Join(d, "keep.txt")); err != nil {
+			t.Errorf("%s 中的文件被删除: %v", filepath.Base(d), err)
+		}
+	}
+	if err := cleanOutputDir(other, "/other/book.txt", false); err != nil {
+		t.Errorf("清理为同一输入生成的目录: %v", err)
+	}
+	if err := cleanOutputDir(foreign, "/src/book.txt", true); err != nil {
+		t.Errorf("force 时清理: %v", err)
+	}
+	for _, d := range []string{foreign, other} {
+		if _, err := os.Stat(d); !os.IsNotExist(err) {
+			t.Errorf("%s 仍然存在", filepath.Base(d))
+		}
+	}
+	if err := cleanOutputDir(filepath.Join(dir, "missing"), "/src/book.txt", false); err != nil {
+		t.Errorf("清理不存在的目录: %v", err)
+	}
+}
+
+// -dry-run 只报告切分结果，不创建输出目录，也不清理已存在的目录
+func TestConvertDryRun(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("第一章 开始\n内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-quiet", "-dry-run", input}); err != nil {
+		t.Fatalf("试运行: %v", err)
+	}
+	if _, err := os.Stat(defaultOutputPath("book.txt", formatHTML, false, false, input)); !os.IsNotExist(err) {
+		t.Errorf("试运行创建了输出目录: %v", err)
+	}
+
+	existing := filepath.Join(dir, "out")
+	if err := os.MkdirAll(existing, 0755); err != nil {
+		t.Fatal(err)
+	}
+	kept := filepath.Join(existing, "keep.txt")
+	if err := os.WriteFile(kept, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-quiet", "-dry-run", "-out", existing, input}); err != nil {
+		t.Fatalf("试运行: %v", err)
+	}
+	if entries, _ := os.ReadDir(existing); len(entries) != 1 {
+		t.Errorf("试运行修改了已存在的输出目录，其中有 %d 项", len(entries))
+	}
+}
+
+// -ui-lang en 时目录页和搜索页面也使用英文界面
+func TestConvertUILang(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("第一章 开始\n内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-quiet", "-ui-lang", "en", "-out", "out", input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	for name, want := range map[string]string{
+		indexFileName:      "<title>book.txt - Contents</title>",
+		searchPageFileName: `placeholder="Enter text to find"`,
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, "out", name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(content), want) || !strings.Contains(string(content), `<html lang="en">`) {
+			t.Errorf("%s 没有使用英文界面:\n%s", name, content)
+		}
+	}
+}
@@ -0,0 +1,514 @@
+// Package txt2html 把纯文本或 Markdown 按大小切分为一组互相链接的HTML页面。
+//
+// 命令行工具 txt2html 在此基础上生成目录页、清单、搜索页面和 EPUB；
+// 其他程序可以直接使用 Converter，把分块写到任意 io.Writer。
+package txt2html
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+const (
+	DefaultTargetSize     = 1024 * 1024      // 默认的每块HTML目标大小：1MB
+	DefaultMaxLineSize    = 16 * 1024 * 1024 // 默认允许的单行最大长度
+	DefaultCenterMaxWidth = 1000             // 默认中央内容区最大宽度（px）
+	DefaultTabWidth       = 4                // 默认制表符宽度（字符）
+	readBufferSize        = 4096             // 读取缓冲区初始大小
+	minChunkContent       = 1024             // 每块除页面模板外至少要能容纳的正文字节数
+)
+
+// Converter.Logger 为 nil 时使用，不输出任何内容
+var discardLogger = slog.New(slog.NewTextHandler(io.Discard, &slog.HandlerOptions{Level: slog.Level(math.MaxInt32)}))
+
+// 输入中没有任何内容（0 字节，或只有 BOM）时 Split 返回的错误，此时不生成任何分块
+var ErrEmpty = errors.New("输入为空")
+
+// TargetSize 小于页面模板本身的大小，每块几乎放不下正文，会生成大量文件
+var ErrTargetTooSmall = errors.New("目标大小太小")
+
+// 转换参数，零值字段使用默认值
+type Converter struct {
+	FileName        string             // 输入文件名，用于生成分块文件名
+	Title           string             // 页面中显示的书名，为空时使用 FileName
+	Encoding        string             // 输入编码，为空时为 utf-8，AutoEncoding 时根据开头内容检测
+	AllowBinary     bool               // 不检查输入是否为文本，否则看起来是二进制文件时返回 ErrBinary
+	TargetSize      int                // 每块HTML的目标大小（字节），为0时为 DefaultTargetSize
+	LinesPerChunk   int                // 每块的行数，不为0时按行数分块，忽略 TargetSize；章节标题仍从新的一块开始
+	Overlap         int                // 每块开头重复上一块的最后几行（Markdown 为几个段落），淡色显示，计入块的大小；章节标题开始的块不重复
+	RevealLines     int                // 每块先显示的行数（Markdown 按段落所占的行数），其后每这么多行为一段，读者滚动接近时才显示，很大的块也能很快显示出来；0 表示整块一次显示，只对内置的完整页面生效
+	MaxChunks       int                // 最多生成的块数，达到后其余内容都放入最后一块（因而可以超过 TargetSize），0 表示不限
+	FirstChunkOnly  bool               // 只切分出第一块，写满后立即停止读取，用于快速预览；此时 Book 中只有一块
+	OutputDir       string             // Convert 未指定输出时写入分块文件的目录
+	NamePattern     NamePattern        // 分块文件名模板，零值为 DefaultNamePattern
+	RawNames        bool               // 文件名模板中的 {base} 保留 FileName 原样，否则经 SafeFileName 处理
+	ReservedNames   []string           // 同一目录中的其他输出文件，分块文件名不能与它们相同
+	HeadingRules    []HeadingRule      // 卷、章、节标题匹配规则，为空时不检测章节
+	SplitMarker     string             // 分块标记：与之完全相同的行（Markdown 中须单独成段）总是开始新的一块，该行及其后的空行不输出；为空时不检测
+	Markdown        bool               // 按 Markdown 渲染正文
+	Layout          Layout             // 分块页面布局，零值为 LayoutFull
+	SharedAssets    bool               // 完整页面引用共用的样式表和脚本文件，而不是每页内联一份，见 WriteReaderAssets
+	Minify          bool               // 去掉内置页面模板、样式和脚本中的缩进、空行和整行注释，正文不受影响，分块大小按压缩后计算；对自定义模板无效
+	Template        *template.Template // 自定义分块页面模板，不为 nil 时代替 Layout 的内置模板，见 ParseTemplateFile
+	CenterMaxWidth  int                // 中央内容区最大宽度（px），为0时为 DefaultCenterMaxWidth
+	Header          template.HTML      // 每页正文上方的页眉，纯文本需由调用方转义
+	Provenance      *Provenance        // 在设置面板中显示的来源和转换记录，为 nil 时不显示
+	Footer          template.HTML      // 每页正文下方的页脚，纯文本需由调用方转义
+	Columns         int                // 正文分栏数，为0时不分栏；完整页面中读者还可以自行调整
+	TabWidth        int                // 制表符宽度（字符），页面中以 CSS tab-size 显示，为0时为 DefaultTabWidth
+	ExpandTabs      bool               // 切分时把纯文本中的制表符展开为 TabWidth 对齐的空格，用于不支持 tab-size 的阅读环境
+	Justify         bool               // 正文默认两端对齐
+	FirstLineIndent bool               // 每段首行缩进两个字：纯文本在每段第一行开头插入缩进标记（去掉原有的行首空白），Markdown 缩进每个段落
+	LineParagraphs  bool               // 纯文本每个非空行是一段（段落之间没有空行的文本），每段前插入可调整的段落间距；否则由空行分隔段落，段内的换行只是换行
+	SolidBackground bool               // 完整页面的背景使用左侧背景色的纯色，不在两侧使用渐变
+	Theme           string             // 完整页面默认的阅读主题，为 ReaderThemes 之一，为空时为第一个
+	UILang          string             // 页面界面文字的语言，为 UILanguages 之一，为空时为第一个（中文）
+	Favicon         template.URL       // 页面图标，一般为图片的 data URI，为空时为 DefaultFavicon；EPUB 页面中不使用
+	AnchorLines     int                // 每隔多少行插入一个行号锚点，0 表示不插入
+	LineNumbers     bool               // 在每行前显示它在整个输入中的行号，只对纯文本生效
+	WrapAt          int                // 把超过该字符数的行折成多行，折出的每行各为一个切分单位，按行数分块和搜索都按折后的行计算，行号仍为原文的行号；0 表示不折行，只对纯文本生效
+	MaxBlankLines   int                // 连续空行最多保留的行数，多余的丢弃，0 表示全部保留；只对纯文本生效
+	MaxLineSize     int                // 单行最大字节数，超过时读取失败，为0时为 DefaultMaxLineSize
+	Logger          *slog.Logger       // 以 Debug 级别记录编码、分块位置、检测到的标题和解码错误，为 nil 时不记录
+	DecodeMarker    string             // 非空时把无法解码的字节显示为该标记（如 "[?]"），为空时保留 U+FFFD
+	Sanitize        bool               // 去掉正文中除制表符外的控制字符（NUL、换页符等），数量见 Book.ControlChars
+	SearchPage      string             // 分块页面中“全书搜索”链接指向的文件名，为空时不显示
+	IndexPage       string             // 分块页面中“目录”按钮指向的目录页，相对于分块文件所在的目录，为空时不显示
+	SearchIndex     bool               // 切分时同时生成全书搜索索引，用 Book.WriteSearchIndex 输出
+	Resume          Resume             // 接着上一次转换的结果继续编号，零值表示从头开始
+}
+
+// 追加转换的起点：输入是上一次转换之后新增的内容，新块接在已有的块后面编号
+type Resume struct {
+	Chunks      []string  // 已有各块的文件名，第一个新块的上一页链接到最后一个已有块
+	Lines       int       // 已处理的行数，新内容的行号从 Lines+1 开始
+	Headings    int       // 已有的章节标题数，新标题的锚点ID接着编号
+	SearchIndex io.Reader // 上一次输出的搜索索引脚本，新的索引在其后追加，为 nil 时只含新内容
+}
+
+// 切分完成的一本书。正文暂存在临时目录中，用完后必须调用 Close
+type Book struct {
+	Encoding     string           // 实际使用的输入编码，自动检测时为检测结果
+	Chunks       []TemplateData   // 每块的页面数据（不含正文），追加转换时只含新块
+	Headings     []ChapterHeading // 检测到的章节标题
+	Stats        []ChunkStats     // 每块的统计信息，与 Chunks 一一对应
+	Lines        int              // 读取到的最后一行的行号（追加转换时包括已处理的行）
+	DecodeErrors int              // 无法按输入编码解码、被替换为 U+FFFD 或 DecodeMarker 的字符数
+	ControlChars int              // 正文中除制表符外的控制字符数，开启 Sanitize 时这些字符已被去掉
+	Truncated    bool             // 开启 FirstChunkOnly 时第一块之后还有未读取的内容
+
+	tmpl   pageTemplate
+	resume Resume
+	spool  *chunkSpool
+	search *searchIndex
+}
+
+// 读取 r 的全部内容并切分，每块写满后立即暂存到磁盘，内存中只保留当前这一块
+func (c *Converter) Split(r io.Reader) (*Book, error) {
+	if !c.Layout.valid() {
+		return nil, fmt.Errorf("无效的页面布局: %d", c.Layout)
+	}
+	if c.Theme != "" && !slices.Contains(ReaderThemes, c.Theme) {
+		return nil, fmt.Errorf("未知的阅读主题: %s（可用: %s）", c.Theme, strings.Join(ReaderThemes, "、"))
+	}
+	if c.UILang != "" && !slices.Contains(UILanguages, c.UILang) {
+		return nil, fmt.Errorf("未知的界面语言: %s（可用: %s）", c.UILang, strings.Join(UILanguages, "、"))
+	}
+	// 统一为规范名称，Book.Encoding 和之后按名称的判断都不受大小写等写法影响
+	encodingName := "utf-8"
+	if c.Encoding != "" {
+		if encodingName = CanonicalEncoding(c.Encoding); encodingName == "" {
+			return nil, fmt.Errorf("不支持的编码: %s", c.Encoding)
+		}
+	}
+	// 自动检测编码：预读输入开头的一段用于判断，预读的内容仍会被后续读取
+	detected := encodingName == AutoEncoding
+	if detected {
+		buffered := bufio.NewReaderSize(r, detectSampleSize)
+		sample, err := buffered.Peek(detectSampleSize)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("读取输入失败: %w", err)
+		}
+		encodingName = detectEncoding(sample, err == nil)
+		r = buffered
+	}
+	log := c.Logger
+	if log == nil {
+		log = discardLogger
+	}
+	log.Debug("输入编码", "encoding", encodingName, "detected", detected)
+	decoder := LookupEncoding(encodingName)
+	// 同样只预读开头的一段，自动检测时的缓冲区足够大，会直接复用
+	if !c.AllowBinary {
+		buffered := bufio.NewReaderSize(r, binarySampleSize)
+		sample, err := buffered.Peek(binarySampleSize)
+		if err != nil && err != io.EOF {
+			return nil, fmt.Errorf("读取输入失败: %w", err)
+		}
+		if looksBinary(sample, encodingName, err == nil) {
+			return nil, fmt.Errorf("%w（含有空字节或大量无法按 %s 解码的内容）", ErrBinary, encodingName)
+		}
+		r = buffered
+	}
+
+	target := c.TargetSize
+	if target <= 0 {
+		target = DefaultTargetSize
+	}
+	maxLineSize := c.MaxLineSize
+	if maxLineSize <= 0 {
+		maxLineSize = DefaultMaxLineSize
+	}
+	width := c.CenterMaxWidth
+	if width <= 0 {
+		width = DefaultCenterMaxWidth
+	}
+	columns := max(c.Columns, 1)
+	tabWidth := c.TabWidth
+	if tabWidth <= 0 {
+		tabWidth = DefaultTabWidth
+	}
+
+	// 开头的 BOM 只用来标明编码，解码时去掉，否则会作为一个多余的字符出现在第一块开头。
+	// 有 BOM 时以 BOM 标明的编码为准
+	scanner := bufio.NewScanner(transform.NewReader(r, unicode.BOMOverride(decoder.NewDecoder())))
+	scanner.Buffer(make([]byte, readBufferSize), maxLineSize)
+	decodeErrs := &decodeErrors{marker: []byte(c.DecodeMarker), line: c.Resume.Lines, log: log}
+	controls := &controlChars{split: decodeErrs.scanLines, remove: c.Sanitize, line: c.Resume.Lines, log: log}
+	scanner.Split(controls.scanLines)
+	lines := &lineUnits{scanner: scanner, lineNo: c.Resume.Lines, maxBlank: c.MaxBlankLines}
+	if c.ExpandTabs {
+		lines.tabWidth = tabWidth
+	}
+	lines.wrapAt = c.WrapAt
+	var units unitSource = lines
+	if c.Markdown {
+		markdown := newMarkdownUnits(scanner)
+		markdown.lineNo = c.Resume.Lines
+		units = markdown
+	}
+
+	// 整本书共用的页面字段，每块在此基础上补充块序号、导航等信息
+	page := TemplateData{
+		FileName:        c.FileName,
+		Title:           c.Title,
+		CenterMaxWidth:  width,
+		Markdown:        c.Markdown,
+		SearchPage:      c.SearchPage,
+		IndexPage:       c.IndexPage,
+		Columns:         columns,
+		TabWidth:        tabWidth,
+		Justify:         c.Justify,
+		FirstLineIndent: c.FirstLineIndent,
+		Theme:           c.Theme,
+		SolidBackground: c.SolidBackground,
+		UILang:          c.UILang,
+		Favicon:         c.Favicon,
+		Header:          c.Header,
+		Footer:          c.Footer,
+		Provenance:      c.Provenance,
+		SharedAssets:    c.SharedAssets && c.Layout == LayoutFull && c.Template == nil,
+	}
+	if page.Title == "" {
+		page.Title = c.FileName
+	}
+	if page.Favicon == "" {
+		page.Favicon = DefaultFavicon
+	}
+	// 文件名中常有连续或首尾的空白，显示时合并为一个空格
+	page.Title = strings.Join(strings.Fields(page.Title), " ")
+	namePattern := c.NamePattern
+	namePattern.keepBase = c.RawNames
+	tmpl := pageTemplate{c.Layout, c.Template, c.Minify}
+	// 按大小分块时，先确认第一块除去页面模板后还能放下一定的正文
+	if c.LinesPerChunk <= 0 {
+		base := getBaseHTMLSize(tmpl, page, namePattern, len(c.Resume.Chunks)+1)
+		if target-base < minChunkContent {
+			return nil, fmt.Errorf("%w: 页面模板本身约 %d 字节，目标大小至少需要 %d 字节，当前为 %d 字节",
+				ErrTargetTooSmall, base, base+minChunkContent, target)
+		}
+	}
+	// 逐段显示依靠完整页面中的脚本，精简页面和自定义模板中的段会一直不显示
+	revealLines := 0
+	if c.Layout == LayoutFull && c.Template == nil {
+		revealLines = c.RevealLines
+	}
+	split, err := splitToSpool(units, splitConfig{
+		page:        page,
+		target:      target,
+		tmpl:        tmpl,
+		names:       namePattern,
+		rules:       c.HeadingRules,
+		withSearch:  c.SearchIndex,
+		anchors:     c.AnchorLines,
+		lineNumbers: c.LineNumbers,
+		indent:      c.FirstLineIndent && !c.Markdown,
+		lineParas:   c.LineParagraphs && !c.Markdown,
+		resume:      c.Resume,
+		maxChunks:   c.MaxChunks,
+		firstOnly:   c.FirstChunkOnly,
+		lineLimit:   c.LinesPerChunk,
+		overlap:     c.Overlap,
+		splitMarker: c.SplitMarker,
+		reveal:      revealLines,
+		log:         log,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if split.spool.count == 0 {
+		split.spool.remove()
+		return nil, ErrEmpty
+	}
+
+	existing := c.Resume.Chunks
+	first := len(existing) + 1
+	total := len(existing) + split.spool.count
+	names, err := namePattern.chunkNames(c.FileName, first, total, existing, c.ReservedNames)
+	if err != nil {
+		split.spool.remove()
+		return nil, err
+	}
+	names = append(existing[:len(existing):len(existing)], names...)
+	book := &Book{
+		Encoding:     encodingName,
+		Chunks:       make([]TemplateData, split.spool.count),
+		Headings:     split.headings,
+		Stats:        split.stats,
+		Lines:        split.lines,
+		DecodeErrors: decodeErrs.count,
+		ControlChars: controls.count,
+		Truncated:    split.truncated,
+		tmpl:         tmpl,
+		resume:       c.Resume,
+		spool:        split.spool,
+		search:       split.search,
+	}
+	for i := range book.Chunks {
+		chunk := first + i
+		data := page
+		data.TotalChunks = total
+		data.CurrentChunk = chunk
+		data.CharCount = split.stats[i].CharCount
+		data.WordCount = split.stats[i].WordCount
+		data.Chapter = split.stats[i].Chapter
+		data.ReadingMinutes = readingMinutes(data.WordCount)
+		data.OutputFile = names[chunk-1]
+		data.FilePattern = namePattern.chunkPattern(c.FileName, total)
+		if chunk > 1 {
+			data.PrevFile = names[chunk-2]
+		}
+		if chunk < total {
+			data.NextFile = names[chunk]
+		}
+		book.Chunks[i] = data
+	}
+	return book, nil
+}
+
+// 按尽量相同的大小切分，避免按 TargetSize 装满前面各块后剩下很小的最后一块：
+// 先按 TargetSize 切分得到最少的块数，再找出仍能切成这么多块的最小目标大小重新切分。
+// 每次切分都要完整读取一遍输入，open 每次返回从头开始的输入。
+// 按行数分块、设置了 MaxChunks 或 FirstChunkOnly 时与 Split 相同
+func (c *Converter) SplitBalanced(open func() (io.Reader, error)) (*Book, error) {
+	r, err := open()
+	if err != nil {
+		return nil, err
+	}
+	book, err := c.Split(r)
+	if err != nil || len(book.Chunks) <= 1 || c.LinesPerChunk > 0 || c.MaxChunks > 0 || c.FirstChunkOnly {
+		return book, err
+	}
+	log := c.Logger
+	if log == nil {
+		log = discardLogger
+	}
+
+	target := c.TargetSize
+	if target <= 0 {
+		target = DefaultTargetSize
+	}
+	// n 块的总大小不超过 n 个目标大小，因此目标大小至少为平均大小，从这里开始二分查找
+	n := len(book.Chunks)
+	var total byteCounter
+	for _, data := range book.Chunks {
+		if err := book.Render(data.CurrentChunk, &total); err != nil {
+			book.Close()
+			return nil, err
+		}
+	}
+	// 之后的切分只为找到合适的目标大小，不再重复输出编码、标题等调试信息
+	quiet := *c
+	quiet.Logger = nil
+	// lo 切出的块数多于 n，hi 恰好切成 n 块，精确到目标大小的 0.5%
+	lo, hi := int(total)/n-1, target
+	for hi-lo > max(64, hi/200) {
+		mid := lo + (hi-lo)/2
+		quiet.TargetSize = mid
+		r, err := open()
+		if err != nil {
+			book.Close()
+			return nil, err
+		}
+		candidate, err := quiet.Split(r)
+		if errors.Is(err, ErrTargetTooSmall) {
+			lo = mid
+			continue
+		}
+		if err != nil {
+			book.Close()
+			return nil, err
+		}
+		log.Debug("均衡分块", "target", mid, "chunks", len(candidate.Chunks))
+		if len(candidate.Chunks) > n {
+			candidate.Close()
+			lo = mid
+			continue
+		}
+		book.Close()
+		book, hi = candidate, mid
+	}
+	log.Debug("均衡分块的目标大小", "target", hi, "chunks", n)
+	return book, nil
+}
+
+// 切分 r 并依次渲染每一块，第 chunk 块写入 w(chunk)，
+// 返回的 Writer 实现了 io.Closer 时写完后关闭。w 为 nil 时写入 OutputDir 下的分块文件
+func (c *Converter) Convert(r io.Reader, w func(chunk int) io.Writer) error {
+	book, err := c.Split(r)
+	if err != nil {
+		return err
+	}
+	defer book.Close()
+
+	if w == nil {
+		if err := os.MkdirAll(c.OutputDir, 0755); err != nil {
+			return err
+		}
+		if book.Chunks[0].SharedAssets {
+			if err := WriteReaderAssets(c.OutputDir, c.Minify); err != nil {
+				return err
+			}
+		}
+	}
+	for _, data := range book.Chunks {
+		var out io.Writer
+		if w != nil {
+			out = w(data.CurrentChunk)
+		} else {
+			file, err := os.Create(filepath.Join(c.OutputDir, data.OutputFile))
+			if err != nil {
+				return err
+			}
+			out = file
+		}
+		err := book.Render(data.CurrentChunk, out)
+		if closer, ok := out.(io.Closer); ok {
+			// 磁盘写满等错误可能要到关闭文件时才会暴露
+			if closeErr := closer.Close(); err == nil {
+				err = closeErr
+			}
+		}
+		if err != nil {
+			return fmt.Errorf("生成第 %d 块失败: %w", data.CurrentChunk, err)
+		}
+	}
+	return nil
+}
+
+// 读回第 chunk 块的正文，渲染为完整页面写入 w。chunk 即 Chunks 中的 CurrentChunk。可在多个 goroutine 中同时调用
+func (b *Book) Render(chunk int, w io.Writer) error {
+	i := chunk - len(b.resume.Chunks) - 1
+	if i < 0 || i >= len(b.Chunks) {
+		return fmt.Errorf("块序号超出范围: %d", chunk)
+	}
+	content, err := b.spool.read(i + 1)
+	if err != nil {
+		return fmt.Errorf("读取第 %d 块失败: %w", chunk, err)
+	}
+	data := b.Chunks[i]
+	data.Content = template.HTML(content)
+	return b.tmpl.execute(w, data)
+}
+
+// 把所有块合并为一个页面写入 w：每块包在可折叠的 <details class="part" id="part-N"> 中，
+// 共用一个设置面板，目标大小只决定各部分的粒度。outputFile 为合并后页面的文件名，页面记录阅读位置时使用
+func (b *Book) RenderSingle(w io.Writer, outputFile string) error {
+	data := b.Chunks[0]
+	data.Single = true
+	data.CurrentChunk = 1
+	data.TotalChunks = len(b.Chunks)
+	data.OutputFile = outputFile
+	data.PrevFile, data.NextFile = "", ""
+	data.CharCount, data.WordCount = 0, 0
+	for _, stats := range b.Stats {
+		data.CharCount += stats.CharCount
+		data.WordCount += stats.WordCount
+	}
+	data.ReadingMinutes = readingMinutes(data.WordCount)
+	// 页面的其余部分先整体渲染，正文处再逐块从暂存文件复制，不把全书读入内存
+	data.Content = templateContentMarker
+	var page bytes.Buffer
+	if err := b.tmpl.execute(&page, data); err != nil {
+		return err
+	}
+	head, tail, ok := bytes.Cut(page.Bytes(), []byte(templateContentMarker))
+	if !ok {
+		return fmt.Errorf("页面模板中没有 {{.Content}}")
+	}
+
+	bw := bufio.NewWriter(w)
+	bw.Write(head)
+	for i, chunk := range b.Chunks {
+		content, err := b.spool.read(i + 1)
+		if err != nil {
+			return fmt.Errorf("读取第 %d 块失败: %w", chunk.CurrentChunk, err)
+		}
+		open := ""
+		if i == 0 {
+			open = " open"
+		}
+		summary := fmt.Sprintf(chunk.Label("part"), chunk.CurrentChunk)
+		if chunk.Chapter != "" {
+			summary += " · " + html.EscapeString(chunk.Chapter)
+		}
+		// 正文保留空白，标签之间不能有多余的换行
+		fmt.Fprintf(bw, `<details class="part" id="part-%d"%s><summary>%s</summary>`, chunk.CurrentChunk, open, summary)
+		bw.WriteString(content)
+		bw.WriteString("</details>")
+	}
+	bw.Write(tail)
+	return bw.Flush()
+}
+
+// 输出全书搜索索引脚本，页面加载后可通过 window.txt2htmlSearch 访问。
+// 需要在切分时启用 Converter.SearchIndex
+func (b *Book) WriteSearchIndex(w io.Writer) error {
+	if b.search == nil {
+		return fmt.Errorf("切分时未启用搜索索引")
+	}
+	files := append([]string{}, b.resume.Chunks...)
+	for _, data := range b.Chunks {
+		files = append(files, data.OutputFile)
+	}
+	return b.search.writeTo(w, files, b.resume.SearchIndex)
+}
+
+// 删除暂存的正文
+func (b *Book) Close() error {
+	return b.spool.remove()
+}
@@ -0,0 +1,964 @@
+package txt2html
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+// 转换 input，返回每块渲染后的页面
+func convertToBuffers(t *testing.T, c *Converter, input string) []*bytes.Buffer {
+	t.Helper()
+	var pages []*bytes.Buffer
+	err := c.Convert(strings.NewReader(input), func(chunk int) io.Writer {
+		if chunk != len(pages)+1 {
+			t.Fatalf("块序号 %d 不连续", chunk)
+		}
+		pages = append(pages, &bytes.Buffer{})
+		return pages[chunk-1]
+	})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	return pages
+}
+
+func TestConvertChapters(t *testing.T) {
+	c := &Converter{
+		FileName:   "book.txt",
+		TargetSize: 64 * 1024,
+		Layout:     LayoutMinimal,
+		HeadingRules: []HeadingRule{
+			{Level: LevelChapter, Pattern: regexp.MustCompile(DefaultChapterPattern), NewChunk: true},
+		},
+	}
+	input := "序言\n第一章 开始\n正文一\n第二章 继续\n正文二\n"
+	book, err := c.Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+
+	if len(book.Chunks) != 3 {
+		t.Fatalf("得到 %d 块，期望 3 块", len(book.Chunks))
+	}
+	if len(book.Headings) != 2 || book.Headings[0].Chunk != 2 || book.Headings[1].Chunk != 3 {
+		t.Fatalf("章节标题 = %+v", book.Headings)
+	}
+	if got := book.Stats[2].Chapter; got != "第二章 继续" {
+		t.Errorf("第 3 块的章节 = %q", got)
+	}
+	if got := book.Chunks[2].Chapter; got != "第二章 继续" {
+		t.Errorf("第 3 块页面中的章节 = %q", got)
+	}
+	if got, want := book.Chunks[1].OutputFile, "book_chunk_2.html"; got != want {
+		t.Errorf("第 2 块的文件名 = %q，期望 %q", got, want)
+	}
+	if book.Chunks[1].PrevFile != book.Chunks[0].OutputFile || book.Chunks[1].NextFile != book.Chunks[2].OutputFile {
+		t.Errorf("第 2 块的导航链接不正确: %+v", book.Chunks[1])
+	}
+
+	var page bytes.Buffer
+	if err := book.Render(3, &page); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(page.String(), `<span class="chapter-heading" id="chapter-2" data-chapter="第二章 继续">第二章 继续`) {
+		t.Errorf("第 3 块缺少章节锚点:\n%s", page.String())
+	}
+}
+
+func TestConvertAutoEncoding(t *testing.T) {
+	// "中文" 的 GBK 编码
+	book, err := (&Converter{FileName: "gbk.txt", Encoding: AutoEncoding}).Split(strings.NewReader("\xd6\xd0\xce\xc4\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if book.Encoding != "gbk" {
+		t.Errorf("检测到的编码 = %q，期望 gbk", book.Encoding)
+	}
+	if book.Stats[0].CharCount != 2 {
+		t.Errorf("字符数 = %d，期望 2", book.Stats[0].CharCount)
+	}
+}
+
+// 字符数和字数按 Unicode 字符统计：UTF-16 的代理对、汉字都计为一个字符，
+// 按目标大小拆开的超长行各块字符数之和仍等于原文字符数
+func TestSplitCharCount(t *testing.T) {
+	// "𠀀😀汉字ab" 的 UTF-16LE 编码，𠀀 和 😀 各占一个代理对
+	input := "\x40\xd8\x00\xdc\x3d\xd8\x00\xde\x49\x6c\x57\x5b\x61\x00\x62\x00\x0a\x00"
+	book, err := (&Converter{FileName: "a.txt", Encoding: "utf-16le"}).Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if got := book.Stats[0].CharCount; got != 6 {
+		t.Errorf("字符数 = %d，期望 6", got)
+	}
+	if got := book.Stats[0].WordCount; got != 4 {
+		t.Errorf("字数 = %d，期望 4", got)
+	}
+
+	line := strings.Repeat("汉字𠀀", 2000)
+	book, err = (&Converter{FileName: "a.txt", TargetSize: 8 * 1024, Layout: LayoutMinimal}).Split(strings.NewReader(line))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if len(book.Stats) < 2 {
+		t.Fatalf("长行没有被拆开: %d 块", len(book.Stats))
+	}
+	total := 0
+	for _, stats := range book.Stats {
+		total += stats.CharCount
+	}
+	if want := utf8.RuneCountInString(line); total != want {
+		t.Errorf("各块字符数之和 = %d，期望 %d", total, want)
+	}
+}
+
+func TestConvertUnknownEncoding(t *testing.T) {
+	if _, err := (&Converter{Encoding: "latin-9"}).Split(strings.NewReader("x")); err == nil {
+		t.Error("不支持的编码应返回错误")
+	}
+}
+
+// 行号按原文件连续编号，不因分块重新开始
+func TestConvertLineNumbers(t *testing.T) {
+	input := strings.Repeat("一行正文\n", 2000)
+	c := &Converter{FileName: "lines.txt", TargetSize: 32 * 1024, Layout: LayoutMinimal, LineNumbers: true}
+	pages := convertToBuffers(t, c, input)
+	if len(pages) < 2 {
+		t.Fatalf("得到 %d 块，期望至少 2 块", len(pages))
+	}
+	numberRe := regexp.MustCompile(`data-line="(\d+)"`)
+	want := 1
+	for i, page := range pages {
+		for _, m := range numberRe.FindAllStringSubmatch(page.String(), -1) {
+			if m[1] != fmt.Sprint(want) {
+				t.Fatalf("第 %d 块中的行号 %s，期望 %d", i+1, m[1], want)
+			}
+			want++
+		}
+	}
+	if want != 2001 {
+		t.Errorf("共标注 %d 行，期望 2000 行", want-1)
+	}
+}
+
+// 空输入不生成任何分块，返回 ErrEmpty；只有一个换行时仍是一块
+func TestSplitEmpty(t *testing.T) {
+	for _, input := range []string{"", "\uFEFF"} {
+		if _, err := (&Converter{}).Split(strings.NewReader(input)); !errors.Is(err, ErrEmpty) {
+			t.Errorf("Split(%q) 返回 %v，期望 ErrEmpty", input, err)
+		}
+	}
+	book, err := (&Converter{}).Split(strings.NewReader("\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if len(book.Chunks) != 1 {
+		t.Errorf("得到 %d 块，期望 1 块", len(book.Chunks))
+	}
+}
+
+// 纯文本中分隔段落的空行单独包一层，页面中可以调整段落间距
+func TestConvertBlankLines(t *testing.T) {
+	c := &Converter{FileName: "a.txt", Layout: LayoutMinimal}
+	pages := convertToBuffers(t, c, "第一段\n\n第二段\n  \n第三段\n")
+	got := pages[0].String()
+	want := "第一段\n<span class=\"blank-line\">\n</span>第二段\n<span class=\"blank-line\">  \n</span>第三段\n"
+	if !strings.Contains(got, want) {
+		t.Errorf("页面中没有期望的正文 %q:\n%s", want, got)
+	}
+}
+
+// LF 与 CRLF 混用的输入经由实际的按行扫描切分：每行末尾的 \r 都被去掉，行的边界和行号不变。
+// 逐字节读取，\r 和 \n 落在不同的读取中也能正确处理
+func TestConvertMixedLineEndings(t *testing.T) {
+	c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, LinesPerChunk: 2, AnchorLines: 1}
+	var pages []*bytes.Buffer
+	err := c.Convert(iotest.OneByteReader(strings.NewReader("一\r\n二\n三\r\n四\n五\r\n")), func(chunk int) io.Writer {
+		pages = append(pages, &bytes.Buffer{})
+		return pages[chunk-1]
+	})
+	if err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	want := []string{
+		`<span class="line-anchor" id="L1"></span>一` + "\n" + `<span class="line-anchor" id="L2"></span>二` + "\n",
+		`<span class="line-anchor" id="L3"></span>三` + "\n" + `<span class="line-anchor" id="L4"></span>四` + "\n",
+		`<span class="line-anchor" id="L5"></span>五` + "\n",
+	}
+	if len(pages) != len(want) {
+		t.Fatalf("得到 %d 块，期望 %d 块", len(pages), len(want))
+	}
+	for i, page := range pages {
+		if strings.Contains(page.String(), "\r") {
+			t.Errorf("第 %d 块中有 \\r", i+1)
+		}
+		if !strings.Contains(page.String(), `<div class="content">`+want[i]+`</div>`) {
+			t.Errorf("第 %d 块的正文不是 %q:\n%s", i+1, want[i], page)
+		}
+	}
+}
+
+// 比原来 1 MB 扫描缓冲更长的单行能正常转换，拼接各块正文后与原文逐字节一致
+func TestConvertLineLongerThanBuffer(t *testing.T) {
+	line := strings.Repeat("abcdefghij", 2*1024*1024/10)
+	pages := convertToBuffers(t, &Converter{FileName: "long.txt", Layout: LayoutMinimal}, line+"\n")
+	contentRe := regexp.MustCompile(`(?s)<div class="content">(.*?)</div>`)
+	var got strings.Builder
+	for i, page := range pages {
+		m := contentRe.FindStringSubmatch(page.String())
+		if m == nil {
+			t.Fatalf("第 %d 块中没有正文", i+1)
+		}
+		got.WriteString(m[1])
+	}
+	if got.String() != line+"\n" {
+		t.Errorf("拼接后的正文 %d 字节，与原文 %d 字节不一致", got.Len(), len(line)+1)
+	}
+}
+
+// 超过 MaxLineSize 的行返回错误，不会被截断后当作成功
+func TestConvertLineTooLong(t *testing.T) {
+	c := &Converter{FileName: "long.txt", Layout: LayoutMinimal, MaxLineSize: 1024 * 1024}
+	input := "第一行\n" + strings.Repeat("a", 2*1024*1024) + "\n"
+	err := c.Convert(strings.NewReader(input), func(int) io.Writer { return io.Discard })
+	if !errors.Is(err, bufio.ErrTooLong) || !strings.Contains(err.Error(), "存在超过单行长度上限的行") {
+		t.Errorf("Convert = %v，期望超过单行长度上限的错误", err)
+	}
+}
+
+// 没有换行的 3 MB 单行在 1 MB 目标大小下也要拆开：每个页面都不超过目标大小，
+// 切分点落在安全的边界上，不会截断多字节字符或转义实体
+func TestConvertSingleLongLine(t *testing.T) {
+	const target = 1024 * 1024
+	input := strings.Repeat("一二三四五六七八九十abc&<>", 3*1024*1024/40)
+	pages := convertToBuffers(t, &Converter{FileName: "long.txt", TargetSize: target, AnchorLines: 10}, input)
+	if len(pages) < 3 {
+		t.Fatalf("得到 %d 块，期望至少 3 块", len(pages))
+	}
+	entityRe := regexp.MustCompile(`&[a-z#0-9]*$|^[a-z#0-9]*;`)
+	contentRe := regexp.MustCompile(`(?s)<div class="content"[^>]*>(.*?)</div>`)
+	for i, page := range pages {
+		if page.Len() > target {
+			t.Errorf("第 %d 块 %d 字节，超过目标大小 %d", i+1, page.Len(), target)
+		}
+		if !utf8.Valid(page.Bytes()) {
+			t.Errorf("第 %d 块不是有效的 UTF-8", i+1)
+		}
+		m := contentRe.FindStringSubmatch(page.String())
+		if m == nil {
+			t.Fatalf("第 %d 块中没有正文", i+1)
+		}
+		text := strings.TrimSpace(regexp.MustCompile(`<[^>]*>`).ReplaceAllString(m[1], ""))
+		if entityRe.MatchString(text) {
+			t.Errorf("第 %d 块的首尾截断了转义实体: %q…%q", i+1, text[:min(20, len(text))], text[max(0, len(text)-20):])
+		}
+	}
+}
+
+// 连续空行压缩为最多 MaxBlankLines 行，行号仍按原文计数
+func TestConvertMaxBlankLines(t *testing.T) {
+	c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, MaxBlankLines: 1, AnchorLines: 1}
+	pages := convertToBuffers(t, c, "a\n\n\n\n\nb\n")
+	got := pages[0].String()
+	want := `<span class="line-anchor" id="L1"></span>a
+<span class="line-anchor" id="L2"></span><span class="blank-line">
+</span><span class="line-anchor" id="L6"></span>b
+`
+	if !strings.Contains(got, want) {
+		t.Errorf("页面中没有期望的正文 %q:\n%s", want, got)
+	}
+}
+
+// UTF-8 和 UTF-16 的 BOM 不出现在第一块的正文中
+func TestConvertStripsBOM(t *testing.T) {
+	tests := []struct {
+		name     string
+		encoding string
+		input    string
+	}{
+		{"utf-8", "utf-8", "\xef\xbb\xbf中文\n"},
+		{"utf-8 自动检测", AutoEncoding, "\xef\xbb\xbf中文\n"},
+		{"utf-16le", "utf-16le", "\xff\xfe\x2d\x4e\x87\x65\n\x00"},
+		{"utf-16be 自动检测", AutoEncoding, "\xfe\xff\x4e\x2d\x65\x87\x00\n"},
+		// 未指定编码时按 UTF-8 读取，BOM 标明的 UTF-16 优先，其中的空字节不算二进制内容
+		{"utf-16le 默认编码", "", "\xff\xfe\x2d\x4e\x87\x65\n\x00"},
+		{"utf-16be 默认编码", "", "\xfe\xff\x4e\x2d\x65\x87\x00\n"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Converter{FileName: "bom.txt", Encoding: tt.encoding, Layout: LayoutMinimal}
+			pages := convertToBuffers(t, c, tt.input)
+			page := pages[0].String()
+			if strings.ContainsRune(page, '\uFEFF') {
+				t.Errorf("第 1 块中仍有 BOM")
+			}
+			if !strings.Contains(page, `<div class="content">中文`) {
+				t.Errorf("第 1 块的正文不正确:\n%s", page)
+			}
+		})
+	}
+}
+
+// 追加转换时块序号、行号和章节锚点都接着已有的内容编号
+func TestSplitResume(t *testing.T) {
+	c := &Converter{
+		FileName: "book.txt",
+		Layout:   LayoutMinimal,
+		HeadingRules: []HeadingRule{
+			{Level: LevelChapter, Pattern: regexp.MustCompile(DefaultChapterPattern), NewChunk: true},
+		},
+		AnchorLines: 1,
+		SearchIndex: true,
+		Resume: Resume{
+			Chunks:      []string{"book_chunk_1.html", "book_chunk_2.html"},
+			Lines:       100,
+			Headings:    2,
+			SearchIndex: strings.NewReader("window.txt2htmlSearch = {\"files\": [\"a\",\"b\"], \"lines\": [\n[1,1,\"旧\"]\n]};\n"),
+		},
+	}
+	book, err := c.Split(strings.NewReader("第三章 新的一章\n正文\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+
+	if len(book.Chunks) != 1 {
+		t.Fatalf("得到 %d 个新块，期望 1 块", len(book.Chunks))
+	}
+	data := book.Chunks[0]
+	if data.CurrentChunk != 3 || data.TotalChunks != 3 || data.OutputFile != "book_chunk_3.html" || data.PrevFile != "book_chunk_2.html" {
+		t.Errorf("新块的页面数据不正确: %+v", data)
+	}
+	if book.Lines != 102 {
+		t.Errorf("行数 = %d，期望 102", book.Lines)
+	}
+	if len(book.Headings) != 1 || book.Headings[0].Anchor != "chapter-3" || book.Headings[0].Chunk != 3 {
+		t.Errorf("章节标题 = %+v", book.Headings)
+	}
+
+	var page bytes.Buffer
+	if err := book.Render(3, &page); err != nil {
+		t.Fatalf("Render: %v", err)
+	}
+	if !strings.Contains(page.String(), `id="L101"`) {
+		t.Errorf("行号锚点没有接着编号:\n%s", page.String())
+	}
+
+	var index bytes.Buffer
+	if err := book.WriteSearchIndex(&index); err != nil {
+		t.Fatalf("WriteSearchIndex: %v", err)
+	}
+	want := "window.txt2htmlSearch = {\"files\": [\"book_chunk_1.html\",\"book_chunk_2.html\",\"book_chunk_3.html\"], \"lines\": [\n" +
+		"[1,1,\"旧\"],\n[3,101,\"第三章 新的一章\"],\n[3,102,\"正文\"]\n]};\n"
+	if index.String() != want {
+		t.Errorf("搜索索引 = %q，期望 %q", index.String(), want)
+	}
+}
+
+// 搜索索引中的 < > & 原样保留，不会因 \u003c 之类的转义比正文大得多；只有 </ 需要转义
+func TestSearchIndexEscaping(t *testing.T) {
+	c := &Converter{FileName: "book.txt", Layout: LayoutMinimal, SearchIndex: true}
+	book, err := c.Split(strings.NewReader("a<b && c>d</script>\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	var index bytes.Buffer
+	if err := book.WriteSearchIndex(&index); err != nil {
+		t.Fatalf("WriteSearchIndex: %v", err)
+	}
+	if want := `[1,1,"a<b && c>d<\/script>"]`; !strings.Contains(index.String(), want) {
+		t.Errorf("搜索索引 = %q，期望包含 %q", index.String(), want)
+	}
+}
+
+// 自定义模板代替内置页面，不含 {{.Content}} 的模板在读取时就报错
+func TestConvertCustomTemplate(t *testing.T) {
+	dir := t.TempDir()
+	good := filepath.Join(dir, "good.html")
+	bad := filepath.Join(dir, "bad.html")
+	os.WriteFile(good, []byte(`<h1>{{.FileName}} {{.CurrentChunk}}/{{.TotalChunks}}</h1>{{.Content}}`), 0644)
+	os.WriteFile(bad, []byte(`<h1>{{.FileName}}</h1>`), 0644)
+
+	if _, err := ParseTemplateFile(bad); err == nil {
+		t.Errorf("不含 {{.Content}} 的模板没有报错")
+	}
+	tmpl, err := ParseTemplateFile(good)
+	if err != nil {
+		t.Fatalf("ParseTemplateFile: %v", err)
+	}
+	pages := convertToBuffers(t, &Converter{FileName: "a.txt", Template: tmpl}, "a<b\n")
+	if len(pages) != 1 {
+		t.Fatalf("得到 %d 块，期望 1 块", len(pages))
+	}
+	if got, want := pages[0].String(), "<h1>a.txt 1/1</h1>a&lt;b\n"; got != want {
+		t.Errorf("页面 = %q，期望 %q", got, want)
+	}
+}
+
+// 达到块数上限后其余内容都放入最后一块，显示的总块数为上限
+func TestConvertMaxChunks(t *testing.T) {
+	c := &Converter{FileName: "a.txt", TargetSize: 4 * 1024, Layout: LayoutMinimal, MaxChunks: 3}
+	input := strings.Repeat("一行测试文本\n", 5000)
+	book, err := c.Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if len(book.Chunks) != 3 {
+		t.Fatalf("得到 %d 块，期望 3 块", len(book.Chunks))
+	}
+	for _, data := range book.Chunks {
+		if data.TotalChunks != 3 {
+			t.Errorf("第 %d 块的总块数 = %d，期望 3", data.CurrentChunk, data.TotalChunks)
+		}
+	}
+	total := 0
+	for _, s := range book.Stats {
+		total += s.CharCount
+	}
+	if want := utf8.RuneCountInString(strings.ReplaceAll(input, "\n", "")); total != want {
+		t.Errorf("各块共 %d 字符，期望 %d", total, want)
+	}
+}
+
+// 只切分第一块时写满就停止读取，之后的输入即使读取出错也不影响结果
+func TestSplitFirstChunkOnly(t *testing.T) {
+	c := &Converter{FileName: "a.txt", TargetSize: 4 * 1024, Layout: LayoutMinimal, FirstChunkOnly: true}
+	input := strings.Repeat("一行测试文本\n", 5000)
+	r := io.MultiReader(strings.NewReader(input), iotest.ErrReader(errors.New("不应读到这里")))
+	book, err := c.Split(r)
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if len(book.Chunks) != 1 || book.Chunks[0].TotalChunks != 1 || book.Chunks[0].NextFile != "" {
+		t.Fatalf("得到 %d 块，总块数 %d，期望只有一块", len(book.Chunks), book.Chunks[0].TotalChunks)
+	}
+	if !book.Truncated {
+		t.Error("Truncated = false，期望 true")
+	}
+	if book.Lines >= 5000 {
+		t.Errorf("读取了 %d 行，期望读完第一块就停止", book.Lines)
+	}
+
+	// 内容不足一块时与普通切分相同
+	book, err = c.Split(strings.NewReader("一行\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if len(book.Chunks) != 1 || book.Truncated {
+		t.Errorf("得到 %d 块，Truncated = %v，期望 1 块且未截断", len(book.Chunks), book.Truncated)
+	}
+}
+
+// 按行数分块：行数恰为整数倍时没有多余的空块，有余数时最后一块放剩下的行
+func TestSplitLinesPerChunk(t *testing.T) {
+	tests := []struct {
+		name  string
+		lines int
+		want  []int // 每块的行数
+	}{
+		{"整数倍", 10, []int{5, 5}},
+		{"有余数", 11, []int{5, 5, 1}},
+		{"不足一块", 3, []int{3}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, LinesPerChunk: 5, AnchorLines: 1}
+			pages := convertToBuffers(t, c, strings.Repeat("一行\n", tt.lines))
+			if len(pages) != len(tt.want) {
+				t.Fatalf("得到 %d 块，期望 %d 块", len(pages), len(tt.want))
+			}
+			for i, page := range pages {
+				if got := strings.Count(page.String(), `class="line-anchor"`); got != tt.want[i] {
+					t.Errorf("第 %d 块有 %d 行，期望 %d 行", i+1, got, tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 每块开头重复上一块的最后几行，重复的行不带锚点ID；从新章节开始的块不重复
+func TestSplitOverlap(t *testing.T) {
+	c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, LinesPerChunk: 3, Overlap: 2, AnchorLines: 1,
+		HeadingRules: []HeadingRule{
+			{Level: LevelChapter, Pattern: regexp.MustCompile(DefaultChapterPattern), NewChunk: true},
+		},
+	}
+	pages := convertToBuffers(t, c, "行一\n行二\n行三\n行四\n第二章 标题\n行五\n")
+	if len(pages) != 3 {
+		t.Fatalf("得到 %d 块，期望 3 块", len(pages))
+	}
+	overlapRe := regexp.MustCompile(`(?s)<div class="overlap" aria-hidden="true">(.*?)</div>`)
+	want := []string{"", "行二 行三", ""}
+	for i, page := range pages {
+		m := overlapRe.FindStringSubmatch(page.String())
+		got := ""
+		if m != nil {
+			if strings.Contains(m[1], ` id="`) {
+				t.Errorf("第 %d 块重复的内容中有锚点ID: %s", i+1, m[1])
+			}
+			got = strings.Join(strings.Fields(regexp.MustCompile(`<[^>]*>`).ReplaceAllString(m[1], "")), " ")
+		}
+		if got != want[i] {
+			t.Errorf("第 %d 块重复的内容为 %q，期望 %q", i+1, got, want[i])
+		}
+	}
+
+	// 按大小分块时重复的内容计入大小，每块仍不超过目标大小
+	c = &Converter{FileName: "a.txt", Layout: LayoutMinimal, TargetSize: 4 * 1024, Overlap: 3}
+	for i, page := range convertToBuffers(t, c, strings.Repeat("一行测试文本\n", 2000)) {
+		if page.Len() > c.TargetSize {
+			t.Errorf("第 %d 块 %d 字节，超过目标大小 %d", i+1, page.Len(), c.TargetSize)
+		}
+	}
+}
+
+// 逐段显示时每块开头的几行直接显示，其后每几行包成一段；精简页面没有显示各段的脚本，不分段
+func TestSplitRevealLines(t *testing.T) {
+	input := "行1\n行2\n行3\n行4\n行5\n行6\n行7\n"
+	c := &Converter{FileName: "a.txt", LinesPerChunk: 5, RevealLines: 2}
+	pages := convertToBuffers(t, c, input)
+	if len(pages) != 2 {
+		t.Fatalf("得到 %d 块，期望 2 块", len(pages))
+	}
+	want := "行1\n行2\n" + revealOpenTag + "行3\n行4\n" + revealCloseTag + revealOpenTag + "行5\n" + revealCloseTag
+	if !strings.Contains(pages[0].String(), want) {
+		t.Errorf("第一块中没有按每 2 行分段的正文 %q", want)
+	}
+	if strings.Contains(pages[1].String(), revealOpenTag) {
+		t.Error("第二块只有 2 行，不应分段")
+	}
+	c = &Converter{FileName: "a.txt", Layout: LayoutMinimal, LinesPerChunk: 5, RevealLines: 2}
+	if page := convertToBuffers(t, c, input)[0]; strings.Contains(page.String(), revealOpenTag) {
+		t.Error("精简页面中有逐段显示的段")
+	}
+
+	// 分段的标签计入大小，每段一行时每块仍不超过目标大小
+	c = &Converter{FileName: "a.txt", TargetSize: 96 * 1024, RevealLines: 1}
+	for i, page := range convertToBuffers(t, c, strings.Repeat("一行测试文本\n", 20000)) {
+		if page.Len() > c.TargetSize {
+			t.Errorf("第 %d 块 %d 字节，超过目标大小 %d", i+1, page.Len(), c.TargetSize)
+		}
+	}
+}
+
+// 分块标记所在处开始新的一块，标记本身不输出；开头、结尾和连续的标记不产生空块
+func TestSplitMarker(t *testing.T) {
+	const marker = "----PAGE----"
+	tests := []struct {
+		name     string
+		input    string
+		markdown bool
+		lines    int      // 每块的行数，0 表示按大小分块
+		want     []string // 每块的正文（去掉标签后的文字）
+	}{
+		{"中间", "一\n" + marker + "\n二\n", false, 0, []string{"一", "二"}},
+		{"开头", marker + "\n一\n二\n", false, 0, []string{"一 二"}},
+		{"结尾", "一\n二\n" + marker + "\n", false, 0, []string{"一 二"}},
+		{"连续", "一\n" + marker + "\n" + marker + "\n\n" + marker + "\n二\n", false, 0, []string{"一", "二"}},
+		{"不完全相同", "一\n " + marker + "\n二\n", false, 0, []string{"一 " + marker + " 二"}},
+		{"与按行数分块同时生效", "一\n二\n三\n" + marker + "\n四\n", false, 2, []string{"一 二", "三", "四"}},
+		{"Markdown", "一\n\n" + marker + "\n\n二\n", true, 0, []string{"一", "二"}},
+	}
+	tagRe := regexp.MustCompile(`<[^>]*>`)
+	contentRe := regexp.MustCompile(`(?s)<div class="content[^"]*">(.*?)</div>`)
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, Markdown: tt.markdown, LinesPerChunk: tt.lines, SplitMarker: marker}
+			pages := convertToBuffers(t, c, tt.input)
+			var got []string
+			for _, page := range pages {
+				m := contentRe.FindStringSubmatch(page.String())
+				if m == nil {
+					t.Fatalf("页面中没有正文: %s", page)
+				}
+				got = append(got, strings.Join(strings.Fields(tagRe.ReplaceAllString(m[1], "")), " "))
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("各块正文 = %q，期望 %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// 均衡分块与按目标大小切分的块数相同，但最后一块不会比其他块小很多
+func TestSplitBalanced(t *testing.T) {
+	input := strings.Repeat("一行测试文本，用于均衡分块\n", 1500)
+	c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, TargetSize: 16 * 1024}
+	sizes := func(book *Book) []int {
+		t.Helper()
+		var sizes []int
+		for _, data := range book.Chunks {
+			var buf bytes.Buffer
+			if err := book.Render(data.CurrentChunk, &buf); err != nil {
+				t.Fatal(err)
+			}
+			sizes = append(sizes, buf.Len())
+		}
+		return sizes
+	}
+	greedy, err := c.Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer greedy.Close()
+	want := sizes(greedy)
+
+	opened := 0
+	book, err := c.SplitBalanced(func() (io.Reader, error) {
+		opened++
+		return strings.NewReader(input), nil
+	})
+	if err != nil {
+		t.Fatalf("SplitBalanced: %v", err)
+	}
+	defer book.Close()
+	got := sizes(book)
+	if len(got) != len(want) {
+		t.Fatalf("得到 %d 块，期望与按目标大小切分相同的 %d 块", len(got), len(want))
+	}
+	if opened < 2 {
+		t.Errorf("只读取了 %d 遍输入", opened)
+	}
+	smallest, largest := slices.Min(got), slices.Max(got)
+	if largest > c.TargetSize || smallest < largest*9/10 {
+		t.Errorf("各块大小 %v 不均衡（按目标大小切分时为 %v）", got, want)
+	}
+}
+
+// 超长的行折成多行后按折后的行数分块，行号只标在原文一行的开头
+func TestSplitWrapAt(t *testing.T) {
+	c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, LinesPerChunk: 2, WrapAt: 3, LineNumbers: true}
+	pages := convertToBuffers(t, c, "一二三四五六七\n")
+	if len(pages) != 2 {
+		t.Fatalf("得到 %d 块，期望 2 块", len(pages))
+	}
+	if !strings.Contains(pages[0].String(), "一二三\n") || !strings.Contains(pages[1].String(), "七\n") {
+		t.Errorf("折行位置不对:\n%s\n%s", pages[0], pages[1])
+	}
+	numbers := 0
+	for _, page := range pages {
+		numbers += strings.Count(page.String(), `data-line="1"`)
+	}
+	if numbers != 1 {
+		t.Errorf("第 1 行的行号出现了 %d 次，期望 1 次", numbers)
+	}
+}
+
+// 首行缩进时段落第一行以缩进标记开头，原有的行首全角空格被去掉，空行和章节标题不加标记；
+// 空行分隔段落时段内后续的行不缩进，每行一段时每个非空行都是一段，并在段前加间距标记
+func TestSplitFirstLineIndent(t *testing.T) {
+	for _, tc := range []struct {
+		lineParagraphs bool
+		want           []string
+		indents, gaps  int
+	}{
+		{false, []string{paragraphIndentTag + "第一段\n段内换行\n", paragraphIndentTag + "第二段\n"}, 2, 0},
+		{true, []string{paragraphGapTag + paragraphIndentTag + "第一段\n" + paragraphGapTag + paragraphIndentTag + "段内换行\n"}, 3, 3},
+	} {
+		c := &Converter{
+			FileName:        "a.txt",
+			Layout:          LayoutMinimal,
+			FirstLineIndent: true,
+			LineParagraphs:  tc.lineParagraphs,
+			HeadingRules:    []HeadingRule{{Pattern: regexp.MustCompile(DefaultChapterPattern), Level: 1}},
+		}
+		pages := convertToBuffers(t, c, "第一章 开始\n　　第一段\n段内换行\n\n第二段\n")
+		page := pages[0].String()
+		for _, want := range tc.want {
+			if !strings.Contains(page, want) {
+				t.Errorf("LineParagraphs=%v: 缺少 %q:\n%s", tc.lineParagraphs, want, page)
+			}
+		}
+		if n := strings.Count(page, paragraphIndentTag); n != tc.indents {
+			t.Errorf("LineParagraphs=%v: 缩进标记出现了 %d 次，期望 %d 次", tc.lineParagraphs, n, tc.indents)
+		}
+		if n := strings.Count(page, paragraphGapTag); n != tc.gaps {
+			t.Errorf("LineParagraphs=%v: 间距标记出现了 %d 次，期望 %d 次", tc.lineParagraphs, n, tc.gaps)
+		}
+	}
+}
+
+// Sanitize 时去掉除制表符外的控制字符，不开启时原样保留，两种情况都统计数量；含空字节的输入需 AllowBinary
+func TestSplitSanitize(t *testing.T) {
+	input := "第\x00一\f行\t结尾\r\r\n\v第二行\n"
+	for _, sanitize := range []bool{false, true} {
+		c := &Converter{FileName: "a.txt", Layout: LayoutMinimal, AllowBinary: true, Sanitize: sanitize}
+		book, err := c.Split(strings.NewReader(input))
+		if err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+		var buf bytes.Buffer
+		err = book.Render(1, &buf)
+		book.Close()
+		if err != nil {
+			t.Fatalf("Render: %v", err)
+		}
+		if book.ControlChars != 3 {
+			t.Errorf("sanitize=%v: ControlChars = %d，期望 3", sanitize, book.ControlChars)
+		}
+		if cleaned := strings.Contains(buf.String(), "第一行\t结尾\n第二行\n"); cleaned != sanitize {
+			t.Errorf("sanitize=%v: 控制字符的处理不对:\n%s", sanitize, buf.String())
+		}
+	}
+}
+
+// 指定 IndexPage 时完整页面的浮动导航中有“目录”链接，精简页面写在 head 中
+func TestRenderIndexPage(t *testing.T) {
+	for _, layout := range []Layout{LayoutFull, LayoutMinimal} {
+		page := convertToBuffers(t, &Converter{FileName: "a.txt", Layout: layout, IndexPage: "index.html"}, "内容\n")[0].String()
+		if !strings.Contains(page, `href="index.html"`) {
+			t.Errorf("布局 %v 中没有目录页链接", layout)
+		}
+		page = convertToBuffers(t, &Converter{FileName: "a.txt", Layout: layout}, "内容\n")[0].String()
+		if strings.Contains(page, `href="index.html"`) {
+			t.Errorf("布局 %v 没有目录页时仍有链接", layout)
+		}
+	}
+}
+
+// SolidBackground 时页面背景改为左侧背景色，隐藏右侧背景的选择
+func TestRenderSolidBackground(t *testing.T) {
+	page := convertToBuffers(t, &Converter{FileName: "a.txt", SolidBackground: true}, "内容\n")[0].String()
+	if !strings.Contains(page, "background: var(--left-bg);") || !strings.Contains(page, `<div class="control-group" hidden>`) {
+		t.Errorf("没有使用纯色背景:\n%s", page)
+	}
+	page = convertToBuffers(t, &Converter{FileName: "a.txt"}, "内容\n")[0].String()
+	if strings.Contains(page, "background: var(--left-bg);") || strings.Contains(page, `<div class="control-group" hidden>`) {
+		t.Error("默认页面使用了纯色背景")
+	}
+}
+
+// 每种界面语言都有默认语言的全部文字；英文界面的页面中除正文、样式和脚本外没有中文
+func TestUILabels(t *testing.T) {
+	for _, lang := range UILanguages {
+		for key := range uiLabels[UILanguages[0]] {
+			if _, ok := uiLabels[lang][key]; !ok {
+				t.Errorf("界面语言 %s 缺少 %q", lang, key)
+			}
+		}
+	}
+	c := &Converter{FileName: "a.txt", UILang: "en", IndexPage: "index.html", SearchPage: "search.html", FirstLineIndent: true}
+	page := convertToBuffers(t, c, "text\n")[0].String()
+	page = regexp.MustCompile(`(?s)<script.*?</script>|<style.*?</style>`).ReplaceAllString(page, "")
+	if han := regexp.MustCompile(`\p{Han}+`).FindAllString(page, -1); han != nil {
+		t.Errorf("英文界面中还有中文: %q", han)
+	}
+	if !strings.Contains(page, `<html lang="en">`) || !strings.Contains(page, "Part 1 of 1") {
+		t.Errorf("页面没有使用英文界面:\n%s", page)
+	}
+	if _, err := (&Converter{FileName: "a.txt", UILang: "xx"}).Split(strings.NewReader("text\n")); err == nil {
+		t.Error("不支持的界面语言没有报错")
+	}
+}
+
+// 压缩时去掉缩进、空行和整行的注释，行尾的注释和换行保留
+func TestMinifyText(t *testing.T) {
+	src := "  <!-- 说明 -->\n  <p>\n\n    /* 多行\n       注释 */ a { color: red; } /* 行尾 */\n    // 脚本注释\n    f('//x');\n"
+	want := "<p>\na { color: red; } /* 行尾 */\nf('//x');\n"
+	if got := minifyText(src); got != want {
+		t.Errorf("minifyText = %q，期望 %q", got, want)
+	}
+}
+
+// Minify 时页面更小，按压缩后的模板计算基础大小，各块仍不超过目标大小
+func TestConvertMinify(t *testing.T) {
+	input := strings.Repeat("一行测试文本\n", 20000)
+	c := &Converter{FileName: "a.txt", TargetSize: 100 * 1024}
+	plain := convertToBuffers(t, c, input)
+	c.Minify = true
+	minified := convertToBuffers(t, c, input)
+	if len(minified) > len(plain) {
+		t.Errorf("压缩后块数 %d，不压缩时 %d", len(minified), len(plain))
+	}
+	for i, page := range minified {
+		if page.Len() > c.TargetSize {
+			t.Errorf("第 %d 块 %d 字节，超过目标大小 %d", i+1, page.Len(), c.TargetSize)
+		}
+	}
+	page := TemplateData{FileName: "a.txt"}
+	if base := getBaseHTMLSize(pageTemplate{minify: true}, page, NamePattern{}, 1); base >= getBaseHTMLSize(pageTemplate{}, page, NamePattern{}, 1) {
+		t.Errorf("压缩后的基础大小 %d 没有变小", base)
+	}
+	if !strings.Contains(minified[0].String(), "一行测试文本\n一行测试文本\n") {
+		t.Error("压缩影响了正文")
+	}
+}
+
+// 目标大小放不下页面模板时直接报错，而不是生成大量几乎为空的分块
+func TestSplitTargetTooSmall(t *testing.T) {
+	c := &Converter{FileName: "a.txt", TargetSize: 4 * 1024}
+	input := strings.Repeat("一行测试文本\n", 5000)
+	if _, err := c.Split(strings.NewReader(input)); !errors.Is(err, ErrTargetTooSmall) {
+		t.Fatalf("Split 返回 %v，期望 ErrTargetTooSmall", err)
+	}
+	// 精简页面的模板小得多，同样的大小可以正常切分
+	c.Layout = LayoutMinimal
+	book, err := c.Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	book.Close()
+}
+
+// SharedAssets 时页面引用共用的样式表和脚本，写入目录的文件与内联的内容一致
+func TestConvertSharedAssets(t *testing.T) {
+	dir := t.TempDir()
+	c := &Converter{FileName: "a.txt", OutputDir: dir, SharedAssets: true}
+	if err := c.Convert(strings.NewReader("hello\n"), nil); err != nil {
+		t.Fatalf("Convert: %v", err)
+	}
+	page, err := os.ReadFile(filepath.Join(dir, "a_chunk_1.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{`href="` + ReaderStyleFile + `"`, `src="` + ReaderScriptFile + `"`} {
+		if !strings.Contains(string(page), want) {
+			t.Errorf("页面中没有 %s", want)
+		}
+	}
+	if strings.Contains(string(page), "addEventListener('DOMContentLoaded'") {
+		t.Errorf("页面中仍内联了阅读脚本")
+	}
+	for name, want := range map[string]string{ReaderStyleFile: readerStyle, ReaderScriptFile: readerScript} {
+		got, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil || string(got) != want {
+			t.Errorf("%s 的内容不正确: %v", name, err)
+		}
+	}
+}
+
+// 未指定书名时使用文件名，指定后只影响显示，不影响分块文件名
+func TestSplitTitle(t *testing.T) {
+	for _, tt := range []struct{ title, want string }{{"", "a.txt"}, {"书名", "书名"}} {
+		book, err := (&Converter{FileName: "a.txt", Title: tt.title}).Split(strings.NewReader("hello\n"))
+		if err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+		defer book.Close()
+		if got := book.Chunks[0].Title; got != tt.want {
+			t.Errorf("Title = %q，期望 %q", got, tt.want)
+		}
+		if got := book.Chunks[0].OutputFile; got != "a_chunk_1.html" {
+			t.Errorf("OutputFile = %q", got)
+		}
+	}
+}
+
+// 分块文件名中的空白和不便用于网址的字符被替换，标题保留原来的文件名；RawNames 时文件名保留原样
+func TestSplitSafeNames(t *testing.T) {
+	tests := []struct {
+		fileName string
+		raw      bool
+		title    string
+		output   string
+	}{
+		{"my  book #1.txt", false, "my book #1.txt", "my_book-1_chunk_1.html"},
+		{"《三体》 第一部.txt", false, "《三体》 第一部.txt", "《三体》_第一部_chunk_1.html"},
+		{"a&b?.txt", false, "a&b?.txt", "a-b_chunk_1.html"},
+		{"###.txt", false, "###.txt", "book_chunk_1.html"},
+		{"my book.txt", true, "my book.txt", "my book_chunk_1.html"},
+	}
+	for _, tt := range tests {
+		book, err := (&Converter{FileName: tt.fileName, RawNames: tt.raw}).Split(strings.NewReader("hello\n"))
+		if err != nil {
+			t.Fatalf("Split: %v", err)
+		}
+		defer book.Close()
+		data := book.Chunks[0]
+		if data.Title != tt.title || data.OutputFile != tt.output {
+			t.Errorf("%q: Title = %q，OutputFile = %q，期望 %q、%q", tt.fileName, data.Title, data.OutputFile, tt.title, tt.output)
+		}
+		if data.FileName != tt.fileName {
+			t.Errorf("%q: FileName = %q，期望保持不变", tt.fileName, data.FileName)
+		}
+	}
+}
+
+// 无法解码的字节计入 DecodeErrors，指定 DecodeMarker 时在正文中显示为该标记
+func TestSplitDecodeErrors(t *testing.T) {
+	// "中文" 的 GBK 编码后跟两个无效字节
+	input := "\xd6\xd0\xce\xc4\xff\xfe\n\xd6\xd0\n"
+	pages := convertToBuffers(t, &Converter{FileName: "a.txt", Encoding: "gbk", AllowBinary: true, DecodeMarker: "[?]", Layout: LayoutMinimal}, input)
+	if !strings.Contains(pages[0].String(), "中文[?][?]\n中") {
+		t.Errorf("页面中没有解码错误的标记: %s", pages[0].String())
+	}
+	book, err := (&Converter{FileName: "a.txt", Encoding: "gbk", AllowBinary: true}).Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	if book.DecodeErrors != 2 {
+		t.Errorf("DecodeErrors = %d，期望 2", book.DecodeErrors)
+	}
+}
+
+// 合并为单个页面时每块是一个 <details> 部分，页面中只有一个设置面板，没有翻页链接
+func TestRenderSingle(t *testing.T) {
+	c := &Converter{FileName: "a.txt", HeadingRules: []HeadingRule{
+		{Level: LevelChapter, Pattern: regexp.MustCompile(DefaultChapterPattern), NewChunk: true},
+	}}
+	book, err := c.Split(strings.NewReader("前言\n第1章 开始\n正文一\n第2章 继续\n正文二\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	var buf bytes.Buffer
+	if err := book.RenderSingle(&buf, "a.html"); err != nil {
+		t.Fatalf("RenderSingle: %v", err)
+	}
+	page := buf.String()
+	if got := strings.Count(page, `<details class="part"`); got != len(book.Chunks) {
+		t.Errorf("得到 %d 个部分，期望 %d 个", got, len(book.Chunks))
+	}
+	for _, want := range []string{`<summary>第 2 部分 · 第1章 开始</summary>`, "正文二", `currentFile: "a.html"`} {
+		if !strings.Contains(page, want) {
+			t.Errorf("页面中没有 %q", want)
+		}
+	}
+	if strings.Count(page, `id="mainContent"`) != 1 || strings.Contains(page, "下一页") {
+		t.Errorf("合并的页面应只有一个正文区且没有翻页链接")
+	}
+}
+
+// 指定 Logger 时以 Debug 级别记录分块位置和检测到的标题
+func TestSplitLogger(t *testing.T) {
+	var buf bytes.Buffer
+	c := &Converter{
+		FileName: "a.txt",
+		Logger:   slog.New(slog.NewTextHandler(&buf, &slog.HandlerOptions{Level: slog.LevelDebug})),
+		HeadingRules: []HeadingRule{
+			{Level: LevelChapter, Pattern: regexp.MustCompile(DefaultChapterPattern), NewChunk: true},
+		},
+	}
+	book, err := c.Split(strings.NewReader("前言\n第1章 开始\n正文\n"))
+	if err != nil {
+		t.Fatalf("Split: %v", err)
+	}
+	defer book.Close()
+	for _, want := range []string{`msg=检测到标题 line=2`, `msg=开始新的一块 chunk=2 line=2 reason=章节标题`} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("日志中没有 %q:\n%s", want, buf.String())
+		}
+	}
+}
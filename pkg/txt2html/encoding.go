@@ -0,0 +1,204 @@
+package txt2html
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"log/slog"
+	"slices"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+const (
+	AutoEncoding     = "auto"    // 自动检测编码
+	detectSampleSize = 64 * 1024 // 检测编码时读取的开头字节数
+	binarySampleSize = 8 * 1024  // 检查是否为二进制文件时读取的开头字节数
+)
+
+// 输入看起来不是文本文件（例如误选了 PDF 或图片），见 Converter.AllowBinary
+var ErrBinary = errors.New("输入看起来不是文本文件")
+
+// 根据输入开头的字节猜测编码：有 BOM 时按 BOM 判断；否则能通过 UTF-8 校验的视为 UTF-8，
+// 其余按 GBK 处理。truncated 表示 sample 只是输入的开头部分，末尾可能截断了一个多字节字符
+func detectEncoding(sample []byte, truncated bool) string {
+	if name := bomEncoding(sample); name != "" {
+		return name
+	}
+
+	if truncated {
+		// 去掉末尾不完整的字符，避免把截断处误判为非法 UTF-8
+		for i := 0; i < utf8.UTFMax-1 && len(sample) > 0; i++ {
+			if r, _ := utf8.DecodeLastRune(sample); r != utf8.RuneError {
+				break
+			}
+			sample = sample[:len(sample)-1]
+		}
+	}
+	if utf8.Valid(sample) {
+		return "utf-8"
+	}
+	return "gbk"
+}
+
+// 开头的 BOM 标明的编码，没有 BOM 时为空
+func bomEncoding(sample []byte) string {
+	switch {
+	case bytes.HasPrefix(sample, []byte{0xEF, 0xBB, 0xBF}):
+		return "utf-8"
+	case bytes.HasPrefix(sample, []byte{0xFF, 0xFE}):
+		return "utf-16le"
+	case bytes.HasPrefix(sample, []byte{0xFE, 0xFF}):
+		return "utf-16be"
+	}
+	return ""
+}
+
+// 根据开头的字节判断输入是否为二进制文件：UTF-16 以外的编码中出现空字节，
+// 或按 encodingName 解码后超过一成是无法解码的替换字符。开头有 BOM 时与解码时一样以 BOM 标明的编码为准，
+// 未指定编码的 UTF-16 文件不会因为其中的空字节被当作二进制文件。truncated 的含义同 detectEncoding
+func looksBinary(sample []byte, encodingName string, truncated bool) bool {
+	if len(sample) == 0 {
+		return false
+	}
+	if name := bomEncoding(sample); name != "" {
+		encodingName = name
+	}
+	if !isUTF16(encodingName) && bytes.IndexByte(sample, 0) >= 0 {
+		return true
+	}
+	decoded, err := LookupEncoding(encodingName).NewDecoder().Bytes(sample)
+	if err != nil {
+		return true
+	}
+	text := string(decoded)
+	runes := utf8.RuneCountInString(text)
+	invalid := strings.Count(text, string(utf8.RuneError))
+	if truncated && strings.HasSuffix(text, string(utf8.RuneError)) {
+		invalid-- // 末尾截断的多字节字符
+	}
+	return invalid*10 > runes
+}
+
+// 统计解码错误：解码器把无法解码的字节替换为 U+FFFD，按行扫描时逐行计数（原文中本来就有的 U+FFFD 也会计入）。
+// marker 不为空时把替换字符换成 marker，在页面中更容易看出出错的位置
+type decodeErrors struct {
+	marker []byte
+	count  int
+	line   int // 已扫描的行数
+	log    *slog.Logger
+}
+
+var replacementChar = []byte(string(utf8.RuneError))
+
+// 代替 bufio.ScanLines 用作 Scanner 的切分函数
+func (d *decodeErrors) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := bufio.ScanLines(data, atEOF)
+	if advance > 0 {
+		d.line++
+	}
+	if n := bytes.Count(token, replacementChar); n > 0 {
+		d.count += n
+		d.log.Debug("解码错误", "line", d.line, "count", n)
+		if len(d.marker) > 0 {
+			token = bytes.ReplaceAll(token, replacementChar, d.marker)
+		}
+	}
+	return advance, token, err
+}
+
+// encodingName 为规范名称
+func isUTF16(encodingName string) bool {
+	return strings.HasPrefix(encodingName, "utf-16")
+}
+
+// 一种支持的输入编码
+type EncodingInfo struct {
+	Name        string // 规范名称，自动检测的结果也使用这个名称
+	Description string
+	Encoding    encoding.Encoding
+}
+
+// 所有支持的输入编码，LookupEncoding 和命令行的编码列表都以此为准
+var encodings = []EncodingInfo{
+	{Name: "utf-8", Description: "UTF-8（默认）", Encoding: unicode.UTF8},
+	{Name: "utf-16", Description: "UTF-16，没有 BOM 时按小端处理", Encoding: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	{Name: "utf-16le", Description: "UTF-16 小端", Encoding: unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)},
+	{Name: "utf-16be", Description: "UTF-16 大端", Encoding: unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)},
+	{Name: "gbk", Description: "GBK 简体中文", Encoding: simplifiedchinese.GBK},
+}
+
+// 编码的别名（已规范化）及对应的规范名称：Windows 记事本把系统默认编码称为“ANSI”，
+// 把 UTF-16 小端称为“Unicode”；GB2312 是 GBK 的子集
+var encodingAliases = map[string]string{
+	"ansi":    "gbk",
+	"gb2312":  "gbk",
+	"cp936":   "gbk",
+	"unicode": "utf-16le",
+}
+
+// 按规范化名称索引的编码，由 encodings 和 encodingAliases 生成
+var encodingIndex = func() map[string]*EncodingInfo {
+	index := make(map[string]*EncodingInfo)
+	for i := range encodings {
+		index[normalizeEncodingName(encodings[i].Name)] = &encodings[i]
+	}
+	for alias, name := range encodingAliases {
+		index[alias] = index[normalizeEncodingName(name)]
+	}
+	return index
+}()
+
+// 规范化编码名称：不区分大小写，忽略空格、下划线和连字符，"UTF_8"、"utf 8" 都视为 "utf8"
+func normalizeEncodingName(name string) string {
+	return strings.Map(func(r rune) rune {
+		switch r {
+		case ' ', '_', '-':
+			return -1
+		}
+		return r
+	}, strings.ToLower(name))
+}
+
+// 返回所有支持的输入编码（不含 AutoEncoding）
+func Encodings() []EncodingInfo {
+	return append([]EncodingInfo(nil), encodings...)
+}
+
+// 返回规范名称为 name 的编码的别名，按字母顺序排列
+func EncodingAliases(name string) []string {
+	var aliases []string
+	for alias, target := range encodingAliases {
+		if target == name {
+			aliases = append(aliases, alias)
+		}
+	}
+	slices.Sort(aliases)
+	return aliases
+}
+
+// 返回编码名称的规范形式（如 "UTF8" 为 "utf-8"、"ansi" 为 "gbk"），"auto" 的各种写法为 AutoEncoding，
+// 不支持时返回空字符串
+func CanonicalEncoding(encodingName string) string {
+	key := normalizeEncodingName(encodingName)
+	if key == AutoEncoding {
+		return AutoEncoding
+	}
+	if info := encodingIndex[key]; info != nil {
+		return info.Name
+	}
+	return ""
+}
+
+// 根据名称返回输入编码，名称的写法见 CanonicalEncoding，不支持时返回 nil。
+// "auto" 不是具体编码，需先用 Converter 检测
+func LookupEncoding(encodingName string) encoding.Encoding {
+	if info := encodingIndex[normalizeEncodingName(encodingName)]; info != nil {
+		return info.Encoding
+	}
+	return nil
+}
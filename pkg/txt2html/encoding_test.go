@@ -0,0 +1,84 @@
+package txt2html
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestDetectEncoding(t *testing.T) {
+	tests := []struct {
+		name      string
+		sample    string
+		truncated bool
+		want      string
+	}{
+		{"UTF-8 BOM", "\xef\xbb\xbfabc", false, "utf-8"},
+		{"UTF-16LE BOM", "\xff\xfea\x00", false, "utf-16le"},
+		{"UTF-16BE BOM", "\xfe\xff\x00a", false, "utf-16be"},
+		{"UTF-8", "中文", false, "utf-8"},
+		{"截断的 UTF-8", "中文"[:5], true, "utf-8"},
+		{"GBK", "\xd6\xd0\xce\xc4", false, "gbk"},
+	}
+	for _, tt := range tests {
+		if got := detectEncoding([]byte(tt.sample), tt.truncated); got != tt.want {
+			t.Errorf("%s: detectEncoding = %s，期望 %s", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCanonicalEncoding(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"utf-8", "utf-8"},
+		{"UTF8", "utf-8"},
+		{"utf_16 LE", "utf-16le"},
+		{"GBK", "gbk"},
+		{"GB_2312", "gbk"},
+		{"ANSI", "gbk"},
+		{"Unicode", "utf-16le"},
+		{"Auto", AutoEncoding},
+		{"latin1", ""},
+	}
+	for _, tt := range tests {
+		if got := CanonicalEncoding(tt.name); got != tt.want {
+			t.Errorf("CanonicalEncoding(%q) = %q，期望 %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestLooksBinary(t *testing.T) {
+	tests := []struct {
+		name     string
+		sample   string
+		encoding string
+		want     bool
+	}{
+		{"空输入", "", "utf-8", false},
+		{"UTF-8 文本", "第一章 开始\n正文", "utf-8", false},
+		{"GBK 文本", "\xd6\xd0\xce\xc4\n", "gbk", false},
+		{"UTF-16 文本中的空字节", "a\x00b\x00", "utf-16le", false},
+		{"空字节", "PNG\x00\x00\x00IHDR", "utf-8", true},
+		{"无法解码", strings.Repeat("\xff\xfe\xfd", 10) + "abc", "utf-8", true},
+		{"少量无法解码", "\xff" + strings.Repeat("正文", 10), "utf-8", false},
+	}
+	for _, tt := range tests {
+		if got := looksBinary([]byte(tt.sample), tt.encoding, false); got != tt.want {
+			t.Errorf("%s: looksBinary = %v，期望 %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSplitRejectsBinary(t *testing.T) {
+	input := "\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR"
+	if _, err := (&Converter{}).Split(strings.NewReader(input)); !errors.Is(err, ErrBinary) {
+		t.Errorf("Split 返回 %v，期望 ErrBinary", err)
+	}
+	book, err := (&Converter{AllowBinary: true}).Split(strings.NewReader(input))
+	if err != nil {
+		t.Fatalf("AllowBinary 时 Split 返回 %v", err)
+	}
+	book.Close()
+}
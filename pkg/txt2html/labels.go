@@ -0,0 +1,359 @@
+package txt2html
+
+// 支持的界面语言，第一个为默认
+var UILanguages = []string{"zh", "en"}
+
+// 页面中阅读脚本用到的界面文字，随页面数据 window.txt2htmlPage.labels 提供，共用的脚本文件不含任何文字
+var scriptLabelKeys = []string{
+	"openSettings", "closeSettings", "darkMode", "lightMode", "vertical", "horizontal", "noWrap", "wrap",
+	"justify", "alignLeft", "indent", "noIndent", "columnCount", "copyBookmark", "copied", "part", "bookmark",
+	"bookmarkName", "remove", "removeBookmark", "jumpError", "readAloud", "pause", "resume", "resumeSpeech",
+}
+
+// 各语言的界面文字，键为文字的用途；含 %d、%s 的是格式，页面模板中用 printf 填入，脚本中用 label() 填入。
+// 增加语言时在 UILanguages 中加上它的名称，缺少的键显示默认语言的文字
+var uiLabels = map[string]map[string]string{
+	"zh": {
+		"lang":                     "zh-CN",
+		"partTitle":                "第%d部分",
+		"part":                     "第 %d 部分",
+		"partOf":                   "第 %d / %d 部分",
+		"partCount":                "共 %d 部分",
+		"chunkStats":               "%d 字符 · 约 %d 字 · 预计阅读 %d 分钟",
+		"skipToContent":            "跳到正文",
+		"readingProgress":          "本部分阅读进度",
+		"settings":                 "阅读设置",
+		"openSettings":             "☰ 阅读设置",
+		"closeSettings":            "✕ 关闭设置",
+		"quickNav":                 "快捷导航",
+		"backToTop":                "回到顶部",
+		"index":                    "目录",
+		"fontSize":                 "字体大小调节",
+		"decreaseFont":             "减小字体",
+		"increaseFont":             "增大字体",
+		"fontWeight":               "字体粗细",
+		"lighter":                  "变细",
+		"bolder":                   "加粗",
+		"lineHeight":               "行距调节",
+		"decreaseLineHeight":       "减小行距",
+		"increaseLineHeight":       "增大行距",
+		"lineHeightDown":           "行距-",
+		"lineHeightUp":             "行距+",
+		"paragraphSpacing":         "段落间距",
+		"decreaseParagraphSpacing": "减小段落间距",
+		"increaseParagraphSpacing": "增大段落间距",
+		"paragraphSpacingDown":     "段距-",
+		"paragraphSpacingUp":       "段距+",
+		"columns":                  "分栏",
+		"fewerColumns":             "减少分栏",
+		"moreColumns":              "增加分栏",
+		"columnsDown":              "栏-",
+		"columnsUp":                "栏+",
+		"columnCount":              "%d 栏",
+		"fontFamily":               "字体选择",
+		"defaultFont":              "默认字体",
+		"fontSerif":                "衬线体 (serif)",
+		"fontSansSerif":            "无衬线体 (sans-serif)",
+		"fontMonospace":            "等宽字体 (monospace)",
+		"fontNotoSerif":            "思源宋体 (Noto Serif CJK SC)",
+		"fontNotoSans":             "思源黑体 (Noto Sans CJK SC)",
+		"fontYaHei":                "微软雅黑 (Microsoft YaHei)",
+		"fontKaiTi":                "楷体 (KaiTi)",
+		"theme":                    "阅读主题",
+		"chooseTheme":              "阅读主题选择",
+		"themeCustom":              "自定义",
+		"themePaperwhite":          "纸白 (Paperwhite)",
+		"themeSepia":               "复古 (Sepia)",
+		"themeSolarized":           "日晒 (Solarized)",
+		"themeNight":               "夜读 (Night)",
+		"textColor":                "字体颜色选择",
+		"colorBlack":               "黑色",
+		"colorSlate":               "深石板灰（护眼）",
+		"colorDefaultGray":         "默认深灰",
+		"colorGray":                "中灰",
+		"colorWarmBrown":           "温暖棕（护眼）",
+		"colorDarkBlue":            "深蓝",
+		"colorDarkGreen":           "深绿（护眼）",
+		"colorPurple":              "紫色",
+		"colorSoftBrown":           "柔和棕（护眼）",
+		"colorSoftGray":            "柔和深灰",
+		"colorSolarized":           "日晒灰蓝",
+		"colorNight":               "夜读浅灰",
+		"background":               "背景颜色选择",
+		"centerBackground":         "中间背景",
+		"chooseCenterBackground":   "中间背景颜色选择",
+		"leftBackground":           "左侧背景",
+		"chooseLeftBackground":     "左侧背景颜色选择",
+		"rightBackground":          "右侧背景",
+		"chooseRightBackground":    "右侧背景颜色选择",
+		"sideBackground":           "两侧背景",
+		"chooseSideBackground":     "两侧背景颜色选择",
+		"bgWhite":                  "白色",
+		"bgWarmWhite":              "暖白/米色",
+		"bgCream":                  "柔和乳白",
+		"bgLightYellow":            "浅黄",
+		"bgEyeGreen":               "护眼绿（浅）",
+		"bgEyeBlue":                "护眼蓝（浅）",
+		"bgSepia":                  "复古黄",
+		"bgSolarized":              "日晒米黄",
+		"bgNight":                  "夜读深灰",
+		"bgLightGray":              "浅灰",
+		"bgWarmWhiteEye":           "暖白/米色（护眼）",
+		"bgCreamEye":               "柔和乳白（护眼）",
+		"bgLightYellowEye":         "浅黄（护眼）",
+		"bgLightGreen":             "浅绿",
+		"bgLightPurple":            "浅紫",
+		"bgBeigeGray":              "米灰",
+		"bgDarkSepia":              "复古深黄",
+		"bgSolarizedSide":          "日晒浅黄",
+		"bgNightSide":              "夜读黑",
+		"darkMode":                 "夜间模式",
+		"lightMode":                "日间模式",
+		"direction":                "排版方向",
+		"vertical":                 "竖排",
+		"horizontal":               "横排",
+		"longLines":                "长行",
+		"noWrap":                   "不换行",
+		"wrap":                     "自动换行",
+		"alignment":                "对齐",
+		"justify":                  "两端对齐",
+		"alignLeft":                "左对齐",
+		"indent":                   "首行缩进",
+		"noIndent":                 "取消缩进",
+		"readAloud":                "朗读",
+		"pause":                    "暂停",
+		"resume":                   "继续",
+		"resumeSpeech":             "继续朗读",
+		"stop":                     "停止",
+		"speechRate":               "朗读语速",
+		"findInPage":               "页内查找",
+		"findText":                 "查找内容",
+		"find":                     "查找",
+		"nextMatch":                "下一个匹配",
+		"previousMatch":            "上一个匹配",
+		"searchBook":               "全书搜索",
+		"bookmarks":                "书签",
+		"bookmark":                 "书签",
+		"copyBookmark":             "复制书签链接",
+		"copied":                   "已复制",
+		"addBookmark":              "添加书签",
+		"bookmarkList":             "书签列表",
+		"countOpen":                "（",
+		"countClose":               "）",
+		"bookmarkName":             "书签名称",
+		"remove":                   "删除",
+		"removeBookmark":           "删除书签 %s",
+		"print":                    "打印",
+		"printPage":                "打印本页",
+		"jump":                     "跳转",
+		"jumpTo":                   "跳转到第几部分",
+		"jumpError":                "请输入 1 到 %d 之间的整数",
+		"resumeReading":            "继续阅读",
+		"resumeReadingAt":          "继续阅读：%s",
+		"fileInfo":                 "文件信息",
+		"sourceFile":               "原文件",
+		"modified":                 "修改时间",
+		"generated":                "转换时间",
+		"generator":                "程序版本",
+		"pageNav":                  "翻页",
+		"prevPage":                 "上一页",
+		"nextPage":                 "下一页",
+		"chapters":                 "章节目录",
+		"parts":                    "分块列表",
+		"partSize":                 "约 %.2f KB",
+		"tocDepth":                 "显示到第 %d 级",
+		"backToIndex":              "返回目录",
+		"searchPlaceholder":        "输入要查找的内容",
+		"search":                   "搜索",
+		"searchIndexError":         "未能加载搜索索引 %s",
+		"searchResult":             "第 %d 部分 · 第 %d 行",
+		"noMatches":                "没有找到匹配的内容",
+		"matches":                  "共找到 %d 处",
+		"matchesTruncated":         "共找到 %d 处，仅显示前 %d 处",
+	},
+	"en": {
+		"lang":                     "en",
+		"partTitle":                "Part %d",
+		"part":                     "Part %d",
+		"partOf":                   "Part %d of %d",
+		"partCount":                "%d parts",
+		"chunkStats":               "%d characters · about %d words · %d min read",
+		"skipToContent":            "Skip to content",
+		"readingProgress":          "Reading progress of this part",
+		"settings":                 "Reading settings",
+		"openSettings":             "☰ Settings",
+		"closeSettings":            "✕ Close settings",
+		"quickNav":                 "Quick navigation",
+		"backToTop":                "Back to top",
+		"index":                    "Contents",
+		"fontSize":                 "Font size",
+		"decreaseFont":             "Decrease font size",
+		"increaseFont":             "Increase font size",
+		"fontWeight":               "Font weight",
+		"lighter":                  "Lighter",
+		"bolder":                   "Bolder",
+		"lineHeight":               "Line height",
+		"decreaseLineHeight":       "Decrease line height",
+		"increaseLineHeight":       "Increase line height",
+		"lineHeightDown":           "Line-",
+		"lineHeightUp":             "Line+",
+		"paragraphSpacing":         "Paragraph spacing",
+		"decreaseParagraphSpacing": "Decrease paragraph spacing",
+		"increaseParagraphSpacing": "Increase paragraph spacing",
+		"paragraphSpacingDown":     "Gap-",
+		"paragraphSpacingUp":       "Gap+",
+		"columns":                  "Columns",
+		"fewerColumns":             "Fewer columns",
+		"moreColumns":              "More columns",
+		"columnsDown":              "Col-",
+		"columnsUp":                "Col+",
+		"columnCount":              "%d col",
+		"fontFamily":               "Font",
+		"defaultFont":              "Default font",
+		"fontSerif":                "Serif",
+		"fontSansSerif":            "Sans-serif",
+		"fontMonospace":            "Monospace",
+		"fontNotoSerif":            "Noto Serif CJK SC",
+		"fontNotoSans":             "Noto Sans CJK SC",
+		"fontYaHei":                "Microsoft YaHei",
+		"fontKaiTi":                "KaiTi",
+		"theme":                    "Theme",
+		"chooseTheme":              "Choose a reading theme",
+		"themeCustom":              "Custom",
+		"themePaperwhite":          "Paperwhite",
+		"themeSepia":               "Sepia",
+		"themeSolarized":           "Solarized",
+		"themeNight":               "Night",
+		"textColor":                "Text color",
+		"colorBlack":               "Black",
+		"colorSlate":               "Dark slate gray (easy on the eyes)",
+		"colorDefaultGray":         "Dark gray (default)",
+		"colorGray":                "Gray",
+		"colorWarmBrown":           "Warm brown (easy on the eyes)",
+		"colorDarkBlue":            "Dark blue",
+		"colorDarkGreen":           "Dark green (easy on the eyes)",
+		"colorPurple":              "Purple",
+		"colorSoftBrown":           "Soft brown (easy on the eyes)",
+		"colorSoftGray":            "Soft dark gray",
+		"colorSolarized":           "Solarized gray-blue",
+		"colorNight":               "Night light gray",
+		"background":               "Background color",
+		"centerBackground":         "Center",
+		"chooseCenterBackground":   "Center background color",
+		"leftBackground":           "Left",
+		"chooseLeftBackground":     "Left background color",
+		"rightBackground":          "Right",
+		"chooseRightBackground":    "Right background color",
+		"sideBackground":           "Sides",
+		"chooseSideBackground":     "Side background color",
+		"bgWhite":                  "White",
+		"bgWarmWhite":              "Warm white",
+		"bgCream":                  "Cream",
+		"bgLightYellow":            "Light yellow",
+		"bgEyeGreen":               "Pale green",
+		"bgEyeBlue":                "Pale blue",
+		"bgSepia":                  "Sepia",
+		"bgSolarized":              "Solarized beige",
+		"bgNight":                  "Night dark gray",
+		"bgLightGray":              "Light gray",
+		"bgWarmWhiteEye":           "Warm white (easy on the eyes)",
+		"bgCreamEye":               "Cream (easy on the eyes)",
+		"bgLightYellowEye":         "Light yellow (easy on the eyes)",
+		"bgLightGreen":             "Light green",
+		"bgLightPurple":            "Light purple",
+		"bgBeigeGray":              "Beige gray",
+		"bgDarkSepia":              "Dark sepia",
+		"bgSolarizedSide":          "Solarized light yellow",
+		"bgNightSide":              "Night black",
+		"darkMode":                 "Dark mode",
+		"lightMode":                "Light mode",
+		"direction":                "Text direction",
+		"vertical":                 "Vertical",
+		"horizontal":               "Horizontal",
+		"longLines":                "Long lines",
+		"noWrap":                   "No wrap",
+		"wrap":                     "Wrap",
+		"alignment":                "Alignment",
+		"justify":                  "Justify",
+		"alignLeft":                "Align left",
+		"indent":                   "Indent",
+		"noIndent":                 "No indent",
+		"readAloud":                "Read aloud",
+		"pause":                    "Pause",
+		"resume":                   "Resume",
+		"resumeSpeech":             "Resume reading aloud",
+		"stop":                     "Stop",
+		"speechRate":               "Speech rate",
+		"findInPage":               "Find in page",
+		"findText":                 "Find text",
+		"find":                     "Find",
+		"nextMatch":                "Next match",
+		"previousMatch":            "Previous match",
+		"searchBook":               "Search book",
+		"bookmarks":                "Bookmarks",
+		"bookmark":                 "Bookmark",
+		"copyBookmark":             "Copy bookmark link",
+		"copied":                   "Copied",
+		"addBookmark":              "Add bookmark",
+		"bookmarkList":             "Bookmarks",
+		"countOpen":                " (",
+		"countClose":               ")",
+		"bookmarkName":             "Bookmark name",
+		"remove":                   "Delete",
+		"removeBookmark":           "Delete bookmark %s",
+		"print":                    "Print",
+		"printPage":                "Print this page",
+		"jump":                     "Go to",
+		"jumpTo":                   "Part number to go to",
+		"jumpError":                "Enter a whole number from 1 to %d",
+		"resumeReading":            "Continue reading",
+		"resumeReadingAt":          "Continue reading: %s",
+		"fileInfo":                 "File info",
+		"sourceFile":               "Source file",
+		"modified":                 "Modified",
+		"generated":                "Converted",
+		"generator":                "Generator",
+		"pageNav":                  "Pages",
+		"prevPage":                 "Previous",
+		"nextPage":                 "Next",
+		"chapters":                 "Chapters",
+		"parts":                    "Parts",
+		"partSize":                 "about %.2f KB",
+		"tocDepth":                 "Show %d levels",
+		"backToIndex":              "Back to contents",
+		"searchPlaceholder":        "Enter text to find",
+		"search":                   "Search",
+		"searchIndexError":         "Could not load the search index %s",
+		"searchResult":             "Part %d · line %d",
+		"noMatches":                "No matches found",
+		"matches":                  "%d matches",
+		"matchesTruncated":         "%d matches, showing the first %d",
+	},
+}
+
+// 界面语言 lang 中 key 的文字，lang 为空或不支持时使用默认语言
+func UILabel(lang, key string) string {
+	if text, ok := uiLabels[lang][key]; ok {
+		return text
+	}
+	return uiLabels[UILanguages[0]][key]
+}
+
+// 界面语言 lang 中 keys 的文字，用于传给页面脚本
+func UILabels(lang string, keys ...string) map[string]string {
+	labels := make(map[string]string, len(keys))
+	for _, key := range keys {
+		labels[key] = UILabel(lang, key)
+	}
+	return labels
+}
+
+// 页面模板中使用的界面文字，如 {{.Label "settings"}}
+func (d TemplateData) Label(key string) string {
+	return UILabel(d.UILang, key)
+}
+
+// 阅读脚本使用的界面文字
+func (d TemplateData) ScriptLabels() map[string]string {
+	return UILabels(d.UILang, scriptLabelKeys...)
+}
@@ -0,0 +1,166 @@
+package txt2html
+
+import (
+	"bufio"
+	"bytes"
+	"strings"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// Markdown 渲染器：启用 GFM 扩展（表格、删除线、自动链接等），
+// 不开启 unsafe，正文中的原始HTML会被转义而不是原样输出。
+// 按 XHTML 输出（<br />、<hr /> 等），这样同一份渲染结果也能直接放进 EPUB
+var markdownRenderer = goldmark.New(
+	goldmark.WithExtensions(extension.GFM),
+	goldmark.WithRendererOptions(html.WithXHTML()),
+)
+
+// 按 Markdown 块读取内容：以代码块外的空行为界，把段落、标题、列表、代码块各自作为一个切分单位，
+// 保证切分不会发生在代码块或多行列表项的中间。每块单独渲染，因此跨块的引用式链接不会生效
+type markdownUnits struct {
+	scanner *bufio.Scanner
+	pending string // 预读到、属于下一块的行
+	hasNext bool
+	lineNo  int // 已读取的行数
+	pendNo  int // pending 的行号
+	failed  error
+}
+
+func newMarkdownUnits(scanner *bufio.Scanner) *markdownUnits {
+	return &markdownUnits{scanner: scanner}
+}
+
+func (m *markdownUnits) next() (contentUnit, bool) {
+	var lines []string
+	inFence := false
+	isList := false
+	blankRun := 0  // 列表块中暂存的空行数，要看下一行才能决定是否属于当前列表
+	firstLine := 0 // 块第一行的行号
+
+	for {
+		var line string
+		var lineNo int
+		if m.hasNext {
+			line, lineNo, m.hasNext = m.pending, m.pendNo, false
+		} else if m.scanner.Scan() {
+			m.lineNo++
+			line, lineNo = normalizeLine(m.scanner.Text()), m.lineNo
+		} else {
+			break
+		}
+
+		if inFence {
+			lines = append(lines, line)
+			if isCodeFence(line) {
+				inFence = false
+			}
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			if len(lines) == 0 {
+				continue // 跳过块之间多余的空行
+			}
+			if isList {
+				blankRun++
+				continue
+			}
+			break
+		}
+
+		if len(lines) > 0 {
+			// 列表中空行之后的缩进内容或新的列表项仍属于同一个列表
+			if blankRun > 0 && !(isIndented(line) || isListItem(line)) {
+				m.pending, m.pendNo, m.hasNext = line, lineNo, true
+				break
+			}
+			// ATX 标题总是单独成块，便于按章节切分
+			if isATXHeading(line) {
+				m.pending, m.pendNo, m.hasNext = line, lineNo, true
+				break
+			}
+		}
+		for ; blankRun > 0; blankRun-- {
+			lines = append(lines, "")
+		}
+
+		lines = append(lines, line)
+		if len(lines) == 1 {
+			firstLine = lineNo
+			isList = isListItem(line)
+			if isATXHeading(line) {
+				break
+			}
+		}
+		if isCodeFence(line) {
+			inFence = true
+		}
+	}
+
+	if len(lines) == 0 {
+		return contentUnit{}, false
+	}
+	raw := strings.Join(lines, "\n")
+	var buf bytes.Buffer
+	if err := markdownRenderer.Convert([]byte(raw), &buf); err != nil {
+		m.failed = err
+		return contentUnit{}, false
+	}
+	unit := contentUnit{raw: raw, html: buf.String(), line: firstLine}
+	if len(lines) == 1 && isATXHeading(lines[0]) {
+		// 只有标题块参与章节检测，检测时去掉 # 标记
+		unit.title = strings.Trim(strings.TrimSpace(lines[0]), "# \t")
+	}
+	return unit, true
+}
+
+func (m *markdownUnits) lines() int {
+	return m.lineNo
+}
+
+func (m *markdownUnits) err() error {
+	if m.failed != nil {
+		return m.failed
+	}
+	return m.scanner.Err()
+}
+
+// 代码块围栏：行首最多3个空格后跟 ``` 或 ~~~
+func isCodeFence(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	if len(line)-len(trimmed) > 3 {
+		return false
+	}
+	return strings.HasPrefix(trimmed, "```") || strings.HasPrefix(trimmed, "~~~")
+}
+
+// ATX 标题：# 到 ###### 后跟空格或行尾
+func isATXHeading(line string) bool {
+	trimmed := strings.TrimLeft(line, " ")
+	level := len(trimmed) - len(strings.TrimLeft(trimmed, "#"))
+	if level < 1 || level > 6 {
+		return false
+	}
+	return len(trimmed) == level || trimmed[level] == ' ' || trimmed[level] == '\t'
+}
+
+// 列表项：- * + 或 "1." "1)" 后跟空格
+func isListItem(line string) bool {
+	trimmed := strings.TrimLeft(line, " \t")
+	if len(trimmed) >= 2 && strings.ContainsRune("-*+", rune(trimmed[0])) && trimmed[1] == ' ' {
+		return true
+	}
+	digits := len(trimmed) - len(strings.TrimLeft(trimmed, "0123456789"))
+	if digits == 0 || digits > 9 || len(trimmed) < digits+2 {
+		return false
+	}
+	return (trimmed[digits] == '.' || trimmed[digits] == ')') && trimmed[digits+1] == ' '
+}
+
+// 缩进至少两个空格或一个制表符的行，视为列表项的续行
+func isIndented(line string) bool {
+	return strings.HasPrefix(line, "  ") || strings.HasPrefix(line, "\t")
+}
@@ -0,0 +1,56 @@
+package txt2html
+
+import "strings"
+
+// 整行注释的开头和结尾：HTML 注释、样式和脚本中的块注释，以及脚本中的行注释（结尾为空表示到行尾）
+var commentMarkers = []struct{ start, end string }{
+	{"<!--", "-->"},
+	{"/*", "*/"},
+	{"//", ""},
+}
+
+// 压缩内置页面模板、样式和脚本：去掉每行首尾的空白、空行以及单独成行的注释。
+// 换行全部保留，脚本依赖换行自动补分号，合并成一行会改变含义；模板中没有 <pre> 等保留空白的元素，
+// 正文只来自 {{.Content}}，不受影响。只处理从行首开始的注释，行尾的注释可能在字符串或链接中，保持原样
+func minifyText(src string) string {
+	var b strings.Builder
+	b.Grow(len(src))
+	end := "" // 正在跳过的多行注释的结束标记
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		for line != "" {
+			if end != "" {
+				i := strings.Index(line, end)
+				if i < 0 {
+					line = ""
+					break
+				}
+				line = strings.TrimSpace(line[i+len(end):])
+				end = ""
+				continue
+			}
+			comment := false
+			for _, marker := range commentMarkers {
+				if strings.HasPrefix(line, marker.start) {
+					comment = true
+					if marker.end == "" {
+						line = ""
+					} else {
+						line = line[len(marker.start):]
+						end = marker.end
+					}
+					break
+				}
+			}
+			if !comment {
+				break
+			}
+		}
+		if line == "" {
+			continue
+		}
+		b.WriteString(line)
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
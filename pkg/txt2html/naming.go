@@ -0,0 +1,188 @@
+package txt2html
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const DefaultNamePattern = "{base}_chunk_{n}.html" // 默认的分块文件名模板
+
+// 文件名模板中的占位符：{base}、{n}、{total}，数字占位符可带 printf 风格的宽度，如 {n:04d}
+var namePlaceholderRe = regexp.MustCompile(`\{([a-z]+)(?::([^}]*))?\}`)
+
+// 数字占位符允许的格式：d，或以 0 填充到指定宽度的 0Nd。不允许 {n:4d} 这样用空格填充，文件名中会出现空格
+var nameWidthRe = regexp.MustCompile(`^(0[0-9]{1,2})?d$`)
+
+// 分块文件名模板，零值等同于 DefaultNamePattern
+type NamePattern struct {
+	pattern  string
+	keepBase bool // {base} 保留原样，否则经 SafeFileName 处理
+}
+
+// 解析并校验文件名模板：只允许已知的占位符，必须包含 {n} 才能让每块的文件名不同，
+// 且不能包含路径分隔符（分块之间、目录页与分块之间都按同一目录下的相对路径链接）
+func ParseNamePattern(pattern string) (NamePattern, error) {
+	if strings.ContainsAny(pattern, `/\`) {
+		return NamePattern{}, fmt.Errorf("文件名模板不能包含路径分隔符: %s", pattern)
+	}
+	hasNumber := false
+	for _, m := range namePlaceholderRe.FindAllStringSubmatch(pattern, -1) {
+		switch m[1] {
+		case "base":
+			if m[2] != "" {
+				return NamePattern{}, fmt.Errorf("文件名模板中 {base} 不支持格式: %s", m[0])
+			}
+		case "n", "total":
+			if m[2] != "" && !nameWidthRe.MatchString(m[2]) {
+				return NamePattern{}, fmt.Errorf("文件名模板中无效的数字格式: %s（示例: {n:04d}）", m[0])
+			}
+			if m[1] == "n" {
+				hasNumber = true
+			}
+		default:
+			return NamePattern{}, fmt.Errorf("文件名模板中未知的占位符: %s（可用: {base} {n} {total}）", m[0])
+		}
+	}
+	if !hasNumber {
+		return NamePattern{}, fmt.Errorf("文件名模板必须包含块序号 {n}: %s", pattern)
+	}
+	return NamePattern{pattern: pattern}, nil
+}
+
+// {base} 的值：去掉扩展名的 fileName
+func (p NamePattern) base(fileName string) string {
+	if !p.keepBase {
+		fileName = SafeFileName(fileName)
+	}
+	return fileName[:len(fileName)-len(filepath.Ext(fileName))]
+}
+
+// 生成第 chunk 块（共 total 块）的文件名
+func (p NamePattern) format(fileName string, chunk, total int) string {
+	pattern := p.pattern
+	if pattern == "" {
+		pattern = DefaultNamePattern
+	}
+	baseName := p.base(fileName)
+	return namePlaceholderRe.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		m := namePlaceholderRe.FindStringSubmatch(placeholder)
+		value := chunk
+		switch m[1] {
+		case "base":
+			return baseName
+		case "total":
+			value = total
+		}
+		if m[2] == "" {
+			return fmt.Sprint(value)
+		}
+		return fmt.Sprintf("%"+m[2], value)
+	})
+}
+
+// 页面中按块序号拼出文件名用的模板：替换 {base} 和 {total}，只保留 {n} 占位符
+func (p NamePattern) chunkPattern(fileName string, total int) string {
+	pattern := p.pattern
+	if pattern == "" {
+		pattern = DefaultNamePattern
+	}
+	baseName := p.base(fileName)
+	return namePlaceholderRe.ReplaceAllStringFunc(pattern, func(placeholder string) string {
+		m := namePlaceholderRe.FindStringSubmatch(placeholder)
+		switch m[1] {
+		case "base":
+			return baseName
+		case "total":
+			if m[2] == "" {
+				return fmt.Sprint(total)
+			}
+			return fmt.Sprintf("%"+m[2], total)
+		}
+		return placeholder
+	})
+}
+
+// 生成第 first 块到第 total 块的文件名，并确认它们互不相同且不会与目录页等其他输出文件重名。
+// existing 为已有的前 first-1 块的文件名
+func (p NamePattern) chunkNames(fileName string, first, total int, existing, reserved []string) ([]string, error) {
+	seen := make(map[string]int, total+len(reserved))
+	for _, name := range reserved {
+		seen[name] = 0
+	}
+	for i, name := range existing {
+		seen[name] = i + 1
+	}
+	names := make([]string, 0, total-first+1)
+	for chunk := first; chunk <= total; chunk++ {
+		name := p.format(fileName, chunk, total)
+		if prev, ok := seen[name]; ok {
+			if prev == 0 {
+				return nil, fmt.Errorf("第 %d 块的文件名 %s 与其他输出文件重名", chunk, name)
+			}
+			return nil, fmt.Errorf("第 %d 块与第 %d 块的文件名相同: %s", prev, chunk, name)
+		}
+		seen[name] = chunk
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// 连续的替换字符合并为一个，含 - 时合并为 -
+var safeNameRunRe = regexp.MustCompile(`[-_]*-[-_]*|__+`)
+
+// 把文件名转换为便于用作网址和在静态服务器上发布的形式：空白替换为 _，
+// ASCII 中除字母、数字和 . _ - ( ) 以外的字符（# ? % & 引号、斜杠等）以及控制字符替换为 -，
+// 汉字等其他文字和全角标点保留。开头的 . 和首尾多余的 _ - 去掉，扩展名保持不变；
+// 全部字符都被替换时返回 "book" 加扩展名
+func SafeFileName(name string) string {
+	ext := filepath.Ext(name)
+	if !isSafeExt(ext) {
+		ext = ""
+	}
+	base := name[:len(name)-len(ext)]
+	var b strings.Builder
+	for _, r := range base {
+		switch {
+		case unicode.IsSpace(r):
+			b.WriteByte('_')
+		case r < utf8.RuneSelf:
+			if 'a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9' || strings.ContainsRune("._-()", r) {
+				b.WriteRune(r)
+			} else {
+				b.WriteByte('-')
+			}
+		case unicode.IsGraphic(r):
+			b.WriteRune(r)
+		default:
+			b.WriteByte('-')
+		}
+	}
+	base = safeNameRunRe.ReplaceAllStringFunc(b.String(), func(run string) string {
+		if strings.Contains(run, "-") {
+			return "-"
+		}
+		return "_"
+	})
+	base = strings.TrimRight(strings.TrimLeft(base, "._-"), "_-")
+	if base == "" {
+		base = "book"
+	}
+	return base + ext
+}
+
+// 扩展名只含字母和数字时原样保留，否则作为文件名的一部分处理
+func isSafeExt(ext string) bool {
+	if len(ext) < 2 {
+		return false
+	}
+	for _, r := range ext[1:] {
+		if !('a' <= r && r <= 'z' || 'A' <= r && r <= 'Z' || '0' <= r && r <= '9') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,27 @@
+package txt2html
+
+import "testing"
+
+func TestParseNamePattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		valid   bool
+	}{
+		{"{base}_chunk_{n}.html", true},
+		{"{n:d}.html", true},
+		{"{n:04d}-of-{total:04d}.html", true},
+		{"{base}.html", false},         // 缺少 {n}
+		{"{n:4d}.html", false},         // 空格填充
+		{"{n:x}.html", false},          // 不是十进制
+		{"{n:0004d}.html", false},      // 宽度过大
+		{"{base:04d}_{n}.html", false}, // {base} 不支持格式
+		{"{name}_{n}.html", false},     // 未知的占位符
+		{"sub/{n}.html", false},        // 路径分隔符
+	}
+	for _, tt := range tests {
+		_, err := ParseNamePattern(tt.pattern)
+		if got := err == nil; got != tt.valid {
+			t.Errorf("ParseNamePattern(%q) = %v，期望有效 = %v", tt.pattern, err, tt.valid)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package txt2html
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// 全书搜索索引：按行记录 [块序号, 行号, 行文本]，切分时边读边写入暂存文件，
+// 不在内存中保留全文。最终输出为一段给全局变量赋值的JS而不是JSON，
+// 这样直接双击打开（file://）的搜索页面也能用 <script> 加载，不受 fetch 跨域限制
+type searchIndex struct {
+	file    *os.File
+	w       *bufio.Writer
+	count   int
+	buf     bytes.Buffer  // 编码一行文本用的缓冲
+	encoder *json.Encoder // 写入 buf，不把 < > & 转义为 \u003c 等，索引不会比正文大很多
+}
+
+func newSearchIndex(dir string) (*searchIndex, error) {
+	file, err := os.Create(filepath.Join(dir, "search.part"))
+	if err != nil {
+		return nil, err
+	}
+	s := &searchIndex{file: file, w: bufio.NewWriter(file)}
+	s.encoder = json.NewEncoder(&s.buf)
+	s.encoder.SetEscapeHTML(false)
+	return s, nil
+}
+
+// 记录第 chunk 块中的一行，line 为该行在输入中的行号，空行不记录
+func (s *searchIndex) add(chunk, line int, text string) error {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil
+	}
+	s.buf.Reset()
+	if err := s.encoder.Encode(text); err != nil {
+		return err
+	}
+	// 脚本中只需避免出现 </，Encode 末尾的换行去掉
+	encoded := bytes.ReplaceAll(bytes.TrimSuffix(s.buf.Bytes(), []byte("\n")), []byte("</"), []byte(`<\/`))
+	if s.count > 0 {
+		s.w.WriteString(",\n")
+	}
+	s.count++
+	_, err := fmt.Fprintf(s.w, "[%d,%d,%s]", chunk, line, encoded)
+	return err
+}
+
+// 写完暂存文件，之后才能调用 writeTo
+func (s *searchIndex) close() error {
+	if err := s.w.Flush(); err != nil {
+		s.file.Close()
+		return err
+	}
+	return s.file.Close()
+}
+
+const (
+	searchLinesStart = "\"lines\": [\n"
+	searchLinesEnd   = "\n]};\n"
+)
+
+// 输出索引脚本：files 为各块的文件名，lines 中的块序号对应 files 的下标+1。
+// prior 不为 nil 时是之前输出的索引脚本，其中的行放在新内容之前
+func (s *searchIndex) writeTo(w io.Writer, files []string, prior io.Reader) error {
+	encodedFiles, err := json.Marshal(files)
+	if err != nil {
+		return err
+	}
+	var priorLines []byte
+	if prior != nil {
+		if priorLines, err = searchIndexLines(prior); err != nil {
+			return err
+		}
+	}
+
+	part, err := os.Open(s.file.Name())
+	if err != nil {
+		return err
+	}
+	defer part.Close()
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "window.txt2htmlSearch = {\"files\": %s, %s", encodedFiles, searchLinesStart)
+	bw.Write(priorLines)
+	if len(priorLines) > 0 && s.count > 0 {
+		bw.WriteString(",\n")
+	}
+	if _, err := io.Copy(bw, part); err != nil {
+		return err
+	}
+	bw.WriteString(searchLinesEnd)
+	return bw.Flush()
+}
+
+// 取出之前输出的索引脚本中 lines 数组的内容
+func searchIndexLines(r io.Reader) ([]byte, error) {
+	script, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	start := bytes.Index(script, []byte(searchLinesStart))
+	if start < 0 || !bytes.HasSuffix(script, []byte(searchLinesEnd)) {
+		return nil, fmt.Errorf("无法识别已有的搜索索引")
+	}
+	return script[start+len(searchLinesStart) : len(script)-len(searchLinesEnd)], nil
+}
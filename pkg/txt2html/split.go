@@ -0,0 +1,742 @@
+package txt2html
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"html/template"
+	"log/slog"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const readingCharsPerMinute = 300 // 估算阅读时间用的阅读速度：每分钟约300个汉字（或单词）
+
+// 首行缩进时插在纯文本每段开头的标记，本身没有宽度，由页面样式决定是否缩进
+const paragraphIndentTag = `<span class="indent"></span>`
+
+// 每行一段时插在纯文本每行开头的标记，页面中显示为段落间距，每块开头的标记由样式隐藏
+const paragraphGapTag = `<span class="paragraph-gap"></span>`
+
+// 逐段显示时包裹每块后续各段正文的标签：未显示的段保留在页面中但不排版，由页面脚本在滚动接近时显示，
+// 浏览器的页内查找仍能找到并展开其中的内容
+const (
+	revealOpenTag  = `<div class="reveal" hidden="until-found">`
+	revealCloseTag = `</div>`
+)
+
+// 默认的章节标题匹配规则，匹配行首的“第十二章”“第 3 章”等
+const DefaultChapterPattern = `^[\s　]*第[\s　]*[0-9０-９零〇一二三四五六七八九十百千万两]{1,8}[\s　]*章`
+
+// 默认的卷、节标题匹配规则，写法同章节
+const (
+	DefaultVolumePattern  = `^[\s　]*第[\s　]*[0-9０-９零〇一二三四五六七八九十百千万两]{1,8}[\s　]*[卷部]`
+	DefaultSectionPattern = `^[\s　]*第[\s　]*[0-9０-９零〇一二三四五六七八九十百千万两]{1,8}[\s　]*节`
+)
+
+// 标题层级，数字越小层级越高
+const (
+	LevelVolume  = 1 // 卷
+	LevelChapter = 2 // 章
+	LevelSection = 3 // 节
+)
+
+// 标题匹配规则
+type HeadingRule struct {
+	Level    int            // 标题层级：LevelVolume、LevelChapter 或 LevelSection
+	Pattern  *regexp.Regexp // 匹配标题行（Markdown 模式下为去掉 # 后的标题文字）
+	NewChunk bool           // 匹配的标题总是从新的一块开始
+}
+
+// 检测到的章节标题及其所在的块
+type ChapterHeading struct {
+	Text   string
+	Chunk  int
+	Anchor string // 页面内锚点ID
+	Level  int    // 标题层级：卷、章、节
+}
+
+// 单个分块的统计信息
+type ChunkStats struct {
+	CharCount int    // 字符数（按 rune 计，不含换行）
+	WordCount int    // 字数：每个汉字计一个，连续的字母数字计一个单词
+	Chapter   string // 本块开头处所在的章节，未检测到章节时为空
+}
+
+// 切分结果
+type splitResult struct {
+	spool     *chunkSpool
+	lines     int // 读取到的最后一行的行号
+	headings  []ChapterHeading
+	stats     []ChunkStats // 每块的统计信息，下标为暂存区中的块序号-1
+	search    *searchIndex // 全书搜索索引，未启用时为 nil
+	truncated bool         // 只切分第一块时，第一块之后还有内容没有读取
+}
+
+// 切分的最小单位：纯文本模式下为一行，Markdown 模式下为一个块（段落、标题、列表、代码块等）
+type contentUnit struct {
+	raw    string // 原始文本，用于统计字数
+	html   string // 转义或渲染后的HTML
+	title  string // 用于检测章节标题的文字，为空表示不可能是章节标题
+	inline bool   // 行内单位：章节锚点包裹整行；否则在块前插入空锚点
+	line   int    // raw 第一行在输入中的行号，从1开始
+	// 由超长的行折出的后续部分，与前一个单位同属原文的一行，不再标注行号
+	continued bool
+}
+
+// 按顺序产生切分单位的来源
+type unitSource interface {
+	next() (contentUnit, bool)
+	err() error
+	lines() int // 到目前为止读取到的最后一行的行号
+}
+
+// 纯文本按行读取，每行转义后作为一个切分单位
+type lineUnits struct {
+	scanner  *bufio.Scanner
+	lineNo   int
+	maxBlank int      // 连续空行最多保留的行数，0 表示全部保留
+	blankRun int      // 当前连续空行的行数
+	tabWidth int      // 大于0时把制表符展开为空格，对齐到该宽度的整数倍列
+	wrapAt   int      // 大于0时把超过该字符数的行折成多个单位
+	pending  []string // 超长的行折出的、尚未返回的后续部分
+}
+
+func (l *lineUnits) next() (contentUnit, bool) {
+	if len(l.pending) > 0 {
+		part := l.pending[0]
+		l.pending = l.pending[1:]
+		return contentUnit{
+			raw:       part,
+			html:      template.HTMLEscapeString(part + "\n"),
+			inline:    true,
+			line:      l.lineNo,
+			continued: true,
+		}, true
+	}
+	var line string
+	for {
+		if !l.scanner.Scan() {
+			return contentUnit{}, false
+		}
+		l.lineNo++
+		line = normalizeLine(l.scanner.Text())
+		if l.tabWidth > 0 {
+			line = expandTabs(line, l.tabWidth)
+		}
+		if strings.TrimSpace(line) != "" {
+			l.blankRun = 0
+			break
+		}
+		// 超出的空行直接跳过，行号仍按原文计数
+		l.blankRun++
+		if l.maxBlank == 0 || l.blankRun <= l.maxBlank {
+			break
+		}
+	}
+	if l.wrapAt > 0 {
+		parts := wrapLine(line, l.wrapAt)
+		line, l.pending = parts[0], parts[1:]
+	}
+	return contentUnit{
+		raw:    line,
+		html:   template.HTMLEscapeString(line + "\n"),
+		title:  line,
+		inline: true,
+		line:   l.lineNo,
+	}, true
+}
+
+func (l *lineUnits) err() error {
+	return l.scanner.Err()
+}
+
+func (l *lineUnits) lines() int {
+	return l.lineNo
+}
+
+// 只切分第一块时，第一块写满后由 emit 返回，结束读取
+var errStopSplit = errors.New("已写满第一块")
+
+// 切分参数
+type splitConfig struct {
+	page        TemplateData  // 整本书共用的页面字段
+	target      int           // 每块HTML的目标大小（字节）
+	tmpl        pageTemplate  // 分块页面模板，与 page、names 一起用于计算每块模板的基础大小
+	names       NamePattern   // 分块文件名模板
+	rules       []HeadingRule // 按顺序匹配卷、章、节等标题，newChunk 的规则匹配的单位总是从新的一块开始，超长的章节内部仍按大小切分
+	withSearch  bool          // 同时把每行的块序号和行号写入搜索索引（暂存在同一临时目录中）
+	anchors     int           // 每隔多少行插入一个行号锚点（id="L行号"），0 表示不插入
+	lineNumbers bool          // 在纯文本的每行前显示它在整个输入中的行号
+	indent      bool          // 在纯文本每段开头插入首行缩进的标记
+	lineParas   bool          // 纯文本每个非空行都是一段，否则由空行分隔段落
+	resume      Resume        // 接着已有的块继续编号
+	maxChunks   int           // 最多生成的块数（包括已有的块），0 表示不限
+	firstOnly   bool          // 第一块写满后停止读取
+	lineLimit   int           // 每块的行数，不为0时按行数而不是大小分块
+	overlap     int           // 每块开头重复上一块最后多少个单位（纯文本为行）
+	splitMarker string        // 与之完全相同的单位及其后的空行不输出，其后的内容从新的一块开始；为空时不检测
+	reveal      int           // 每块先显示的行数，其后每这么多行包成一段逐步显示，0 表示整块一次显示
+	log         *slog.Logger  // 记录分块位置和检测到的标题
+}
+
+// 逐个读取 units 中的切分单位并按目标HTML大小切分，每块写满后立即写入临时暂存区。
+// 由于页面需要显示的总块数要到切分结束才知道，正文不能直接渲染成最终HTML；
+// 暂存到磁盘后内存中只保留当前这一块（约 cfg.target），即使输入有几百MB
+// 也不会占用成倍的内存，代价是正文要额外写入并读回一次磁盘。
+// 调用方负责在使用完毕后调用 spool.remove 清理暂存区。
+func splitToSpool(units unitSource, cfg splitConfig) (*splitResult, error) {
+	if cfg.log == nil {
+		cfg.log = discardLogger
+	}
+	spool, err := newChunkSpool()
+	if err != nil {
+		return nil, err
+	}
+	result := &splitResult{spool: spool}
+	if cfg.withSearch {
+		if result.search, err = newSearchIndex(spool.dir); err != nil {
+			spool.remove()
+			return nil, err
+		}
+	}
+	// 出错时关闭索引文件并删除暂存区
+	fail := func(err error) (*splitResult, error) {
+		if result.search != nil {
+			result.search.close()
+		}
+		spool.remove()
+		return nil, err
+	}
+
+	// 把每块写入暂存区
+	// 块序号和章节锚点都接着已有的块编号，暂存区中仍从1开始
+	chunks := newChunker(len(cfg.resume.Chunks)+1, func(chunk int, chapter string) int {
+		page := cfg.page
+		page.Chapter = chapter
+		return cfg.target - getBaseHTMLSize(cfg.tmpl, page, cfg.names, chunk)
+	}, func(content string, stats ChunkStats) error {
+		if err := spool.add(content); err != nil {
+			return err
+		}
+		result.stats = append(result.stats, stats)
+		cfg.log.Debug("完成分块", "chunk", len(cfg.resume.Chunks)+len(result.stats), "bytes", len(content), "chars", stats.CharCount)
+		if cfg.firstOnly {
+			return errStopSplit
+		}
+		return nil
+	})
+	chunks.lineLimit = cfg.lineLimit
+	chunks.overlap = cfg.overlap
+	chunks.reveal = cfg.reveal
+	if cfg.maxChunks > 0 {
+		// 已有的块不能修改，新内容至少要放入一个新块
+		chunks.last = max(cfg.maxChunks, len(cfg.resume.Chunks)+1)
+	}
+	nextAnchor := 1        // 下一个行号锚点至少要在这一行
+	paragraphStart := true // 下一个非空行是段落的第一行：在开头、空行和标题之后
+	markerSplit := false   // 上一个单位是分块标记，下一个单位从新的一块开始
+	for {
+		unit, ok := units.next()
+		if !ok {
+			break
+		}
+		// 分块标记只决定在哪里换块，本身不输出；块开头、结尾或连续的标记之间没有正文，不会产生空块
+		if cfg.splitMarker != "" && !unit.continued && unit.raw == cfg.splitMarker {
+			cfg.log.Debug("检测到分块标记", "line", unit.line)
+			markerSplit = true
+			paragraphStart = true
+			continue
+		}
+		// 标记之后的空行也不输出，新的一块从正文开始
+		if markerSplit && strings.TrimSpace(unit.raw) == "" {
+			continue
+		}
+		rule, heading := matchHeading(unit.title, cfg.rules)
+		escaped := unit.html
+		anchor := fmt.Sprintf("chapter-%d", cfg.resume.Headings+len(result.headings)+1)
+		if heading {
+			// 给章节标题加上锚点，锚点ID在整本书内唯一
+			// data-chapter 供页面显示当前所在的章节
+			chunks.chapter = strings.TrimSpace(unit.title)
+			title := template.HTMLEscapeString(chunks.chapter)
+			if unit.inline {
+				escaped = fmt.Sprintf(`<span class="chapter-heading" id="%s" data-chapter="%s">%s</span>`, anchor, title, escaped)
+			} else {
+				escaped = fmt.Sprintf(`<span class="chapter-anchor" id="%s" data-chapter="%s"></span>%s`, anchor, title, escaped)
+			}
+		}
+		// 只拆分纯文本行：转义后的文本不含标签，只需避开多字节字符和实体的中间；
+		// Markdown 块和章节标题含有HTML标签，无法安全拆分
+		splittable := unit.inline && !heading
+		// 纯文本的空行分隔段落，单独包一层，页面中可以调整段落间距；
+		// 显示行号时保持原样，行号要和空行显示在同一行
+		if splittable && !cfg.lineNumbers && !unit.continued && strings.TrimSpace(unit.raw) == "" {
+			escaped = `<span class="blank-line">` + escaped + `</span>`
+			splittable = false
+		}
+		// 段落的第一行：每行一段时为每个非空行，否则为空行或标题之后的第一个非空行；折出的后续行不算
+		blank := strings.TrimSpace(unit.raw) == ""
+		firstLine := splittable && !unit.continued && !blank && (cfg.lineParas || paragraphStart)
+		if !unit.continued {
+			paragraphStart = blank || heading || !unit.inline
+		}
+		// 首行缩进：在段落第一行的行首插入空标记，由页面样式撑开两个字的宽度；
+		// 原有的行首空白（常见的两个全角空格）去掉，避免缩进两次。标记和行号、锚点一样不参与拆分
+		prefixTag := ""
+		if cfg.indent && firstLine {
+			prefixTag = paragraphIndentTag
+			escaped = prefixTag + strings.TrimLeft(escaped, " \t\u3000")
+		}
+		// 行号按单位在整个输入中的行号标注，跨块时自然连续
+		numberTag := ""
+		if cfg.lineNumbers && unit.inline && !unit.continued {
+			numberTag = fmt.Sprintf(`<span class="line-number" data-line="%d"></span>`, unit.line)
+			escaped = numberTag + escaped
+		}
+		// 行号锚点插在单位开头，Markdown 块跨多行时以块的第一行为准
+		anchorTag := ""
+		if cfg.anchors > 0 && unit.line >= nextAnchor {
+			anchorTag = fmt.Sprintf(`<span class="line-anchor" id="L%d"></span>`, unit.line)
+			escaped = anchorTag + escaped
+			nextAnchor = unit.line + cfg.anchors
+		}
+		// 每行一段时段落之间没有空行，在每段前插入间距标记，段落间距由它调整；
+		// 标记独占一行，要在行号之前，每块开头的才能由样式隐藏
+		if cfg.lineParas && firstLine {
+			prefixTag += paragraphGapTag
+			escaped = paragraphGapTag + escaped
+		}
+
+		prevChunk := chunks.chunk
+		newChunk := heading && rule.NewChunk || markerSplit
+		markerSplit = false
+		unitChunk, err := chunks.add(escaped, unit.raw, len(anchorTag)+len(numberTag)+len(prefixTag), splittable, newChunk)
+		if err == errStopSplit {
+			// 第一块已写满，当前单位属于下一块，不再记录
+			result.truncated = true
+			break
+		}
+		if err != nil {
+			return fail(err)
+		}
+		if chunks.chunk != prevChunk {
+			reason := "达到目标大小"
+			switch {
+			case heading && rule.NewChunk && unitChunk != prevChunk:
+				reason = "章节标题"
+			case newChunk && unitChunk != prevChunk:
+				reason = "分块标记"
+			case cfg.lineLimit > 0:
+				reason = "达到每块行数"
+			case unitChunk == prevChunk:
+				reason = "超长的行拆到下一块"
+			}
+			cfg.log.Debug("开始新的一块", "chunk", chunks.chunk, "line", unit.line, "reason", reason)
+		}
+		if heading {
+			cfg.log.Debug("检测到标题", "line", unit.line, "level", rule.Level, "text", chunks.chapter, "chunk", unitChunk)
+			result.headings = append(result.headings, ChapterHeading{
+				Text:   chunks.chapter,
+				Chunk:  unitChunk,
+				Anchor: anchor,
+				Level:  rule.Level,
+			})
+		}
+		if result.search != nil {
+			for i, text := range strings.Split(unit.raw, "\n") {
+				if err := result.search.add(unitChunk, unit.line+i, text); err != nil {
+					return fail(err)
+				}
+			}
+		}
+	}
+	if err := units.err(); err != nil {
+		if err == bufio.ErrTooLong {
+			return fail(fmt.Errorf("存在超过单行长度上限的行: %w", err))
+		}
+		return fail(fmt.Errorf("读取输入失败: %w", err))
+	}
+
+	// 添加最后一块内容
+	if !result.truncated {
+		if err := chunks.finish(); err != nil && err != errStopSplit {
+			return fail(err)
+		}
+	}
+	result.lines = units.lines()
+	if result.search != nil {
+		if err := result.search.close(); err != nil {
+			spool.remove()
+			return nil, err
+		}
+	}
+	return result, nil
+}
+
+// 按大小把切分单位累积成块，只决定在哪里分块，写出每块由 emit 负责，本身不做任何 I/O
+type chunker struct {
+	space     func(chunk int, chapter string) int          // 第 chunk 块开头位于 chapter 时可用于正文的字节数
+	emit      func(content string, stats ChunkStats) error // 写出一块已完成的正文
+	chapter   string                                       // 当前所在的章节，记入此后开始的块
+	spaceFor  string                                       // 计算 remaining 时所按的章节
+	chunk     int                                          // 当前块序号，从1开始
+	last      int                                          // 最后一块的序号，到达后不再换块，其余内容都放入这一块；0 表示不限
+	lineLimit int                                          // 每块的行数，不为0时按行数分块，不再按大小分块
+	lines     int                                          // 当前块已有的行数
+	remaining int                                          // 当前块可用于正文的字节数
+	overlap   int                                          // 按大小或行数换块时，在新块开头重复上一块最后几个单位，0 表示不重复
+	recent    []string                                     // 当前块最后 overlap 个单位的HTML
+	carried   int                                          // 当前块开头重复内容的字节数，不算作本块的正文
+	reveal    int                                          // 每隔多少行在块内开始新的一段逐步显示的正文，0 表示不分段
+	segment   int                                          // 当前段已有的行数，第一段是块开头直接显示的部分
+	segmented bool                                         // 当前块已有逐步显示的段，写出前要关闭最后一段
+	content   strings.Builder
+	stats     ChunkStats
+}
+
+// 从第 first 块开始编号
+func newChunker(first int, space func(chunk int, chapter string) int, emit func(content string, stats ChunkStats) error) *chunker {
+	c := &chunker{space: space, emit: emit}
+	c.start(first)
+	return c
+}
+
+// 开始第 chunk 块
+func (c *chunker) start(chunk int) {
+	c.chunk = chunk
+	c.reserve()
+}
+
+// 按当前章节计算本块可用于正文的字节数（页面中会显示开头所在的章节名）
+func (c *chunker) reserve() {
+	c.spaceFor = c.chapter
+	c.remaining = c.space(c.chunk, c.chapter)
+	if c.remaining < 0 {
+		c.remaining = 1024 // 确保至少能容纳一些内容
+	}
+}
+
+// 写出当前块并开始新的一块。carry 表示新块接着上一块的内容（而不是从新的章节开始），
+// 此时在新块开头重复上一块的最后几个单位，重复的内容计入大小，但不计入字数和行数
+func (c *chunker) flush(carry bool) error {
+	if c.segmented {
+		c.content.WriteString(revealCloseTag)
+	}
+	if err := c.emit(c.content.String(), c.stats); err != nil {
+		return err
+	}
+	c.content.Reset()
+	c.stats = ChunkStats{}
+	c.lines = 0
+	c.carried = 0
+	c.segment = 0
+	c.segmented = false
+	c.start(c.chunk + 1)
+	if carry && len(c.recent) > 0 {
+		// 重复的内容最多占新块的一半，否则单位很大时新块放不下新的正文
+		units := c.recent
+		for len(units) > 0 && len(overlapHTML(units)) > c.remaining/2 {
+			units = units[1:]
+		}
+		if len(units) > 0 {
+			c.content.WriteString(overlapHTML(units))
+			c.carried = c.content.Len()
+		}
+	}
+	c.recent = c.recent[:0]
+	return nil
+}
+
+// 当前块是否还没有正文，开头重复的上一块内容不算
+func (c *chunker) empty() bool {
+	return c.content.Len() <= c.carried
+}
+
+// 重复内容中的锚点ID和章节标记已在上一块中出现，去掉后不影响链接定位和当前章节提示
+var overlapAttrRe = regexp.MustCompile(` (?:id|data-chapter)="[^"]*"`)
+
+// 把上一块最后几个单位包在 <div class="overlap"> 中，页面中淡色显示，屏幕阅读器跳过
+func overlapHTML(units []string) string {
+	return `<div class="overlap" aria-hidden="true">` + overlapAttrRe.ReplaceAllString(strings.Join(units, ""), "") + `</div>`
+}
+
+// 在当前块中写入下一个单位之前还要加上的分段标签的字节数，包括写出前关闭最后一段的标签
+func (c *chunker) segmentCost() int {
+	cost := 0
+	if c.segmented {
+		cost += len(revealCloseTag)
+	}
+	if c.reveal > 0 && c.segment >= c.reveal {
+		cost += len(revealOpenTag) + len(revealCloseTag)
+	}
+	return cost
+}
+
+// 当前块是否已满，放不下下一个单位 escaped
+func (c *chunker) full(escaped string) bool {
+	if c.lineLimit > 0 {
+		return c.lines >= c.lineLimit
+	}
+	return c.content.Len()+len(escaped)+c.segmentCost() > c.remaining
+}
+
+// 当前块是否已是允许的最后一块
+func (c *chunker) capped() bool {
+	return c.last > 0 && c.chunk >= c.last
+}
+
+// 把一段正文写入当前块，raw 为其原始文本，用于统计字数
+func (c *chunker) write(escaped, raw string) {
+	if c.empty() {
+		c.stats.Chapter = c.chapter
+		// 开始本块后才遇到新章节（例如第一块以章节标题开头），按新章节重新计算
+		if c.chapter != c.spaceFor {
+			c.reserve()
+		}
+	}
+	// 当前段已满时从下一个单位开始新的一段，段只在单位之间分开，不会切开标签
+	if c.reveal > 0 && c.segment >= c.reveal {
+		if c.segmented {
+			c.content.WriteString(revealCloseTag)
+		}
+		c.content.WriteString(revealOpenTag)
+		c.segmented = true
+		c.segment = 0
+	}
+	c.content.WriteString(escaped)
+	addTextStats(&c.stats, raw)
+	lines := strings.Count(raw, "\n") + 1
+	c.lines += lines
+	c.segment += lines
+	if c.overlap > 0 {
+		if len(c.recent) == c.overlap {
+			c.recent = append(c.recent[:0], c.recent[1:]...)
+		}
+		c.recent = append(c.recent, escaped)
+	}
+}
+
+// 加入一个切分单位，返回单位开头所在的块序号。escaped 为转义后的HTML，开头 prefix 个字节是不可切开的锚点、行号标签；
+// newChunk 时总是从新的一块开始，否则放不下时才换块。
+// splittable 表示 escaped 除锚点外是 HTMLEscapeString 的输出：单位比一整块还大时（例如整个文件只有一行），
+// 在字符边界处拆到多块中，保证每块不超过目标大小。到达最后一块后不再换块，这一块可以超过目标大小
+func (c *chunker) add(escaped, raw string, prefix int, splittable, newChunk bool) (int, error) {
+	if !c.capped() && !c.empty() && (newChunk || c.full(escaped)) {
+		if err := c.flush(!newChunk); err != nil {
+			return 0, err
+		}
+	}
+	unitChunk := c.chunk
+
+	for splittable && c.lineLimit == 0 && !c.capped() && c.content.Len()+len(escaped)+c.segmentCost() > c.remaining {
+		cut := safeCutIndex(escaped, c.remaining-c.content.Len()-c.segmentCost())
+		if cut <= prefix {
+			cut = 0 // 开头的标签不能切开，至少要和一个字符放在同一块
+		}
+		if cut == 0 {
+			if c.empty() {
+				break // 空块也放不下一个字符，只能整体放入
+			}
+			if err := c.flush(true); err != nil {
+				return 0, err
+			}
+			continue
+		}
+		piece := escaped[:cut]
+		escaped = escaped[cut:]
+		c.write(piece, html.UnescapeString(piece[prefix:]))
+		prefix = 0
+		if err := c.flush(true); err != nil {
+			return 0, err
+		}
+		raw = html.UnescapeString(escaped)
+	}
+
+	c.write(escaped, raw)
+	return unitChunk, nil
+}
+
+// 写出最后一块（如果有内容）
+func (c *chunker) finish() error {
+	if c.empty() {
+		return nil
+	}
+	return c.flush(false)
+}
+
+// 在 escaped 的前 limit 个字节内找一个可以安全切开的位置：不切开多字节字符，
+// 也不切开 &amp; 之类的字符实体。escaped 必须是 HTMLEscapeString 的输出。找不到时返回0
+func safeCutIndex(escaped string, limit int) int {
+	if limit >= len(escaped) {
+		return len(escaped)
+	}
+	if limit <= 0 {
+		return 0
+	}
+	cut := limit
+	for cut > 0 && !utf8.RuneStart(escaped[cut]) {
+		cut--
+	}
+	// 切点前最近的 & 之后没有 ; 说明切点落在实体中间，退回到 & 之前
+	if amp := strings.LastIndexByte(escaped[:cut], '&'); amp >= 0 && !strings.Contains(escaped[amp:cut], ";") {
+		cut = amp
+	}
+	return cut
+}
+
+// 把一段正文计入块的字符数和字数。块的大小按转义后的字节数控制，统计则按字符：
+// 汉字、代理对解码后的扩展区汉字和表情都计为一个字符
+func addTextStats(stats *ChunkStats, text string) {
+	stats.CharCount += utf8.RuneCountInString(strings.ReplaceAll(text, "\n", ""))
+	stats.WordCount += countWords(text)
+}
+
+// 去掉行尾残留的回车符。bufio.ScanLines 只会去掉紧挨换行符的一个 \r，
+// "\r\r\n" 这类不规范的换行仍会留下 \r，在页面上显示异常
+func normalizeLine(line string) string {
+	return strings.TrimRight(line, "\r")
+}
+
+// 统计正文中除制表符外的控制字符（NUL、换页符、垂直制表符等），它们在页面中显示为方框或打乱排版。
+// 包在按行扫描的切分函数外，remove 为 true 时同时去掉。行尾残留的 \r 本来就会被 normalizeLine 去掉，不计入
+type controlChars struct {
+	split  bufio.SplitFunc
+	remove bool
+	count  int
+	line   int // 已扫描的行数
+	log    *slog.Logger
+}
+
+func (c *controlChars) scanLines(data []byte, atEOF bool) (int, []byte, error) {
+	advance, token, err := c.split(data, atEOF)
+	if advance > 0 {
+		c.line++
+	}
+	// 绝大多数行没有控制字符，先查找一遍，避免每行都复制
+	if bytes.IndexFunc(bytes.TrimRight(token, "\r"), isControlChar) < 0 {
+		return advance, token, err
+	}
+	n := 0
+	cleaned := bytes.Map(func(r rune) rune {
+		if isControlChar(r) {
+			n++
+			return -1
+		}
+		return r
+	}, bytes.TrimRight(token, "\r"))
+	c.count += n
+	c.log.Debug("控制字符", "line", c.line, "count", n)
+	if c.remove {
+		token = cleaned
+	}
+	return advance, token, err
+}
+
+func isControlChar(r rune) bool {
+	return r != '\t' && unicode.IsControl(r)
+}
+
+// 把制表符展开为空格，对齐到 width 的整数倍列，列按字符计
+func expandTabs(line string, width int) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := width - col%width
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// 把超过 width 个字符的行折成多行，每行不超过 width 个字符，各行依次相接即为原来的行。
+// 尽量在空白之后或汉字前后折行，西文单词比半行还长时才从中间折开；按字符而不是字节计，不会切开多字节字符
+func wrapLine(line string, width int) []string {
+	if utf8.RuneCountInString(line) <= width {
+		return []string{line}
+	}
+	runes := []rune(line)
+	var parts []string
+	for len(runes) > width {
+		cut := width
+		for i := width; i > width/2; i-- {
+			if canBreak(runes[i-1], runes[i]) {
+				cut = i
+				break
+			}
+		}
+		parts = append(parts, string(runes[:cut]))
+		runes = runes[cut:]
+	}
+	return append(parts, string(runes))
+}
+
+// 能否在 a、b 两个字符之间折行：不在开括号、引号之后或逗号、句号、闭括号之前折行
+func canBreak(a, b rune) bool {
+	if unicode.In(a, unicode.Ps, unicode.Pi) || unicode.In(b, unicode.Pe, unicode.Pf) || strings.ContainsRune(",.;:!?，。、；：！？…", b) {
+		return false
+	}
+	if unicode.IsSpace(a) {
+		return !unicode.IsSpace(b)
+	}
+	// 全角标点之后也可以折行
+	return isCJK(a) || isCJK(b) || (a >= 0x3000 && unicode.In(a, unicode.Po))
+}
+
+// 按顺序用 rules 匹配一行，返回第一条匹配的规则
+func matchHeading(line string, rules []HeadingRule) (HeadingRule, bool) {
+	for _, rule := range rules {
+		if rule.Pattern.MatchString(line) {
+			return rule, true
+		}
+	}
+	return HeadingRule{}, false
+}
+
+// 粗略统计字数：每个汉字（及日文假名、韩文）计为一个字，连续的字母或数字计为一个单词，
+// 标点和空白不计
+func countWords(content string) int {
+	count := 0
+	inWord := false
+	for _, r := range content {
+		switch {
+		case isCJK(r):
+			count++
+			inWord = false
+		case unicode.IsLetter(r) || unicode.IsDigit(r):
+			if !inWord {
+				count++
+				inWord = true
+			}
+		default:
+			inWord = false
+		}
+	}
+	return count
+}
+
+// 判断字符是否属于中日韩文字
+func isCJK(r rune) bool {
+	return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) ||
+		unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
+
+// 按 readingCharsPerMinute 估算阅读分钟数，有内容时至少为1分钟
+func readingMinutes(wordCount int) int {
+	if wordCount <= 0 {
+		return 0
+	}
+	return (wordCount + readingCharsPerMinute - 1) / readingCharsPerMinute
+}
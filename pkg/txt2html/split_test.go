@@ -0,0 +1,203 @@
+package txt2html
+
+import (
+	"html"
+	"html/template"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+// 用 chunker 把各行转义后切分，每块可用于正文的字节数为 target-base，返回各块的正文
+func chunkLines(t *testing.T, lines []string, target, base int) []string {
+	t.Helper()
+	var chunks []string
+	c := newChunker(1, func(int, string) int {
+		return target - base
+	}, func(content string, _ ChunkStats) error {
+		chunks = append(chunks, content)
+		return nil
+	})
+	for _, line := range lines {
+		if _, err := c.add(template.HTMLEscapeString(line+"\n"), line, 0, true, false); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := c.finish(); err != nil {
+		t.Fatal(err)
+	}
+	return chunks
+}
+
+func TestChunker(t *testing.T) {
+	tests := []struct {
+		name   string
+		lines  []string
+		target int
+		base   int
+		want   []string
+	}{
+		{
+			name:   "空输入",
+			lines:  nil,
+			target: 100,
+			want:   nil,
+		},
+		{
+			name:   "单个短行",
+			lines:  []string{"hello"},
+			target: 100,
+			base:   20,
+			want:   []string{"hello\n"},
+		},
+		{
+			name:   "恰好等于可用大小",
+			lines:  []string{"123456789"},
+			target: 30,
+			base:   20,
+			want:   []string{"123456789\n"},
+		},
+		{
+			name:   "超出一个字节换块",
+			lines:  []string{"123456789", "a"},
+			target: 30,
+			base:   20,
+			want:   []string{"123456789\n", "a\n"},
+		},
+		{
+			name:   "按转义后的大小计算",
+			lines:  []string{"a<b", "c"},
+			target: 8,
+			want:   []string{"a&lt;b\n", "c\n"},
+		},
+		{
+			name:   "多块",
+			lines:  strings.Split(strings.Repeat("abcdefghi\n", 25), "\n")[:25],
+			target: 120,
+			base:   20,
+			want: []string{
+				strings.Repeat("abcdefghi\n", 10),
+				strings.Repeat("abcdefghi\n", 10),
+				strings.Repeat("abcdefghi\n", 5),
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := chunkLines(t, tt.lines, tt.target, tt.base)
+			if len(got) != len(tt.want) {
+				t.Fatalf("得到 %d 块，期望 %d 块: %q", len(got), len(tt.want), got)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("第 %d 块 = %q，期望 %q", i+1, got[i], tt.want[i])
+				}
+			}
+		})
+	}
+}
+
+// 比一整块还大的行拆到多块中，每块都不超过可用大小，且不切开多字节字符和字符实体
+func TestChunkerLongLine(t *testing.T) {
+	line := strings.Repeat("汉字&<>abc", 500)
+	const target, base = 100, 10
+	chunks := chunkLines(t, []string{line}, target, base)
+	if len(chunks) < 2 {
+		t.Fatalf("长行没有被拆开: %d 块", len(chunks))
+	}
+	var joined strings.Builder
+	for i, chunk := range chunks {
+		if len(chunk) > target-base {
+			t.Errorf("第 %d 块 %d 字节，超过 %d", i+1, len(chunk), target-base)
+		}
+		if !utf8.ValidString(chunk) {
+			t.Errorf("第 %d 块切开了多字节字符: %q", i+1, chunk)
+		}
+		if amp := strings.LastIndexByte(chunk, '&'); amp >= 0 && !strings.Contains(chunk[amp:], ";") {
+			t.Errorf("第 %d 块切开了字符实体: %q", i+1, chunk)
+		}
+		joined.WriteString(chunk)
+	}
+	if got := html.UnescapeString(joined.String()); got != line+"\n" {
+		t.Errorf("拼接后的内容与原文不同")
+	}
+}
+
+func TestSafeCutIndex(t *testing.T) {
+	tests := []struct {
+		escaped string
+		limit   int
+		want    int
+	}{
+		{"abcdef", 10, 6},
+		{"abcdef", 3, 3},
+		{"abcdef", 0, 0},
+		{"ab&amp;cd", 4, 2},
+		{"ab&amp;cd", 7, 7},
+		{"汉字", 4, 3},
+		{"汉字", 2, 0},
+	}
+	for _, tt := range tests {
+		if got := safeCutIndex(tt.escaped, tt.limit); got != tt.want {
+			t.Errorf("safeCutIndex(%q, %d) = %d，期望 %d", tt.escaped, tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestWrapLine(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  []string
+	}{
+		{"短行", 10, []string{"短行"}},
+		{"一二三四五六七", 3, []string{"一二三", "四五六", "七"}},
+		{"hello world again", 12, []string{"hello world ", "again"}},
+		{"甲乙丙，丁戊", 3, []string{"甲乙", "丙，丁", "戊"}},
+		{"abcdefghij", 4, []string{"abcd", "efgh", "ij"}},
+	}
+	for _, tt := range tests {
+		got := wrapLine(tt.line, tt.width)
+		if !slices.Equal(got, tt.want) {
+			t.Errorf("wrapLine(%q, %d) = %q，期望 %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	tests := []struct {
+		content string
+		want    int
+	}{
+		{"", 0},
+		{"你好，世界", 4},
+		{"hello world 42", 3},
+		{"第3章 Go语言", 6},
+	}
+	for _, tt := range tests {
+		if got := countWords(tt.content); got != tt.want {
+			t.Errorf("countWords(%q) = %d，期望 %d", tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestExpandTabs(t *testing.T) {
+	tests := []struct {
+		line  string
+		width int
+		want  string
+	}{
+		{"abc", 4, "abc"},
+		{"\tx", 4, "    x"},
+		{"ab\tc", 4, "ab  c"},
+		{"abcd\te", 4, "abcd    e"},
+		{"汉字\t列", 4, "汉字  列"},
+		{"a\tb\tc", 2, "a b c"},
+	}
+	for _, tt := range tests {
+		if got := expandTabs(tt.line, tt.width); got != tt.want {
+			t.Errorf("expandTabs(%q, %d) = %q，期望 %q", tt.line, tt.width, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,50 @@
+package txt2html
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// 分块正文的临时存储。
+// 切分完成前无法知道总块数，而每个页面都要显示总块数，所以先把每块转义后的正文
+// 依次写入临时目录，等切分结束再逐块读回渲染，内存中始终只保留一块内容。
+type chunkSpool struct {
+	dir   string
+	count int
+}
+
+func newChunkSpool() (*chunkSpool, error) {
+	dir, err := os.MkdirTemp("", "txt2html-spool-")
+	if err != nil {
+		return nil, err
+	}
+	return &chunkSpool{dir: dir}, nil
+}
+
+func (s *chunkSpool) path(chunk int) string {
+	return filepath.Join(s.dir, fmt.Sprintf("%d.part", chunk))
+}
+
+// 追加一块正文，块序号从1开始依次递增
+func (s *chunkSpool) add(content string) error {
+	if err := os.WriteFile(s.path(s.count+1), []byte(content), 0644); err != nil {
+		return err
+	}
+	s.count++
+	return nil
+}
+
+// 读回第 chunk 块的正文
+func (s *chunkSpool) read(chunk int) (string, error) {
+	content, err := os.ReadFile(s.path(chunk))
+	if err != nil {
+		return "", err
+	}
+	return string(content), nil
+}
+
+// 删除临时目录
+func (s *chunkSpool) remove() error {
+	return os.RemoveAll(s.dir)
+}
@@ -0,0 +1,2110 @@
+package txt2html
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// HTML模板数据结构
+type TemplateData struct {
+	Content         template.HTML // 已转义的正文，章节标题带有锚点
+	FileName        string        // 输入文件名，也是页面保存阅读设置和阅读位置所用的键
+	Title           string        // 页面中显示的书名，未指定时与 FileName 相同
+	TotalChunks     int
+	CurrentChunk    int
+	PrevFile        string        // 上一块的文件名，第一块为空
+	NextFile        string        // 下一块的文件名，最后一块为空
+	OutputFile      string        // 本块的文件名
+	CharCount       int           // 本块字符数，按 Unicode 字符而不是字节计
+	WordCount       int           // 本块字数（汉字按字、西文按单词计）
+	ReadingMinutes  int           // 按每分钟300字估算的阅读时间
+	CenterMaxWidth  int           // 中央内容区最大宽度（px）
+	Markdown        bool          // 正文为渲染后的 Markdown，不再按原样保留空白
+	SearchPage      string        // 全书搜索页面的文件名，未生成搜索索引时为空
+	IndexPage       string        // 目录页相对于分块文件的路径，没有目录页时为空
+	Columns         int           // 正文默认分栏数，1 表示不分栏
+	TabWidth        int           // 制表符宽度（字符），用作正文的 CSS tab-size
+	Justify         bool          // 正文默认两端对齐，完整页面中读者还可以自行切换
+	FirstLineIndent bool          // 正文默认每段首行缩进两个字，纯文本中只有带缩进标记的段落生效；完整页面中读者还可以自行切换
+	Theme           string        // 完整页面默认的阅读主题，为 ReaderThemes 之一，为空时为第一个；读者选过主题后以读者的为准
+	Header          template.HTML // 每页正文上方的页眉（如来源说明），为空时不显示
+	Footer          template.HTML // 每页正文下方的页脚（如版权声明），为空时不显示
+	Chapter         string        // 本块开头所在的章节，开头前没有章节标题时为空
+	SharedAssets    bool          // 引用同一目录中的 ReaderStyleFile 和 ReaderScriptFile，而不是内联样式和脚本
+	Single          bool          // 全书合并为一个页面，Content 中每块包在可折叠的 <details class="part"> 中，见 Book.RenderSingle
+	Provenance      *Provenance   // 来源和转换记录，为 nil 时不显示文件信息
+	FilePattern     string        // 分块文件名模板，只含 {n} 占位符（可带宽度，如 {n:04d}），页面中据此跳转到任意一块
+	SolidBackground bool          // 页面背景为左侧背景色的纯色，不在两侧使用渐变，设置面板中不显示右侧背景
+	UILang          string        // 界面文字的语言，为 UILanguages 之一，为空时为第一个；模板中用 {{.Label "键"}} 取得文字
+	Favicon         template.URL  // 页面图标，一般为图片的 data URI；Split 在未指定时填入 DefaultFavicon
+}
+
+// 默认的页面图标：书本 emoji 的 SVG，所有页面共用，收藏后在浏览器中能与其他网站区分开
+const DefaultFavicon template.URL = "data:image/svg+xml,%3Csvg%20xmlns=%22http://www.w3.org/2000/svg%22%20viewBox=%220%200%20100%20100%22%3E%3Ctext%20y=%22.9em%22%20font-size=%2290%22%3E%F0%9F%93%96%3C/text%3E%3C/svg%3E"
+
+// 页面的来源和转换记录，显示在设置面板中可展开的“文件信息”里，便于存档时追溯
+type Provenance struct {
+	SourceFile    string    // 原文件名
+	SourceModTime time.Time // 原文件的修改时间，来源为标准输入时为零值
+	GeneratedAt   time.Time // 转换时间
+	Generator     string    // 生成页面的程序及版本
+}
+
+// 完整页面共用的样式：内联在每页中，或在 SharedAssets 时写入 ReaderStyleFile 由各页引用。
+// 与每页有关的页面宽度、分栏数由页面中的 CSS 变量提供
+const readerStyle = `
+        :root {
+            --left-bg: #f5f5f5;   /* 左侧默认背景 */
+            --center-bg: #ffffff; /* 中央内容背景 */
+            --right-bg: #f5f5f5;  /* 右侧默认背景 */
+        }
+        /* 使用线性渐变在页面两侧显示可配置颜色，中间使用中心背景色 */
+        body {
+            --g-left: calc(50% - var(--center-max-width) / 2);
+            --g-right: calc(50% + var(--center-max-width) / 2);
+            background: linear-gradient(to right,
+                        var(--left-bg) 0px var(--g-left),
+                        var(--center-bg) var(--g-left) var(--g-right),
+                        var(--right-bg) var(--g-right) 100%);
+            color: #333;
+            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+            padding: 20px;
+            margin: 0;
+            font-size: 16px;
+        }
+        .controls {
+            margin-bottom: 20px;
+            padding: 15px;
+            background-color: #f5f5f5;
+            border-radius: 8px;
+            display: flex;
+            flex-wrap: wrap;
+            gap: 15px;
+            align-items: center;
+            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
+        }
+        .control-section {
+            display: flex;
+            flex-direction: column;
+            gap: 8px;
+        }
+        .control-group {
+            display: flex;
+            gap: 10px;
+            align-items: center;
+        }
+        /* 浏览器不支持的功能（如朗读）整节隐藏 */
+        .control-section[hidden],
+        .control-group[hidden] {
+            display: none;
+        }
+        button {
+            background-color: #e0e0e0;
+            color: #333;
+            border: none;
+            padding: 8px 16px;
+            border-radius: 4px;
+            cursor: pointer;
+            transition: background-color 0.3s;
+            font-size: 16px;
+        }
+        button:hover {
+            background-color: #ccc;
+        }
+        /* 键盘操作时显示焦点所在位置，鼠标点击时不显示 */
+        button:focus-visible,
+        select:focus-visible,
+        input:focus-visible,
+        a:focus-visible,
+        summary:focus-visible {
+            outline: 2px solid #0066cc;
+            outline-offset: 2px;
+        }
+        .content:focus {
+            outline: none;
+        }
+        /* 跳到正文的链接平时移出屏幕，用 Tab 键聚焦时显示在左上角 */
+        .skip-link {
+            position: absolute;
+            left: -9999px;
+            top: 10px;
+            z-index: 1000;
+            padding: 8px 16px;
+            border-radius: 4px;
+            background-color: #fff;
+            color: #0066cc;
+        }
+        .skip-link:focus {
+            left: 10px;
+        }
+        .page-center {
+            max-width: var(--center-max-width);
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .content {
+            white-space: pre-wrap;
+            word-wrap: break-word;
+            padding: 25px;
+            border-radius: 8px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            min-height: 300px;
+            transition: background-color 0.3s, color 0.3s, line-height 0.3s;
+            line-height: 1.6; /* 默认行距 */
+            background-color: var(--center-bg);
+            column-count: var(--columns);
+            column-gap: 40px;
+            column-rule: 1px solid rgba(0,0,0,0.1);
+            tab-size: var(--tab-width);
+        }
+        .chunk-info {
+            color: #666;
+            font-size: 0.9em;
+            margin-top: 10px;
+            width: 100%;
+            text-align: right;
+        }
+        .color-options {
+            display: flex;
+            gap: 8px;
+            align-items: center;
+        }
+        .color-preview {
+            width: 20px;
+            height: 20px;
+            border-radius: 4px;
+            border: 1px solid rgba(0,0,0,0.12);
+            box-shadow: 0 1px 2px rgba(0,0,0,0.05);
+            display: inline-block;
+            vertical-align: middle;
+        }
+        #searchInput {
+            padding: 6px 8px;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+            font-size: 14px;
+            width: 160px;
+        }
+        mark.search-hit {
+            background-color: #ffeb3b;
+            color: inherit;
+            padding: 0;
+        }
+        mark.search-hit.current {
+            background-color: #ff9800;
+        }
+        .display-value {
+            min-width: 50px;
+            text-align: center;
+        }
+        #jumpInput {
+            padding: 6px 8px;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+            font-size: 14px;
+            width: 80px;
+        }
+        .jump-error {
+            color: #c00;
+            font-size: 0.9em;
+        }
+        /* 书签列表：占满设置面板的一整行 */
+        .bookmarks {
+            width: 100%;
+            font-size: 0.9em;
+        }
+        .bookmarks summary {
+            cursor: pointer;
+        }
+        .bookmarks ul {
+            list-style: none;
+            margin: 8px 0 0 0;
+            padding: 0;
+        }
+        .bookmarks li {
+            display: flex;
+            gap: 10px;
+            align-items: center;
+            padding: 4px 0;
+        }
+        .bookmarks li a {
+            color: #0066cc;
+            overflow: hidden;
+            text-overflow: ellipsis;
+            white-space: nowrap;
+        }
+        .bookmark-meta {
+            color: #888;
+            white-space: nowrap;
+        }
+        .bookmarks li button {
+            padding: 2px 8px;
+            font-size: 0.9em;
+        }
+        .file-info {
+            width: 100%;
+            font-size: 0.9em;
+            color: #666;
+        }
+        .file-info summary {
+            cursor: pointer;
+        }
+        .file-info dl {
+            display: grid;
+            grid-template-columns: auto 1fr;
+            gap: 4px 12px;
+            margin: 8px 0 0 0;
+        }
+        .file-info dd {
+            margin: 0;
+            word-break: break-all;
+        }
+        .chapter-heading {
+            font-weight: bold;
+        }
+        /* 合并为单个页面时每块是一个可折叠的部分 */
+        .part > summary {
+            cursor: pointer;
+            font-weight: bold;
+            color: #0066cc;
+        }
+        /* 段落间的空行显示为可调高度的块，--paragraph-spacing 为 1 时与普通空行等高；
+           字号设为0只影响显示，复制的正文中仍是换行 */
+        .content {
+            --paragraph-spacing: 1;
+        }
+        .blank-line {
+            display: block;
+            height: calc(var(--paragraph-spacing) * 1lh);
+            font-size: 0;
+            line-height: 0;
+        }
+        .content.markdown p {
+            margin: calc(var(--paragraph-spacing) * 1em) 0;
+        }
+        /* 每行一段时每段前的间距，默认半行；每块开头的不显示 */
+        .paragraph-gap {
+            display: block;
+            block-size: calc(var(--paragraph-spacing) * 0.5lh);
+        }
+        .content > .paragraph-gap:first-child {
+            display: none;
+        }
+        /* 行号写在属性中由伪元素显示，复制正文时不会带上 */
+        .line-number::before {
+            content: attr(data-line);
+            display: inline-block;
+            min-width: 3em;
+            margin-right: 1em;
+            text-align: right;
+            color: #999;
+        }
+        /* Markdown 正文由HTML元素控制排版，不保留源文件空白 */
+        .content.markdown {
+            white-space: normal;
+        }
+        .content.markdown pre {
+            white-space: pre;
+            overflow-x: auto;
+            padding: 12px;
+            background-color: rgba(0,0,0,0.05);
+            border-radius: 4px;
+        }
+        .content.markdown code {
+            font-family: Consolas, Monaco, 'Courier New', monospace;
+        }
+        .chunk-nav {
+            display: flex;
+            justify-content: space-between;
+            gap: 10px;
+        }
+        .nav-button {
+            display: inline-block;
+            background-color: #e0e0e0;
+            color: #333;
+            padding: 8px 16px;
+            border-radius: 4px;
+            text-decoration: none;
+            transition: background-color 0.3s;
+        }
+        a.nav-button:hover {
+            background-color: #ccc;
+        }
+        .nav-button.disabled {
+            color: #aaa;
+            background-color: #eee;
+            cursor: not-allowed;
+        }
+        .page-center > .chunk-nav {
+            margin-top: 20px;
+        }
+        .page-header, .page-footer {
+            color: #666;
+            font-size: 0.9em;
+            text-align: center;
+            margin: 10px 0;
+        }
+        /* 浮动显示当前所在的章节 */
+        .chapter-indicator {
+            position: fixed;
+            top: 10px;
+            right: 10px;
+            max-width: 40%;
+            padding: 4px 12px;
+            border-radius: 4px;
+            background-color: rgba(0,0,0,0.6);
+            color: #fff;
+            font-size: 0.85em;
+            white-space: nowrap;
+            overflow: hidden;
+            text-overflow: ellipsis;
+            z-index: 999;
+            pointer-events: none;
+        }
+        .chapter-indicator:empty {
+            display: none;
+        }
+        /* 浮动的快捷导航，固定在右下角，不挡住顶部的进度条和章节提示 */
+        .float-nav {
+            position: fixed;
+            right: 12px;
+            bottom: 12px;
+            z-index: 998;
+            display: flex;
+            flex-direction: column;
+            gap: 6px;
+        }
+        .float-nav button,
+        .float-nav a {
+            padding: 6px 10px;
+            border: none;
+            border-radius: 4px;
+            background-color: rgba(0,0,0,0.55);
+            color: #fff;
+            font-size: 0.85em;
+            text-align: center;
+            text-decoration: none;
+            cursor: pointer;
+        }
+        .float-nav button:hover,
+        .float-nav a:hover {
+            background-color: rgba(0,0,0,0.75);
+        }
+        /* 本块内的阅读进度条：用 transform 缩放而不是改宽度，滚动时不触发重新排版 */
+        .scroll-progress {
+            position: fixed;
+            top: 0;
+            left: 0;
+            width: 100%;
+            height: 3px;
+            background-color: #0066cc;
+            transform: scaleX(0);
+            transform-origin: left;
+            z-index: 1000;
+            pointer-events: none;
+        }
+        html.vertical .scroll-progress {
+            transform-origin: right;
+        }
+        html.dark-mode .scroll-progress {
+            background-color: #4a90d9;
+        }
+        html.dark-mode .page-header,
+        html.dark-mode .page-footer {
+            color: #999;
+        }
+        /* 竖排：正文从右往左逐列排列，固定高度后横向滚动；不分栏，翻页按钮也改为右边是上一页 */
+        html.vertical .content {
+            writing-mode: vertical-rl;
+            height: 75vh;
+            overflow-x: auto;
+            column-count: 1 !important;
+        }
+        html.vertical .chunk-nav {
+            flex-direction: row-reverse;
+        }
+        /* 不自动换行：长行保持原样，正文区横向滚动，适合代码和表格式的文本；Markdown 正文由其中的元素排版，不受影响 */
+        html.no-wrap .content:not(.markdown) {
+            white-space: pre;
+            overflow-x: auto;
+        }
+        /* 开头重复的上一块内容：淡色显示，与本块正文之间以虚线分隔 */
+        .content .overlap {
+            opacity: 0.45;
+            border-bottom: 1px dashed currentColor;
+            margin-bottom: 0.5em;
+        }
+        /* 逐段显示：尚未显示的段不排版、不占位置，滚动接近时由脚本显示；
+           不支持 hidden="until-found" 的浏览器同样按此处理，不支持 content-visibility 时直接显示 */
+        .content .reveal[hidden] {
+            display: block;
+            content-visibility: hidden;
+        }
+        /* 两端对齐：纯文本的每行以换行结束，按段落最后一行处理不会被拉开，
+           只有一段折成多行时前面几行对齐两端；原文已按固定宽度断行时基本没有效果 */
+        html.justify .content {
+            text-align: justify;
+            text-justify: auto;
+            hyphens: auto;
+        }
+        /* 首行缩进：纯文本段首的空标记撑开两个字（竖排时为两个字的高度），Markdown 缩进每个段落。
+           哪些行是段首在转换时决定（空行分隔或每行一段），与段落间距、两端对齐各自独立 */
+        html.first-line-indent .content .indent {
+            display: inline-block;
+            inline-size: 2em;
+        }
+        html.first-line-indent .content.markdown p {
+            text-indent: 2em;
+        }
+        /* 夜间模式：统一覆盖两侧、中央背景和文字颜色 */
+        html.dark-mode {
+            --left-bg: #1e1e1e;
+            --center-bg: #1e1e1e;
+            --right-bg: #1e1e1e;
+        }
+        html.dark-mode body,
+        html.dark-mode .content {
+            color: #cccccc;
+        }
+        html.dark-mode .controls {
+            background-color: #2a2a2a;
+        }
+        html.dark-mode button,
+        html.dark-mode .nav-button {
+            background-color: #3a3a3a;
+            color: #cccccc;
+        }
+        html.dark-mode button:hover,
+        html.dark-mode a.nav-button:hover {
+            background-color: #4a4a4a;
+        }
+        html.dark-mode .nav-button.disabled {
+            background-color: #2a2a2a;
+            color: #666;
+        }
+        html.dark-mode :focus-visible {
+            outline-color: #7fb2ff;
+        }
+        /* 窄屏（手机、电子书浏览器）：两侧背景没有意义，正文铺满屏幕；
+           设置面板默认收起，点右下角的按钮从底部弹出，桌面端不受影响 */
+        .controls-toggle {
+            display: none;
+        }
+        @media (max-width: 600px) {
+            body {
+                background: var(--center-bg);
+                padding: 0;
+            }
+            .page-center {
+                padding: 10px;
+            }
+            .content {
+                padding: 12px;
+                box-shadow: none;
+            }
+            .controls-toggle {
+                display: block;
+                position: fixed;
+                right: 12px;
+                bottom: 12px;
+                z-index: 1001;
+                box-shadow: 0 2px 6px rgba(0,0,0,0.3);
+            }
+            /* 放在设置按钮上方，设置面板弹出时隐藏 */
+            .float-nav {
+                bottom: 60px;
+            }
+            html.controls-open .float-nav {
+                display: none;
+            }
+            .controls {
+                display: none;
+                position: fixed;
+                left: 0;
+                right: 0;
+                bottom: 0;
+                z-index: 1000;
+                max-height: 70vh;
+                overflow-y: auto;
+                margin: 0;
+                padding-bottom: 60px; /* 留出按钮的位置，最后一项不被挡住 */
+                border-radius: 12px 12px 0 0;
+                box-shadow: 0 -2px 8px rgba(0,0,0,0.2);
+            }
+            html.controls-open .controls {
+                display: flex;
+            }
+        }
+        /* 打印：只保留页眉、正文和页脚，黑字白底，不打印设置面板、翻页导航和两侧背景；
+           自定义样式可以覆盖 --print-text、--print-bg 改用其他颜色 */
+        @page {
+            margin: 2cm 1.8cm;
+        }
+        @media print {
+            :root {
+                --print-text: #000;
+                --print-bg: #fff;
+            }
+            body {
+                background: var(--print-bg) !important;
+                padding: 0;
+            }
+            .controls,
+            .controls-toggle,
+            .float-nav,
+            .chunk-nav,
+            .scroll-progress,
+            .chapter-indicator,
+            .skip-link,
+            .overlap {
+                display: none !important;
+            }
+            .content .reveal[hidden] {
+                content-visibility: visible;
+            }
+            .page-center {
+                max-width: none;
+                padding: 0;
+            }
+            /* 正文的颜色由脚本按阅读设置写在元素上，需要 !important 覆盖 */
+            .content,
+            .page-header,
+            .page-footer {
+                color: var(--print-text) !important;
+                background: var(--print-bg) !important;
+            }
+            .content {
+                box-shadow: none;
+                border-radius: 0;
+                padding: 0;
+                min-height: 0;
+            }
+            .content a {
+                color: inherit;
+            }
+            .chapter-heading {
+                break-after: avoid;
+            }
+        }
+`
+
+// 完整页面共用的阅读脚本，与 readerStyle 一样内联或写入 ReaderScriptFile。
+// 本页的文件名、导航链接等由页面中的内联脚本写入 window.txt2htmlPage
+const readerScript = `
+        // 确保DOM加载完成后执行
+        document.addEventListener('DOMContentLoaded', function() {
+            // 本页的数据，由页面中的内联脚本提供
+            const page = window.txt2htmlPage;
+            // 界面文字，格式中的 %d、%s 依次换成 args
+            function label(key, ...args) {
+                let i = 0;
+                return (page.labels[key] || '').replace(/%[ds]/g, function() { return args[i++]; });
+            }
+            // 获取元素引用
+            const contentElement = document.getElementById('mainContent');
+            // 滚动到元素处；合并为单个页面时先展开它所在的部分
+            function reveal(element) {
+                showSegments(element);
+                const part = element.closest('details.part');
+                if (part) part.open = true;
+                element.scrollIntoView({block: 'center'});
+            }
+            // 逐段显示：块中后续的各段在 .reveal[hidden] 中，滚动接近时才显示，很大的块也能很快显示出第一屏
+            const revealSegments = Array.prototype.map.call(contentElement.querySelectorAll('.reveal'), function(segment) {
+                return {element: segment, chars: segment.textContent.length};
+            });
+            const contentChars = revealSegments.length > 0 ? contentElement.textContent.length : 0;
+            // 显示 element 所在的段及之前的各段，element 为空时全部显示
+            function showSegments(element) {
+                const last = element ? element.closest('.reveal') : null;
+                if (element && !last) return;
+                for (let i = 0; i < revealSegments.length; i++) {
+                    revealSegments[i].element.hidden = false;
+                    if (revealSegments[i].element === last) break;
+                }
+            }
+            // 显示接近屏幕的段：横排时在下方三屏以内，竖排时在左侧两屏以内；合并页面中折叠的部分跳过
+            function fillSegments() {
+                for (let i = 0; i < revealSegments.length; i++) {
+                    const segment = revealSegments[i].element;
+                    if (!segment.hidden || segment.closest('details.part:not([open])')) continue;
+                    const rect = segment.getBoundingClientRect();
+                    if (settings.vertical ? rect.left < -2 * window.innerWidth : rect.top > 3 * window.innerHeight) break;
+                    segment.hidden = false;
+                }
+            }
+            // 已显示的文字占全块的比例，还有未显示的段时用来把滚动位置折算为全块中的位置
+            function shownShare() {
+                let hidden = 0;
+                revealSegments.forEach(function(segment) {
+                    if (segment.element.hidden) hidden += segment.chars;
+                });
+                return contentChars > 0 ? 1 - hidden / contentChars : 1;
+            }
+            // 浏览器的页内查找或锚点定位到未显示的段时，之前的各段一起显示，正文保持连续
+            contentElement.addEventListener('beforematch', function(e) {
+                showSegments(e.target);
+            });
+
+            // 阅读主题预设：一次设置两侧背景、中间背景和文字颜色，颜色都在各下拉菜单中，选择后仍可单独微调
+            const themes = {
+                paperwhite: { left: '#f5f5f5', center: '#ffffff', right: '#f5f5f5', text: '#333333' },
+                sepia: { left: '#e8dcc0', center: '#f4ecd8', right: '#e8dcc0', text: '#5B4636' },
+                solarized: { left: '#eee8d5', center: '#fdf6e3', right: '#eee8d5', text: '#657b83' },
+                night: { left: '#1a1a1a', center: '#262626', right: '#1a1a1a', text: '#c8c8c8' }
+            };
+            // 默认主题由生成时指定
+            const defaultTheme = themes[page.theme] ? page.theme : 'paperwhite';
+
+            // 阅读设置：同一本书的所有分块共用一个命名空间键，翻页后设置保持不变
+            const storageKey = 'txt2html:' + page.fileName;
+            const defaultSettings = {
+                fontSize: 16,
+                fontWeight: 400, // 正文字重，300 到 700，与字体大小分开调节
+                lineHeight: 1.6, // 默认行距
+                paragraphSpacing: 1, // 段落间空行的高度，以行高为单位；每行一段时段前的间距为它的一半
+                columns: page.columns, // 生成时指定的分栏数
+                fontFamily: '', // 空字符串表示使用页面默认字体
+                textColor: themes[defaultTheme].text,
+                centerBg: themes[defaultTheme].center,
+                leftBg: themes[defaultTheme].left,
+                rightBg: themes[defaultTheme].right,
+                theme: defaultTheme, // 当前的主题预设，单独调整颜色后为空
+                darkMode: false,
+                vertical: false, // 竖排（从右往左阅读）
+                noWrap: false, // 长行不自动换行，改为横向滚动
+                justify: page.justify, // 两端对齐，默认值由生成时指定
+                firstLineIndent: page.firstLineIndent, // 首行缩进，默认值由生成时指定
+                ttsRate: 1 // 朗读语速，0.5 到 2 倍
+            };
+            let settings = Object.assign({}, defaultSettings);
+            try {
+                const saved = JSON.parse(localStorage.getItem(storageKey));
+                if (saved) Object.assign(settings, saved);
+            } catch (e) {
+                // 本地存储不可用或数据损坏时使用默认设置
+            }
+            function saveSettings() {
+                try {
+                    localStorage.setItem(storageKey, JSON.stringify(settings));
+                } catch (e) {
+                    // 无痕模式等情况下无法写入，忽略即可
+                }
+            }
+
+            // 颜色下拉菜单与预览色块
+            const centerColorSelect = document.getElementById('centerColorSelect');
+            const centerColorPreview = document.getElementById('centerColorPreview');
+            const leftColorSelect = document.getElementById('leftColorSelect');
+            const rightColorSelect = document.getElementById('rightColorSelect');
+            const leftPreview = document.getElementById('leftColorPreview');
+            const rightPreview = document.getElementById('rightColorPreview');
+            const textColorSelect = document.getElementById('textColorSelect');
+            const textColorPreview = document.getElementById('textColorPreview');
+            const darkModeToggle = document.getElementById('darkModeToggle');
+            const themeSelect = document.getElementById('themeSelect');
+
+            // 按当前设置应用所有颜色；夜间模式下由 CSS 类统一配色，清除内联颜色以免覆盖
+            function applyColors() {
+                const root = document.documentElement;
+                root.classList.toggle('dark-mode', settings.darkMode);
+                if (settings.darkMode) {
+                    root.style.removeProperty('--left-bg');
+                    root.style.removeProperty('--center-bg');
+                    root.style.removeProperty('--right-bg');
+                    contentElement.style.color = '';
+                    contentElement.style.backgroundColor = '';
+                } else {
+                    root.style.setProperty('--left-bg', settings.leftBg);
+                    root.style.setProperty('--center-bg', settings.centerBg);
+                    root.style.setProperty('--right-bg', settings.rightBg);
+                    contentElement.style.color = settings.textColor;
+                    contentElement.style.backgroundColor = settings.centerBg;
+                }
+                darkModeToggle.textContent = settings.darkMode ? label('lightMode') : label('darkMode');
+
+                centerColorSelect.value = settings.centerBg;
+                centerColorPreview.style.background = settings.centerBg;
+                leftColorSelect.value = settings.leftBg;
+                leftPreview.style.background = settings.leftBg;
+                rightColorSelect.value = settings.rightBg;
+                rightPreview.style.background = settings.rightBg;
+                textColorSelect.value = settings.textColor;
+                textColorPreview.style.background = settings.textColor;
+                themeSelect.value = settings.theme || '';
+            }
+
+            // 下拉菜单选择颜色后更新设置；手动选色即退出夜间模式
+            function bindColorSelect(select, key) {
+                select.addEventListener('change', function() {
+                    settings[key] = this.value;
+                    settings.theme = '';
+                    settings.darkMode = false;
+                    applyColors();
+                    saveSettings();
+                });
+            }
+            bindColorSelect(centerColorSelect, 'centerBg');
+            bindColorSelect(leftColorSelect, 'leftBg');
+            bindColorSelect(rightColorSelect, 'rightBg');
+            bindColorSelect(textColorSelect, 'textColor');
+
+            themeSelect.addEventListener('change', function() {
+                const theme = themes[this.value];
+                if (!theme) return;
+                settings.theme = this.value;
+                settings.leftBg = theme.left;
+                settings.centerBg = theme.center;
+                settings.rightBg = theme.right;
+                settings.textColor = theme.text;
+                settings.darkMode = false;
+                applyColors();
+                saveSettings();
+            });
+
+            // 夜间模式切换
+            window.toggleDarkMode = function() {
+                settings.darkMode = !settings.darkMode;
+                applyColors();
+                saveSettings();
+            };
+
+            // 竖排切换
+            const verticalToggle = document.getElementById('verticalToggle');
+            function applyVertical() {
+                document.documentElement.classList.toggle('vertical', settings.vertical);
+                verticalToggle.textContent = settings.vertical ? label('horizontal') : label('vertical');
+            }
+            window.toggleVertical = function() {
+                settings.vertical = !settings.vertical;
+                applyVertical();
+                saveSettings();
+                scheduleScrollProgress();
+            };
+
+            // 自动换行切换
+            const wrapToggle = document.getElementById('wrapToggle');
+            function applyWrap() {
+                document.documentElement.classList.toggle('no-wrap', settings.noWrap);
+                wrapToggle.textContent = settings.noWrap ? label('wrap') : label('noWrap');
+            }
+            window.toggleWrap = function() {
+                settings.noWrap = !settings.noWrap;
+                applyWrap();
+                saveSettings();
+                scheduleScrollProgress();
+            };
+
+            // 窄屏上设置面板收起为底部弹出的菜单，Esc 键或再次点击按钮关闭
+            const controlsToggle = document.getElementById('controlsToggle');
+            function setControlsOpen(open) {
+                document.documentElement.classList.toggle('controls-open', open);
+                controlsToggle.setAttribute('aria-expanded', open);
+                controlsToggle.textContent = open ? label('closeSettings') : label('openSettings');
+            }
+            window.toggleControls = function() {
+                setControlsOpen(!document.documentElement.classList.contains('controls-open'));
+            };
+            document.addEventListener('keydown', function(e) {
+                if (e.key === 'Escape' && document.documentElement.classList.contains('controls-open')) {
+                    setControlsOpen(false);
+                    controlsToggle.focus();
+                }
+            });
+
+            // 两端对齐切换
+            const justifyToggle = document.getElementById('justifyToggle');
+            function applyJustify() {
+                document.documentElement.classList.toggle('justify', settings.justify);
+                justifyToggle.textContent = settings.justify ? label('alignLeft') : label('justify');
+            }
+            window.toggleJustify = function() {
+                settings.justify = !settings.justify;
+                applyJustify();
+                saveSettings();
+            };
+
+            // 首行缩进切换，纯文本页面生成时没有插入缩进标记则不显示按钮
+            const indentToggle = document.getElementById('indentToggle');
+            function applyIndent() {
+                document.documentElement.classList.toggle('first-line-indent', settings.firstLineIndent);
+                if (indentToggle) indentToggle.textContent = settings.firstLineIndent ? label('noIndent') : label('indent');
+            }
+            window.toggleIndent = function() {
+                settings.firstLineIndent = !settings.firstLineIndent;
+                applyIndent();
+                saveSettings();
+            };
+
+            // 字体大小调节功能
+            function applyFontSize() {
+                contentElement.style.fontSize = settings.fontSize + "px";
+                document.getElementById("fontSizeDisplay").textContent = settings.fontSize + "px";
+            }
+            window.changeFontSize = function(change) {
+                settings.fontSize += change;
+                // 限制字体大小范围
+                if (settings.fontSize < 10) settings.fontSize = 10;
+                if (settings.fontSize > 36) settings.fontSize = 36;
+
+                applyFontSize();
+                saveSettings();
+            };
+
+            // 字体粗细调节：视力不佳时加粗正文更易辨认，Markdown 中的粗体随之相对加粗
+            function applyFontWeight() {
+                contentElement.style.fontWeight = settings.fontWeight;
+                document.getElementById('fontWeightDisplay').textContent = settings.fontWeight;
+            }
+            window.changeFontWeight = function(change) {
+                settings.fontWeight = Math.min(700, Math.max(300, settings.fontWeight + change));
+                applyFontWeight();
+                saveSettings();
+            };
+
+            // 行距调节功能
+            function applyLineHeight() {
+                // 保留一位小数显示
+                const displayValue = settings.lineHeight.toFixed(1);
+                contentElement.style.lineHeight = settings.lineHeight;
+                document.getElementById("lineHeightDisplay").textContent = displayValue;
+            }
+            window.changeLineHeight = function(change) {
+                // 四舍五入到一位小数，避免浮点累加误差被保存下来
+                settings.lineHeight = Math.round((settings.lineHeight + change) * 10) / 10;
+                // 限制行距范围（0.8到3.0之间）
+                if (settings.lineHeight < 0.8) settings.lineHeight = 0.8;
+                if (settings.lineHeight > 3.0) settings.lineHeight = 3.0;
+
+                applyLineHeight();
+                saveSettings();
+            };
+
+            // 段落间距调节：0 表示段落间不留空行
+            function applyParagraphSpacing() {
+                contentElement.style.setProperty('--paragraph-spacing', settings.paragraphSpacing);
+                document.getElementById('paragraphSpacingDisplay').textContent = settings.paragraphSpacing.toFixed(1);
+            }
+            window.changeParagraphSpacing = function(change) {
+                settings.paragraphSpacing = Math.min(3, Math.max(0, settings.paragraphSpacing + change));
+                applyParagraphSpacing();
+                saveSettings();
+            };
+
+            // 分栏调节：最多 maxColumns 栏，窄屏上栏数多了每栏太窄
+            const maxColumns = 4;
+            function applyColumns() {
+                contentElement.style.columnCount = settings.columns;
+                document.getElementById('columnsDisplay').textContent = label('columnCount', settings.columns);
+            }
+            window.changeColumns = function(change) {
+                settings.columns = Math.min(maxColumns, Math.max(1, settings.columns + change));
+                applyColumns();
+                saveSettings();
+            };
+
+            // 字体选择：未在列表中的字体（例如保存后选项有变化）回退为默认字体
+            const fontFamilySelect = document.getElementById('fontFamilySelect');
+            function applyFontFamily() {
+                contentElement.style.fontFamily = settings.fontFamily;
+                fontFamilySelect.value = settings.fontFamily;
+                if (fontFamilySelect.value !== settings.fontFamily) fontFamilySelect.value = '';
+            }
+            fontFamilySelect.addEventListener('change', function() {
+                settings.fontFamily = this.value;
+                applyFontFamily();
+                saveSettings();
+            });
+
+            // 页内查找：在正文的文本节点中查找并用 <mark> 包裹匹配，不改动其余DOM；
+            // 清除时把 <mark> 还原为文本节点并合并，正文恢复原样（pre-wrap 的空白与换行不受影响）
+            const searchInput = document.getElementById('searchInput');
+            const searchCount = document.getElementById('searchCount');
+            let searchQuery = '';
+            let searchHits = [];
+            let searchIndex = -1;
+
+            function clearSearch() {
+                searchHits.forEach(function(mark) {
+                    mark.replaceWith(document.createTextNode(mark.textContent));
+                });
+                contentElement.normalize();
+                searchHits = [];
+                searchIndex = -1;
+            }
+
+            function runSearch(query) {
+                clearSearch();
+                searchQuery = query;
+                if (!query) return;
+
+                // 先收集文本节点，避免边遍历边修改DOM
+                const walker = document.createTreeWalker(contentElement, NodeFilter.SHOW_TEXT);
+                const nodes = [];
+                while (walker.nextNode()) nodes.push(walker.currentNode);
+
+                const needle = query.toLowerCase();
+                nodes.forEach(function(node) {
+                    const text = node.nodeValue;
+                    const lowered = text.toLowerCase();
+                    // 转小写后长度不变时才做大小写不敏感匹配，保证下标与原文一致
+                    const ignoreCase = lowered.length === text.length && needle.length === query.length;
+                    const hay = ignoreCase ? lowered : text;
+                    const key = ignoreCase ? needle : query;
+                    const positions = [];
+                    let pos = hay.indexOf(key);
+                    while (pos !== -1) {
+                        positions.push(pos);
+                        pos = hay.indexOf(key, pos + key.length);
+                    }
+                    // 从后往前拆分，前面匹配的下标不受影响
+                    const marks = [];
+                    for (let i = positions.length - 1; i >= 0; i--) {
+                        const match = node.splitText(positions[i]);
+                        match.splitText(key.length);
+                        const mark = document.createElement('mark');
+                        mark.className = 'search-hit';
+                        match.replaceWith(mark);
+                        mark.appendChild(match);
+                        marks.unshift(mark);
+                    }
+                    searchHits.push.apply(searchHits, marks);
+                });
+            }
+
+            function showSearchHit() {
+                searchHits.forEach(function(mark, i) {
+                    mark.classList.toggle('current', i === searchIndex);
+                });
+                if (searchIndex >= 0) {
+                    reveal(searchHits[searchIndex]);
+                }
+                searchCount.textContent = (searchIndex + 1) + '/' + searchHits.length;
+            }
+
+            // 查询词变化时重新查找并定位到第一个匹配，否则前后移动
+            window.searchStep = function(direction) {
+                const query = searchInput.value;
+                if (query !== searchQuery) {
+                    runSearch(query);
+                    searchIndex = searchHits.length > 0 ? 0 : -1;
+                } else if (searchHits.length > 0) {
+                    searchIndex = (searchIndex + direction + searchHits.length) % searchHits.length;
+                }
+                showSearchHit();
+            };
+            searchInput.addEventListener('keydown', function(e) {
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    window.searchStep(e.shiftKey ? -1 : 1);
+                }
+            });
+            // 从全书搜索页面跳转过来时（#search=关键词），自动定位到第一个匹配处
+            if (location.hash.indexOf('#search=') === 0) {
+                try {
+                    searchInput.value = decodeURIComponent(location.hash.slice('#search='.length));
+                } catch (err) {
+                    searchInput.value = '';
+                }
+                if (searchInput.value) window.searchStep(1);
+            }
+
+            // 书签：找到视口顶部处最近的锚点（行号锚点或章节标题），复制指向该位置的链接
+            const bookmarkButton = document.getElementById('bookmarkButton');
+            function currentAnchor() {
+                const anchors = contentElement.querySelectorAll('.line-anchor, .chapter-heading, .chapter-anchor');
+                // 锚点按文档顺序排列，二分查找最后一个位于视口顶部以上的锚点
+                let lo = 0, hi = anchors.length - 1, found = null;
+                while (lo <= hi) {
+                    const mid = (lo + hi) >> 1;
+                    if (anchors[mid].getBoundingClientRect().top <= 5) {
+                        found = anchors[mid];
+                        lo = mid + 1;
+                    } else {
+                        hi = mid - 1;
+                    }
+                }
+                return found;
+            }
+            window.copyBookmark = function() {
+                const anchor = currentAnchor();
+                const url = location.href.split('#')[0] + (anchor ? '#' + anchor.id : '');
+                function copied() {
+                    bookmarkButton.textContent = label('copied');
+                    setTimeout(function() { bookmarkButton.textContent = label('copyBookmark'); }, 1500);
+                }
+                // 剪贴板接口不可用或被拒绝时，弹出输入框让用户手动复制
+                if (navigator.clipboard && navigator.clipboard.writeText) {
+                    navigator.clipboard.writeText(url).then(copied, function() {
+                        window.prompt(label('copyBookmark'), url);
+                    });
+                } else {
+                    window.prompt(label('copyBookmark'), url);
+                }
+            };
+
+            // 书签列表：可以在任意位置添加带名称的书签，记录所在分块和块内滚动比例，
+            // 同一本书的所有分块共用一个列表。点击其他分块的书签时跳转到 <分块>#bookmark-<编号>，打开后再滚动到记录的位置
+            const bookmarksKey = 'txt2html-bookmarks:' + page.fileName;
+            const bookmarkList = document.getElementById('bookmarkList');
+            const bookmarkCount = document.getElementById('bookmarkCount');
+            function loadBookmarks() {
+                try {
+                    const list = JSON.parse(localStorage.getItem(bookmarksKey));
+                    return Array.isArray(list) ? list : [];
+                } catch (e) {
+                    return [];
+                }
+            }
+            function saveBookmarks(list) {
+                try {
+                    localStorage.setItem(bookmarksKey, JSON.stringify(list));
+                } catch (e) {
+                    // 无法写入时本次添加或删除不会保留
+                }
+            }
+            function goToBookmark(mark) {
+                if (mark.chunkFile === page.currentFile) {
+                    scrollToRatio(mark.scrollRatio);
+                } else {
+                    window.location.href = mark.chunkFile + '#bookmark-' + mark.id;
+                }
+            }
+            // 名称等都以 textContent 写入，书签名称中的HTML不会被执行
+            function renderBookmarks() {
+                const list = loadBookmarks();
+                bookmarkCount.textContent = list.length;
+                bookmarkList.textContent = '';
+                list.forEach(function(mark) {
+                    const item = document.createElement('li');
+                    const link = document.createElement('a');
+                    link.href = mark.chunkFile + '#bookmark-' + mark.id;
+                    link.textContent = mark.label;
+                    link.addEventListener('click', function(e) {
+                        e.preventDefault();
+                        goToBookmark(mark);
+                    });
+                    const meta = document.createElement('span');
+                    meta.className = 'bookmark-meta';
+                    meta.textContent = (page.single ? '' : label('part', mark.chunk) + ' · ') + Math.round((Number(mark.scrollRatio) || 0) * 100) + '%';
+                    const remove = document.createElement('button');
+                    remove.textContent = label('remove');
+                    remove.setAttribute('aria-label', label('removeBookmark', mark.label));
+                    remove.addEventListener('click', function() {
+                        saveBookmarks(loadBookmarks().filter(function(m) { return m.id !== mark.id; }));
+                        renderBookmarks();
+                    });
+                    item.append(link, meta, remove);
+                    bookmarkList.appendChild(item);
+                });
+            }
+            window.addBookmark = function() {
+                const ratio = readingRatio();
+                const chapter = chapterIndicator.textContent.trim();
+                const fallback = (chapter || (page.single ? label('bookmark') : label('part', page.currentChunk))) + ' · ' + Math.round(ratio * 100) + '%';
+                const name = window.prompt(label('bookmarkName'), fallback);
+                if (name === null) return;
+                const list = loadBookmarks();
+                // 编号按添加时间生成，同一毫秒内连续添加时顺延，保证互不相同
+                let id = Date.now();
+                list.forEach(function(mark) {
+                    if (mark.id >= id) id = mark.id + 1;
+                });
+                list.push({
+                    id: id,
+                    label: name.trim() || fallback,
+                    chunkFile: page.currentFile,
+                    chunk: page.currentChunk,
+                    scrollRatio: ratio
+                });
+                saveBookmarks(list);
+                renderBookmarks();
+                document.getElementById('bookmarksPanel').open = true;
+            };
+            // 在其他标签页中添加或删除书签后同步列表
+            window.addEventListener('storage', function(e) {
+                if (e.key === bookmarksKey) renderBookmarks();
+            });
+
+            // 当前章节提示：视口顶部以上最后一个章节标题，本块中还没有经过标题时为本块开头所在的章节。
+            // 标题进出视口时才重新计算，不必监听每次滚动
+            const chapterIndicator = document.getElementById('chapterIndicator');
+            const chapterMarks = contentElement.querySelectorAll('[data-chapter]');
+            const startChapter = page.chapter;
+            function updateChapterIndicator() {
+                let current = startChapter;
+                for (let i = 0; i < chapterMarks.length; i++) {
+                    if (chapterMarks[i].getBoundingClientRect().top > 5) break;
+                    current = chapterMarks[i].getAttribute('data-chapter');
+                }
+                chapterIndicator.textContent = current;
+            }
+            if (chapterMarks.length > 0 && 'IntersectionObserver' in window) {
+                const chapterObserver = new IntersectionObserver(updateChapterIndicator);
+                chapterMarks.forEach(function(mark) { chapterObserver.observe(mark); });
+            }
+
+            // 跳转到第 n 部分：按文件名模板拼出目标文件名，{n:04d} 之类的宽度与生成时的格式一致；
+            // 合并为单个页面时展开并滚动到该部分
+            const totalChunks = page.totalChunks;
+            const filePattern = page.filePattern;
+            const jumpInput = document.getElementById('jumpInput');
+            const jumpError = document.getElementById('jumpError');
+            function chunkFileName(n) {
+                return filePattern.replace(/\{n(?::(0?)(\d*)d)?\}/g, function(match, zero, width) {
+                    return String(n).padStart(Number(width) || 0, zero ? '0' : ' ');
+                });
+            }
+            window.jumpToChunk = function() {
+                const n = Number(jumpInput.value);
+                if (!Number.isInteger(n) || n < 1 || n > totalChunks) {
+                    jumpError.textContent = label('jumpError', totalChunks);
+                    return;
+                }
+                jumpError.textContent = '';
+                if (page.single) {
+                    reveal(document.getElementById('part-' + n));
+                } else {
+                    window.location.href = chunkFileName(n);
+                }
+            };
+            jumpInput.addEventListener('keydown', function(e) {
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    window.jumpToChunk();
+                }
+            });
+
+            // 阅读进度条：横排按页面滚动位置计算，竖排时正文在自身区域内横向滚动（向左为正向）
+            const scrollProgress = document.getElementById('scrollProgress');
+            let progressPending = false;
+            function readingRatio() {
+                let ratio;
+                if (settings.vertical) {
+                    const range = contentElement.scrollWidth - contentElement.clientWidth;
+                    ratio = range > 0 ? Math.abs(contentElement.scrollLeft) / range : 1;
+                } else {
+                    const range = document.documentElement.scrollHeight - window.innerHeight;
+                    ratio = range > 0 ? window.scrollY / range : 1;
+                }
+                return Math.min(1, Math.max(0, ratio)) * shownShare();
+            }
+            function scrollToRatio(ratio) {
+                // 比例是相对全块记录的，先显示全部的段
+                if (ratio > 0) showSegments();
+                if (settings.vertical) {
+                    // 从右往左排列时 scrollLeft 为负数
+                    contentElement.scrollLeft = -ratio * (contentElement.scrollWidth - contentElement.clientWidth);
+                } else {
+                    window.scrollTo(0, ratio * (document.documentElement.scrollHeight - window.innerHeight));
+                }
+            }
+            window.backToTop = function() {
+                scrollToRatio(0);
+            };
+            function updateScrollProgress() {
+                progressPending = false;
+                fillSegments();
+                const ratio = readingRatio();
+                scrollProgress.style.transform = 'scaleX(' + ratio + ')';
+                scrollProgress.setAttribute('aria-valuenow', Math.round(ratio * 100));
+            }
+            // 每帧最多更新一次，滚动事件再频繁也不会卡顿
+            function scheduleScrollProgress() {
+                schedulePositionSave();
+                if (progressPending) return;
+                progressPending = true;
+                window.requestAnimationFrame(updateScrollProgress);
+            }
+
+            // 继续阅读：整本书只记一个阅读位置（所在分块和块内滚动比例），目录页和各分块据此跳回。
+            // 只在滚动时记录，仅仅打开某一块不会覆盖原来的位置
+            const positionKey = 'txt2html-position:' + page.fileName;
+            let resumePosition = null;
+            try {
+                resumePosition = JSON.parse(localStorage.getItem(positionKey));
+            } catch (e) {
+                // 没有记录或数据损坏时不显示继续阅读
+            }
+            let positionTimer = 0;
+            function savePosition() {
+                clearTimeout(positionTimer);
+                positionTimer = 0;
+                try {
+                    localStorage.setItem(positionKey, JSON.stringify({chunkFile: page.currentFile, scrollRatio: readingRatio()}));
+                } catch (e) {
+                    // 无法写入时忽略
+                }
+            }
+            // 滚动期间最多每半秒写入一次
+            function schedulePositionSave() {
+                if (!positionTimer) positionTimer = setTimeout(savePosition, 500);
+            }
+            window.addEventListener('pagehide', function() {
+                if (positionTimer) savePosition();
+            });
+            const resumeButton = document.getElementById('resumeButton');
+            window.resumeReading = function() {
+                resumeButton.hidden = true;
+                if (resumePosition.chunkFile === page.currentFile) {
+                    scrollToRatio(resumePosition.scrollRatio);
+                } else {
+                    window.location.href = resumePosition.chunkFile + '#resume';
+                }
+            };
+            window.addEventListener('scroll', scheduleScrollProgress, {passive: true});
+            window.addEventListener('resize', scheduleScrollProgress);
+            contentElement.addEventListener('scroll', scheduleScrollProgress, {passive: true});
+
+            // 朗读：用浏览器的语音合成逐句朗读本块正文，读完后自动翻到下一块接着读。
+            // 整块文本一次交给语音合成时，部分浏览器读到十几秒就会中断，因此按句切分，每次只读一句
+            const speech = window.speechSynthesis;
+            const ttsSection = document.getElementById('ttsSection');
+            const ttsPlay = document.getElementById('ttsPlay');
+            const ttsStop = document.getElementById('ttsStop');
+            const ttsRate = document.getElementById('ttsRate');
+            const ttsContinueKey = 'txt2html-tts:' + page.fileName; // 自动翻页后在下一块开头继续朗读
+            const ttsMaxLength = 200; // 没有标点的长句按此长度再拆开
+            let ttsSegments = null; // 本次朗读的句子：所在的文本节点及起止位置
+            let ttsIndex = 0;
+            let ttsState = 'idle'; // idle、playing 或 paused
+            let ttsGeneration = 0; // 每读一句或停止时加一，被取消的句子随后触发的事件据此忽略
+            function ttsCollect() {
+                const segments = [];
+                const walker = document.createTreeWalker(contentElement, NodeFilter.SHOW_TEXT, {
+                    acceptNode: function(node) {
+                        // 开头重复的上一块内容已经读过
+                        return node.parentElement.closest('.overlap, [aria-hidden="true"]') ? NodeFilter.FILTER_REJECT : NodeFilter.FILTER_ACCEPT;
+                    }
+                });
+                const sentence = /[^。！？!?…；;\n]+[。！？!?…；;”’」』）)"']*/g;
+                for (let node = walker.nextNode(); node; node = walker.nextNode()) {
+                    sentence.lastIndex = 0;
+                    let m;
+                    while ((m = sentence.exec(node.data))) {
+                        const end = m.index + m[0].length;
+                        for (let start = m.index; start < end; start += ttsMaxLength) {
+                            const stop = Math.min(start + ttsMaxLength, end);
+                            if (node.data.slice(start, stop).trim()) segments.push({node: node, start: start, end: stop});
+                        }
+                    }
+                }
+                return segments;
+            }
+            function ttsRect(segment) {
+                const range = document.createRange();
+                range.setStart(segment.node, segment.start);
+                range.setEnd(segment.node, segment.end);
+                return range.getBoundingClientRect();
+            }
+            // 从屏幕上第一句可见的内容开始读；竖排时按行的位置难以判断，从头开始
+            function ttsFirstVisible() {
+                if (settings.vertical) return 0;
+                for (let i = 0; i < ttsSegments.length; i++) {
+                    if (ttsRect(ttsSegments[i]).bottom > 0) return i;
+                }
+                return 0;
+            }
+            // 正在读的句子移出屏幕时滚动到它，解放双手阅读时不必自己翻
+            function ttsFollow(segment) {
+                if (settings.vertical) return;
+                showSegments(segment.node.parentElement);
+                const part = segment.node.parentElement.closest('details.part');
+                if (part) part.open = true;
+                const rect = ttsRect(segment);
+                if (rect.top < 0 || rect.bottom > window.innerHeight) {
+                    window.scrollBy(0, rect.top - window.innerHeight / 3);
+                }
+            }
+            function setTtsState(state) {
+                ttsState = state;
+                ttsPlay.textContent = state === 'playing' ? label('pause') : state === 'paused' ? label('resume') : label('readAloud');
+                ttsPlay.setAttribute('aria-pressed', state === 'playing');
+                ttsStop.disabled = state === 'idle';
+            }
+            function ttsSpeak() {
+                const generation = ++ttsGeneration;
+                if (ttsIndex >= ttsSegments.length) {
+                    ttsFinish();
+                    return;
+                }
+                const segment = ttsSegments[ttsIndex];
+                const utterance = new SpeechSynthesisUtterance(segment.node.data.slice(segment.start, segment.end));
+                utterance.lang = document.documentElement.lang;
+                utterance.rate = settings.ttsRate;
+                utterance.onend = function() {
+                    if (generation !== ttsGeneration) return;
+                    ttsIndex++;
+                    ttsSpeak();
+                };
+                utterance.onerror = function(e) {
+                    if (generation !== ttsGeneration) return;
+                    // 自动翻页过来时浏览器可能要求先有用户操作才能发声，停下来等待点击
+                    setTtsState('idle');
+                    if (e.error === 'not-allowed') ttsPlay.textContent = label('resumeSpeech');
+                };
+                ttsFollow(segment);
+                speech.speak(utterance);
+            }
+            // 本块读完，有下一块时翻过去继续
+            function ttsFinish() {
+                ttsSegments = null;
+                setTtsState('idle');
+                if (!page.nextFile) return;
+                try {
+                    sessionStorage.setItem(ttsContinueKey, '1');
+                } catch (e) {
+                    // 无法记录时翻页后不再自动继续
+                }
+                window.location.href = page.nextFile;
+            }
+            function ttsStart(fromTop) {
+                speech.cancel();
+                ttsSegments = ttsCollect();
+                ttsIndex = fromTop ? 0 : ttsFirstVisible();
+                setTtsState('playing');
+                ttsSpeak();
+            }
+            window.toggleSpeech = function() {
+                if (ttsState === 'playing') {
+                    speech.pause();
+                    setTtsState('paused');
+                } else if (ttsState === 'paused') {
+                    speech.resume();
+                    setTtsState('playing');
+                } else {
+                    ttsStart(false);
+                }
+            };
+            window.stopSpeech = function() {
+                ttsGeneration++;
+                speech.cancel();
+                ttsSegments = null;
+                setTtsState('idle');
+            };
+            function applyTtsRate() {
+                ttsRate.value = settings.ttsRate;
+                document.getElementById('ttsRateDisplay').textContent = Number(settings.ttsRate).toFixed(1) + '×';
+            }
+            ttsRate.addEventListener('input', function() {
+                settings.ttsRate = Number(ttsRate.value);
+                applyTtsRate();
+                saveSettings();
+                // 语速只能在开始读一句之前设定，从当前这句重新读起
+                if (ttsState === 'playing') {
+                    ttsGeneration++;
+                    speech.cancel();
+                    ttsSpeak();
+                }
+            });
+
+            // 打印：合并为单个页面时打印前展开所有部分，打印后恢复原来的折叠状态
+            let printClosed = [];
+            window.addEventListener('beforeprint', function() {
+                printClosed = Array.prototype.filter.call(document.querySelectorAll('details.part'), function(part) {
+                    return !part.open;
+                });
+                printClosed.forEach(function(part) {
+                    part.open = true;
+                });
+            });
+            window.addEventListener('afterprint', function() {
+                printClosed.forEach(function(part) {
+                    part.open = false;
+                });
+                printClosed = [];
+            });
+            window.printPage = function() {
+                window.print();
+            };
+
+            // 键盘翻页：左/右方向键或 PageUp/PageDown 跳转到上一块/下一块；
+            // 竖排时从右往左阅读，左方向键为下一块
+            const prevFile = page.prevFile;
+            const nextFile = page.nextFile;
+            document.addEventListener('keydown', function(e) {
+                // 焦点在下拉菜单或输入框中时方向键用于选择颜色、移动光标，不做翻页
+                if (e.target.closest && e.target.closest('select, input, textarea')) return;
+                if (e.altKey || e.ctrlKey || e.metaKey || e.shiftKey) return;
+                let target = '';
+                if (e.key === 'ArrowLeft') target = settings.vertical ? nextFile : prevFile;
+                if (e.key === 'ArrowRight') target = settings.vertical ? prevFile : nextFile;
+                if (e.key === 'PageUp') target = prevFile;
+                if (e.key === 'PageDown') target = nextFile;
+                if (target) {
+                    e.preventDefault();
+                    window.location.href = target;
+                }
+            });
+
+            // 恢复上次保存的设置，并同步下拉菜单与预览色块
+            applyFontSize();
+            applyFontWeight();
+            applyLineHeight();
+            applyParagraphSpacing();
+            applyColumns();
+            applyVertical();
+            applyWrap();
+            applyJustify();
+            applyIndent();
+            applyFontFamily();
+            applyColors();
+            updateScrollProgress();
+            renderBookmarks();
+            if (speech && typeof SpeechSynthesisUtterance === 'function') {
+                ttsSection.hidden = false;
+                applyTtsRate();
+                // 离开页面后浏览器不一定停止发声
+                window.addEventListener('pagehide', function() {
+                    ttsGeneration++;
+                    speech.cancel();
+                });
+                let continuing = false;
+                try {
+                    continuing = sessionStorage.getItem(ttsContinueKey) === '1';
+                    sessionStorage.removeItem(ttsContinueKey);
+                } catch (e) {
+                    // 无法读取时不自动继续
+                }
+                if (continuing) ttsStart(true);
+            }
+
+            // 从继续阅读跳转过来时（#resume），排版稳定后再滚动到记录的位置
+            const resuming = location.hash === '#resume' && resumePosition && resumePosition.chunkFile === page.currentFile;
+            // 从其他分块的书签跳转过来时（#bookmark-编号）同样按记录的比例定位
+            const bookmarkMatch = /^#bookmark-(\d+)$/.exec(location.hash);
+            const openedBookmark = bookmarkMatch && loadBookmarks().find(function(mark) {
+                return String(mark.id) === bookmarkMatch[1] && mark.chunkFile === page.currentFile;
+            });
+            if (openedBookmark) {
+                scrollToRatio(openedBookmark.scrollRatio);
+            }
+            if (resuming) {
+                scrollToRatio(resumePosition.scrollRatio);
+            } else if (resumePosition && typeof resumePosition.chunkFile === 'string' && resumePosition.chunkFile) {
+                resumeButton.title = resumePosition.chunkFile + ' · ' + Math.round((Number(resumePosition.scrollRatio) || 0) * 100) + '%';
+                resumeButton.hidden = false;
+            }
+
+            // 通过书签或目录链接（#L行号、#chapter-N）打开时，应用字号等设置后排版会变化，重新定位一次
+            if (!resuming && !bookmarkMatch && location.hash && location.hash.indexOf('#search=') !== 0) {
+                let target = null;
+                try {
+                    target = document.getElementById(decodeURIComponent(location.hash.slice(1)));
+                } catch (err) {
+                    // 无效的锚点，保持浏览器默认位置
+                }
+                if (target) {
+                    showSegments(target);
+                    const part = target.closest('details.part');
+                    if (part) part.open = true;
+                    target.scrollIntoView();
+                }
+            }
+        });
+`
+
+// HTML模板内容 - 支持左右两侧展示背景颜色自定义
+const htmlTemplate = `<!DOCTYPE html>
+<html lang="{{.Label "lang"}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    {{with .Provenance}}<meta name="generator" content="{{.Generator}}">{{end}}
+    <title>{{.Title}}{{if not .Single}} - {{printf (.Label "partTitle") .CurrentChunk}}{{end}}</title>
+    {{if .Favicon}}<link rel="icon" href="{{.Favicon}}">{{end}}
+    <style>
+        :root {
+            --center-max-width: {{.CenterMaxWidth}}px;
+            --columns: {{.Columns}};
+            --tab-width: {{.TabWidth}};
+        }
+    </style>
+    {{if .SharedAssets}}<link rel="stylesheet" href="` + ReaderStyleFile + `">{{else}}<style>` + readerStyle + `    </style>{{end}}
+    {{if .SolidBackground}}<style>
+        /* 纯色背景：整页使用左侧背景色，中央背景只用于正文区域 */
+        body {
+            background: var(--left-bg);
+        }
+    </style>{{end}}
+    <script>
+        // 在首次绘制前应用夜间模式，避免翻页时先闪一下白色背景
+        try {
+            const saved = JSON.parse(localStorage.getItem('txt2html:' + {{.FileName}}));
+            if (saved && saved.darkMode) document.documentElement.classList.add('dark-mode');
+            if (saved && saved.vertical) document.documentElement.classList.add('vertical');
+            if (saved && saved.noWrap) document.documentElement.classList.add('no-wrap');
+            if (saved && typeof saved.justify === 'boolean' ? saved.justify : {{.Justify}}) document.documentElement.classList.add('justify');
+            if (saved && typeof saved.firstLineIndent === 'boolean' ? saved.firstLineIndent : {{.FirstLineIndent}}) document.documentElement.classList.add('first-line-indent');
+        } catch (e) {
+            // 读取失败时保持日间模式
+        }
+    </script>
+</head>
+<body>
+    <a class="skip-link" href="#mainContent">{{.Label "skipToContent"}}</a>
+    <div id="scrollProgress" class="scroll-progress" role="progressbar" aria-label="{{.Label "readingProgress"}}" aria-valuemin="0" aria-valuemax="100" aria-valuenow="0"></div>
+    <div id="chapterIndicator" class="chapter-indicator" aria-live="polite">{{.Chapter}}</div>
+    <button id="controlsToggle" class="controls-toggle" onclick="toggleControls()" aria-controls="readerControls" aria-expanded="false">{{.Label "openSettings"}}</button>
+    <nav class="float-nav" aria-label="{{.Label "quickNav"}}">
+        <button onclick="backToTop()">{{.Label "backToTop"}}</button>
+        {{if .IndexPage}}<a href="{{.IndexPage}}">{{.Label "index"}}</a>{{end}}
+    </nav>
+    <div class="controls" id="readerControls" role="region" aria-label="{{.Label "settings"}}">
+        <!-- 字体大小控制 -->
+        <div class="control-section" role="group" aria-labelledby="fontSizeLabel">
+            <span id="fontSizeLabel">{{.Label "fontSize"}}</span>
+            <div class="control-group">
+                <button onclick="changeFontSize(-1)" aria-label="{{.Label "decreaseFont"}}">A-</button>
+                <span id="fontSizeDisplay" class="display-value" aria-live="polite">16px</span>
+                <button onclick="changeFontSize(1)" aria-label="{{.Label "increaseFont"}}">A+</button>
+            </div>
+        </div>
+
+        <!-- 字体粗细 -->
+        <div class="control-section" role="group" aria-labelledby="fontWeightLabel">
+            <span id="fontWeightLabel">{{.Label "fontWeight"}}</span>
+            <div class="control-group">
+                <button onclick="changeFontWeight(-100)">{{.Label "lighter"}}</button>
+                <span id="fontWeightDisplay" class="display-value" aria-live="polite">400</span>
+                <button onclick="changeFontWeight(100)">{{.Label "bolder"}}</button>
+            </div>
+        </div>
+        
+        <!-- 行距控制 -->
+        <div class="control-section" role="group" aria-labelledby="lineHeightLabel">
+            <span id="lineHeightLabel">{{.Label "lineHeight"}}</span>
+            <div class="control-group">
+                <button onclick="changeLineHeight(-0.2)" aria-label="{{.Label "decreaseLineHeight"}}">{{.Label "lineHeightDown"}}</button>
+                <span id="lineHeightDisplay" class="display-value" aria-live="polite">1.6</span>
+                <button onclick="changeLineHeight(0.2)" aria-label="{{.Label "increaseLineHeight"}}">{{.Label "lineHeightUp"}}</button>
+            </div>
+        </div>
+
+        <!-- 段落间距 -->
+        <div class="control-section" role="group" aria-labelledby="paragraphSpacingLabel">
+            <span id="paragraphSpacingLabel">{{.Label "paragraphSpacing"}}</span>
+            <div class="control-group">
+                <button onclick="changeParagraphSpacing(-0.5)" aria-label="{{.Label "decreaseParagraphSpacing"}}">{{.Label "paragraphSpacingDown"}}</button>
+                <span id="paragraphSpacingDisplay" class="display-value" aria-live="polite">1.0</span>
+                <button onclick="changeParagraphSpacing(0.5)" aria-label="{{.Label "increaseParagraphSpacing"}}">{{.Label "paragraphSpacingUp"}}</button>
+            </div>
+        </div>
+
+        <!-- 分栏 -->
+        <div class="control-section" role="group" aria-labelledby="columnsLabel">
+            <span id="columnsLabel">{{.Label "columns"}}</span>
+            <div class="control-group">
+                <button onclick="changeColumns(-1)" aria-label="{{.Label "fewerColumns"}}">{{.Label "columnsDown"}}</button>
+                <span id="columnsDisplay" class="display-value" aria-live="polite">{{printf (.Label "columnCount") .Columns}}</span>
+                <button onclick="changeColumns(1)" aria-label="{{.Label "moreColumns"}}">{{.Label "columnsUp"}}</button>
+            </div>
+        </div>
+
+        <!-- 字体选择 -->
+        <div class="control-section" role="group" aria-labelledby="fontFamilyLabel">
+            <span id="fontFamilyLabel">{{.Label "fontFamily"}}</span>
+            <div class="control-group">
+                <select id="fontFamilySelect" aria-label="{{.Label "fontFamily"}}">
+                    <option value="" selected>{{.Label "defaultFont"}}</option>
+                    <option value="serif">{{.Label "fontSerif"}}</option>
+                    <option value="sans-serif">{{.Label "fontSansSerif"}}</option>
+                    <option value="monospace">{{.Label "fontMonospace"}}</option>
+                    <option value="'Noto Serif CJK SC', 'Source Han Serif SC', 'Songti SC', SimSun, serif">{{.Label "fontNotoSerif"}}</option>
+                    <option value="'Noto Sans CJK SC', 'Source Han Sans SC', 'PingFang SC', sans-serif">{{.Label "fontNotoSans"}}</option>
+                    <option value="'Microsoft YaHei', 'PingFang SC', sans-serif">{{.Label "fontYaHei"}}</option>
+                    <option value="KaiTi, STKaiti, 'Kaiti SC', serif">{{.Label "fontKaiTi"}}</option>
+                </select>
+            </div>
+        </div>
+        
+        <!-- 阅读主题 -->
+        <div class="control-section" role="group" aria-labelledby="themeLabel">
+            <span id="themeLabel">{{.Label "theme"}}</span>
+            <div class="control-group">
+                <select id="themeSelect" aria-label="{{.Label "chooseTheme"}}">
+                    <option value="">{{.Label "themeCustom"}}</option>
+                    <option value="paperwhite" selected>{{.Label "themePaperwhite"}}</option>
+                    <option value="sepia">{{.Label "themeSepia"}}</option>
+                    <option value="solarized">{{.Label "themeSolarized"}}</option>
+                    <option value="night">{{.Label "themeNight"}}</option>
+                </select>
+            </div>
+        </div>
+
+        <!-- 字体颜色控制 -->
+        <div class="control-section" role="group" aria-labelledby="textColorLabel">
+            <span id="textColorLabel">{{.Label "textColor"}}</span>
+            <div class="control-group">
+                <select id="textColorSelect" aria-label="{{.Label "textColor"}}">
+                    <option value="#111111">{{.Label "colorBlack"}} (#111111)</option>
+                    <option value="#2F4F4F">{{.Label "colorSlate"}} (#2F4F4F)</option>
+                    <option value="#333333" selected>{{.Label "colorDefaultGray"}} (#333333)</option>
+                    <option value="#444444">{{.Label "colorGray"}} (#444444)</option>
+                    <option value="#5B4636">{{.Label "colorWarmBrown"}} (#5B4636)</option>
+                    <option value="#0066cc">{{.Label "colorDarkBlue"}} (#0066cc)</option>
+                    <option value="#006600">{{.Label "colorDarkGreen"}} (#006600)</option>
+                    <option value="#8a2be2">{{.Label "colorPurple"}} (#8a2be2)</option>
+                    <option value="#6B4423">{{.Label "colorSoftBrown"}} (#6B4423)</option>
+                    <option value="#4A4A4A">{{.Label "colorSoftGray"}} (#4A4A4A)</option>
+                    <option value="#657b83">{{.Label "colorSolarized"}} (#657b83)</option>
+                    <option value="#c8c8c8">{{.Label "colorNight"}} (#c8c8c8)</option>
+                </select>
+                <span id="textColorPreview" class="color-preview" aria-hidden="true" style="background:#333"></span>
+            </div>
+        </div>
+        
+        <!-- 背景颜色控制（中间/左侧/右侧） -->
+        <div class="control-section" role="group" aria-labelledby="backgroundLabel">
+            <span id="backgroundLabel">{{.Label "background"}}</span>
+            <div style="display:flex;flex-direction:column;gap:8px;">
+                <div class="control-group">
+                    <span>{{.Label "centerBackground"}}</span>
+                    <select id="centerColorSelect" aria-label="{{.Label "chooseCenterBackground"}}">
+                        <option value="#ffffff" selected>{{.Label "bgWhite"}} (#ffffff)</option>
+                        <option value="#fffdf0">{{.Label "bgWarmWhite"}} (#fffdf0)</option>
+                        <option value="#fffbe6">{{.Label "bgCream"}} (#fffbe6)</option>
+                        <option value="#ffffee">{{.Label "bgLightYellow"}} (#ffffee)</option>
+                        <option value="#f7fff7">{{.Label "bgEyeGreen"}} (#f7fff7)</option>
+                        <option value="#f6f9ff">{{.Label "bgEyeBlue"}} (#f6f9ff)</option>
+                        <option value="#f4ecd8">{{.Label "bgSepia"}} (#f4ecd8)</option>
+                        <option value="#fdf6e3">{{.Label "bgSolarized"}} (#fdf6e3)</option>
+                        <option value="#262626">{{.Label "bgNight"}} (#262626)</option>
+                    </select>
+                    <span id="centerColorPreview" class="color-preview" aria-hidden="true" style="background:#ffffff;margin-left:8px"></span>
+                </div>
+                <div class="control-group">
+                    {{if .SolidBackground}}<span>{{.Label "sideBackground"}}</span>
+                    <select id="leftColorSelect" aria-label="{{.Label "chooseSideBackground"}}">{{else}}<span>{{.Label "leftBackground"}}</span>
+                    <select id="leftColorSelect" aria-label="{{.Label "chooseLeftBackground"}}">{{end}}
+                        <option value="#f5f5f5" selected>{{.Label "bgLightGray"}} (#f5f5f5)</option>
+                        <option value="#ffffff">{{.Label "bgWhite"}} (#ffffff)</option>
+                        <option value="#fffdf0">{{.Label "bgWarmWhiteEye"}} (#fffdf0)</option>
+                        <option value="#fffbe6">{{.Label "bgCreamEye"}} (#fffbe6)</option>
+                        <option value="#ffffee">{{.Label "bgLightYellowEye"}} (#ffffee)</option>
+                        <option value="#f7fff7">{{.Label "bgEyeGreen"}} (#f7fff7)</option>
+                        <option value="#f0fff0">{{.Label "bgLightGreen"}} (#f0fff0)</option>
+                        <option value="#f6f9ff">{{.Label "bgEyeBlue"}} (#f6f9ff)</option>
+                        <option value="#f7f0ff">{{.Label "bgLightPurple"}} (#f7f0ff)</option>
+                        <option value="#eeeae0">{{.Label "bgBeigeGray"}} (#eeeae0)</option>
+                        <option value="#e8dcc0">{{.Label "bgDarkSepia"}} (#e8dcc0)</option>
+                        <option value="#eee8d5">{{.Label "bgSolarizedSide"}} (#eee8d5)</option>
+                        <option value="#1a1a1a">{{.Label "bgNightSide"}} (#1a1a1a)</option>
+                    </select>
+                    <span id="leftColorPreview" class="color-preview" aria-hidden="true" style="background:#f5f5f5;margin-left:8px"></span>
+                </div>
+                <div class="control-group"{{if .SolidBackground}} hidden{{end}}>
+                    <span>{{.Label "rightBackground"}}</span>
+                    <select id="rightColorSelect" aria-label="{{.Label "chooseRightBackground"}}">
+                        <option value="#f5f5f5" selected>{{.Label "bgLightGray"}} (#f5f5f5)</option>
+                        <option value="#ffffff">{{.Label "bgWhite"}} (#ffffff)</option>
+                        <option value="#fffdf0">{{.Label "bgWarmWhiteEye"}} (#fffdf0)</option>
+                        <option value="#fffbe6">{{.Label "bgCreamEye"}} (#fffbe6)</option>
+                        <option value="#ffffee">{{.Label "bgLightYellowEye"}} (#ffffee)</option>
+                        <option value="#f7fff7">{{.Label "bgEyeGreen"}} (#f7fff7)</option>
+                        <option value="#f0fff0">{{.Label "bgLightGreen"}} (#f0fff0)</option>
+                        <option value="#f6f9ff">{{.Label "bgEyeBlue"}} (#f6f9ff)</option>
+                        <option value="#f7f0ff">{{.Label "bgLightPurple"}} (#f7f0ff)</option>
+                        <option value="#eeeae0">{{.Label "bgBeigeGray"}} (#eeeae0)</option>
+                        <option value="#e8dcc0">{{.Label "bgDarkSepia"}} (#e8dcc0)</option>
+                        <option value="#eee8d5">{{.Label "bgSolarizedSide"}} (#eee8d5)</option>
+                        <option value="#1a1a1a">{{.Label "bgNightSide"}} (#1a1a1a)</option>
+                    </select>
+                    <span id="rightColorPreview" class="color-preview" aria-hidden="true" style="background:#f5f5f5;margin-left:8px"></span>
+                </div>
+            </div>
+        </div>
+        
+        <!-- 夜间模式 -->
+        <div class="control-section" role="group" aria-labelledby="darkModeLabel">
+            <span id="darkModeLabel">{{.Label "darkMode"}}</span>
+            <div class="control-group">
+                <button id="darkModeToggle" onclick="toggleDarkMode()">{{.Label "darkMode"}}</button>
+            </div>
+        </div>
+
+        <!-- 竖排 -->
+        <div class="control-section" role="group" aria-labelledby="verticalLabel">
+            <span id="verticalLabel">{{.Label "direction"}}</span>
+            <div class="control-group">
+                <button id="verticalToggle" onclick="toggleVertical()">{{.Label "vertical"}}</button>
+            </div>
+        </div>
+
+        <!-- 长行换行 -->
+        <div class="control-section" role="group" aria-labelledby="wrapLabel">
+            <span id="wrapLabel">{{.Label "longLines"}}</span>
+            <div class="control-group">
+                <button id="wrapToggle" onclick="toggleWrap()">{{.Label "noWrap"}}</button>
+            </div>
+        </div>
+
+        <!-- 对齐方式 -->
+        <div class="control-section" role="group" aria-labelledby="justifyLabel">
+            <span id="justifyLabel">{{.Label "alignment"}}</span>
+            <div class="control-group">
+                <button id="justifyToggle" onclick="toggleJustify()">{{.Label "justify"}}</button>
+                {{if or .FirstLineIndent .Markdown}}<button id="indentToggle" onclick="toggleIndent()">{{.Label "indent"}}</button>{{end}}
+            </div>
+        </div>
+
+        <!-- 朗读：浏览器支持语音合成时由脚本显示 -->
+        <div class="control-section" id="ttsSection" role="group" aria-labelledby="ttsLabel" hidden>
+            <span id="ttsLabel">{{.Label "readAloud"}}</span>
+            <div class="control-group">
+                <button id="ttsPlay" onclick="toggleSpeech()" aria-pressed="false">{{.Label "readAloud"}}</button>
+                <button id="ttsStop" onclick="stopSpeech()" disabled>{{.Label "stop"}}</button>
+            </div>
+            <div class="control-group">
+                <input type="range" id="ttsRate" min="0.5" max="2" step="0.1" value="1" aria-label="{{.Label "speechRate"}}">
+                <span id="ttsRateDisplay" class="display-value" aria-live="polite">1.0×</span>
+            </div>
+        </div>
+
+        <!-- 页内查找 -->
+        <div class="control-section" role="group" aria-labelledby="searchLabel">
+            <span id="searchLabel">{{.Label "findInPage"}}</span>
+            <div class="control-group">
+                <input type="search" id="searchInput" placeholder="{{.Label "findText"}}" aria-label="{{.Label "findText"}}">
+                <button onclick="searchStep(1)" aria-label="{{.Label "nextMatch"}}">{{.Label "find"}}</button>
+                <button onclick="searchStep(-1)" aria-label="{{.Label "previousMatch"}}">↑</button>
+                <span id="searchCount" class="display-value" role="status">0/0</span>
+                {{if .SearchPage}}<a href="{{.SearchPage}}">{{.Label "searchBook"}}</a>{{end}}
+            </div>
+        </div>
+
+        <!-- 书签 -->
+        <div class="control-section" role="group" aria-labelledby="bookmarkLabel">
+            <span id="bookmarkLabel">{{.Label "bookmarks"}}</span>
+            <div class="control-group">
+                <button id="bookmarkButton" onclick="copyBookmark()">{{.Label "copyBookmark"}}</button>
+                <button onclick="addBookmark()">{{.Label "addBookmark"}}</button>
+            </div>
+            <details class="bookmarks" id="bookmarksPanel">
+                <summary>{{.Label "bookmarkList"}}{{.Label "countOpen"}}<span id="bookmarkCount">0</span>{{.Label "countClose"}}</summary>
+                <ul id="bookmarkList" aria-label="{{.Label "bookmarkList"}}"></ul>
+            </details>
+        </div>
+
+        <!-- 打印 -->
+        <div class="control-section" role="group" aria-labelledby="printLabel">
+            <span id="printLabel">{{.Label "print"}}</span>
+            <div class="control-group">
+                <button onclick="printPage()">{{.Label "printPage"}}</button>
+            </div>
+        </div>
+
+        <!-- 跳转到指定部分 -->
+        <div class="control-section" role="group" aria-labelledby="jumpLabel">
+            <span id="jumpLabel">{{.Label "jump"}}</span>
+            <div class="control-group">
+                <input type="number" id="jumpInput" min="1" max="{{.TotalChunks}}" placeholder="1-{{.TotalChunks}}" aria-label="{{.Label "jumpTo"}}">
+                <button onclick="jumpToChunk()">{{.Label "jump"}}</button>
+                <span id="jumpError" class="jump-error" role="alert"></span>
+            </div>
+        </div>
+
+        <!-- 继续阅读：有上次的阅读位置时由脚本显示 -->
+        <button id="resumeButton" onclick="resumeReading()" hidden>{{.Label "resumeReading"}}</button>
+
+        {{with .Provenance}}
+        <!-- 文件信息：来源和转换记录，默认折叠 -->
+        <details class="file-info">
+            <summary>{{$.Label "fileInfo"}}</summary>
+            <dl>
+                <dt>{{$.Label "sourceFile"}}</dt><dd>{{.SourceFile}}</dd>
+                {{if not .SourceModTime.IsZero}}<dt>{{$.Label "modified"}}</dt><dd>{{.SourceModTime.Format "2006-01-02 15:04:05 -07:00"}}</dd>{{end}}
+                <dt>{{$.Label "generated"}}</dt><dd>{{.GeneratedAt.Format "2006-01-02 15:04:05 -07:00"}}</dd>
+                <dt>{{$.Label "generator"}}</dt><dd>{{.Generator}}</dd>
+            </dl>
+        </details>
+        {{end}}
+
+        <!-- 分页信息 -->
+        <div class="chunk-info">
+            {{if .Single}}{{printf (.Label "partCount") .TotalChunks}}{{else}}{{printf (.Label "partOf") .CurrentChunk .TotalChunks}}{{end}}
+            · {{printf (.Label "chunkStats") .CharCount .WordCount .ReadingMinutes}}
+        </div>
+        {{template "chunkNav" .}}
+    </div>
+    
+    <div class="page-center" role="main">
+        {{if .Header}}<div class="page-header">{{.Header}}</div>{{end}}
+        <div class="content{{if .Markdown}} markdown{{end}}" id="mainContent" tabindex="-1">
+            {{.Content}}
+        </div>
+        {{if .Footer}}<div class="page-footer">{{.Footer}}</div>{{end}}
+        {{template "chunkNav" .}}
+    </div>
+
+    <script>
+        window.txt2htmlPage = {
+            fileName: {{.FileName}},
+            currentFile: {{.OutputFile}},
+            currentChunk: {{.CurrentChunk}},
+            columns: {{.Columns}},
+            chapter: {{.Chapter}},
+            totalChunks: {{.TotalChunks}},
+            filePattern: {{.FilePattern}},
+            prevFile: {{.PrevFile}},
+            nextFile: {{.NextFile}},
+            single: {{.Single}},
+            justify: {{.Justify}},
+            firstLineIndent: {{.FirstLineIndent}},
+            theme: {{.Theme}},
+            labels: {{.ScriptLabels}}
+        };
+    </script>
+    {{if .SharedAssets}}<script src="` + ReaderScriptFile + `"></script>{{else}}<script>` + readerScript + `    </script>{{end}}
+</body>
+</html>
+{{define "chunkNav"}}{{if not .Single}}
+<nav class="chunk-nav" aria-label="{{.Label "pageNav"}}">
+    {{if .PrevFile}}<a class="nav-button" href="{{.PrevFile}}" rel="prev">{{.Label "prevPage"}}</a>{{else}}<span class="nav-button disabled" aria-disabled="true">{{.Label "prevPage"}}</span>{{end}}
+    {{if .NextFile}}<a class="nav-button" href="{{.NextFile}}" rel="next">{{.Label "nextPage"}}</a>{{else}}<span class="nav-button disabled" aria-disabled="true">{{.Label "nextPage"}}</span>{{end}}
+</nav>
+{{end}}{{end}}`
+
+// 精简页面模板 - 只保留正文和基本样式，不含阅读设置面板和脚本，便于后续处理HTML。
+// 上一页/下一页只以 <link> 形式写在 head 中
+const minimalTemplate = `<!DOCTYPE html>
+<html lang="{{.Label "lang"}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}} - {{printf (.Label "partTitle") .CurrentChunk}}</title>
+    {{if .Favicon}}<link rel="icon" href="{{.Favicon}}">{{end}}
+    {{if .PrevFile}}<link rel="prev" href="{{.PrevFile}}">{{end}}
+    {{if .NextFile}}<link rel="next" href="{{.NextFile}}">{{end}}
+    {{if .IndexPage}}<link rel="index" href="{{.IndexPage}}">{{end}}
+    <style>
+        body {
+            max-width: {{.CenterMaxWidth}}px;
+            margin: 0 auto;
+            padding: 20px;
+            color: #333;
+            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+            font-size: 16px;
+        }
+        .content {
+            white-space: pre-wrap;
+            word-wrap: break-word;
+            line-height: 1.6;
+            column-count: {{.Columns}};
+            column-gap: 40px;
+            tab-size: {{.TabWidth}};
+            {{if .Justify}}text-align: justify;
+            hyphens: auto;{{end}}
+        }
+        .indent {
+            display: inline-block;
+            width: 2em;
+        }
+        .paragraph-gap {
+            display: block;
+            height: 0.5em;
+        }
+        .content > .paragraph-gap:first-child {
+            display: none;
+        }
+        {{if .FirstLineIndent}}.content.markdown p {
+            text-indent: 2em;
+        }{{end}}
+        .content.markdown {
+            white-space: normal;
+        }
+        .content.markdown pre {
+            white-space: pre;
+            overflow-x: auto;
+        }
+        .chapter-heading {
+            font-weight: bold;
+        }
+        .overlap {
+            opacity: 0.45;
+            border-bottom: 1px dashed #999;
+        }
+        .line-number::before {
+            content: attr(data-line);
+            display: inline-block;
+            min-width: 3em;
+            margin-right: 1em;
+            text-align: right;
+            color: #999;
+        }
+        .page-header, .page-footer {
+            color: #666;
+            font-size: 0.9em;
+            text-align: center;
+            margin: 10px 0;
+        }
+        @media print {
+            body {
+                max-width: none;
+                padding: 0;
+                color: #000;
+            }
+            .overlap {
+                display: none;
+            }
+        }
+    </style>
+</head>
+<body>
+    {{if .Header}}<div class="page-header">{{.Header}}</div>{{end}}
+    <div class="content{{if .Markdown}} markdown{{end}}">{{.Content}}</div>
+    {{if .Footer}}<div class="page-footer">{{.Footer}}</div>{{end}}
+</body>
+</html>`
+
+// 用于 EPUB 的分块正文，必须是合法的 XHTML：纯文本经 HTMLEscapeString 转义，Markdown 以 XHTML 方式渲染
+const xhtmlTemplate = `<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xml:lang="{{.Label "lang"}}" lang="{{.Label "lang"}}">
+<head>
+    <meta charset="UTF-8"/>
+    <title>{{.Title}} - {{printf (.Label "part") .CurrentChunk}}</title>
+    <link rel="stylesheet" type="text/css" href="` + XHTMLStyleFile + `"/>
+</head>
+<body>
+    {{if .Header}}<div class="page-header">{{.Header}}</div>{{end}}
+    <div class="content{{if .Markdown}} markdown{{end}}">{{.Content}}</div>
+    {{if .Footer}}<div class="page-footer">{{.Footer}}</div>{{end}}
+</body>
+</html>
+`
+
+// XML 声明。html/template 会把模板中的 "<?" 转义，所以单独写在模板输出之前
+const XMLDeclaration = `<?xml version="1.0" encoding="UTF-8"?>` + "\n"
+
+const XHTMLStyleFile = "style.css" // LayoutXHTML 页面引用的样式表，与分块文件放在同一目录
+
+// 完整页面设置面板中的阅读主题预设，第一个为默认
+var ReaderThemes = []string{"paperwhite", "sepia", "solarized", "night"}
+
+const (
+	ReaderStyleFile  = "style.css" // SharedAssets 时完整页面引用的样式表，与分块文件放在同一目录
+	ReaderScriptFile = "reader.js" // SharedAssets 时完整页面引用的阅读脚本
+)
+
+// 在 dir 中写入 SharedAssets 页面引用的 ReaderStyleFile 和 ReaderScriptFile，minify 时与 Converter.Minify 一样压缩
+func WriteReaderAssets(dir string, minify bool) error {
+	style, script := readerStyle, readerScript
+	if minify {
+		style, script = minifyText(style), minifyText(script)
+	}
+	if err := os.WriteFile(filepath.Join(dir, ReaderStyleFile), []byte(style), 0644); err != nil {
+		return err
+	}
+	return os.WriteFile(filepath.Join(dir, ReaderScriptFile), []byte(script), 0644)
+}
+
+// 分块页面的布局
+type Layout int
+
+const (
+	LayoutFull    Layout = iota // 带阅读设置面板和脚本的完整页面
+	LayoutMinimal               // 只含正文和基本样式的精简页面
+	LayoutXHTML                 // EPUB 使用的 XHTML 正文，排版交给 XHTMLStyleFile
+)
+
+// 启动时解析一次的分块页面模板，下标为 Layout，计算基础大小与渲染共用（template.Template 可并发执行）
+var layoutTemplates = parseLayouts(func(src string) string { return src })
+
+// Converter.Minify 时使用的模板，解析前先由 minifyText 压缩，计算基础大小时同样使用，分块大小按压缩后计算
+var minifiedTemplates = parseLayouts(minifyText)
+
+func parseLayouts(prepare func(string) string) []*template.Template {
+	return []*template.Template{
+		LayoutFull:    template.Must(template.New("htmlTemplate").Parse(prepare(htmlTemplate))),
+		LayoutMinimal: template.Must(template.New("minimalTemplate").Parse(prepare(minimalTemplate))),
+		LayoutXHTML:   template.Must(template.New("xhtmlTemplate").Parse(prepare(xhtmlTemplate))),
+	}
+}
+
+func (l Layout) valid() bool {
+	return l >= 0 && int(l) < len(layoutTemplates)
+}
+
+// 按布局渲染一块页面，XHTML 页面先写出 XML 声明；minify 时使用压缩后的模板
+func (l Layout) execute(w io.Writer, data TemplateData, minify bool) error {
+	if l == LayoutXHTML {
+		if _, err := io.WriteString(w, XMLDeclaration); err != nil {
+			return err
+		}
+	}
+	if minify {
+		return minifiedTemplates[l].Execute(w, data)
+	}
+	return layoutTemplates[l].Execute(w, data)
+}
+
+// 渲染分块页面使用的模板：custom 不为 nil 时使用自定义模板，否则使用 layout 对应的内置模板，
+// minify 时为压缩后的内置模板（自定义模板保持原样）
+type pageTemplate struct {
+	layout Layout
+	custom *template.Template
+	minify bool
+}
+
+func (p pageTemplate) execute(w io.Writer, data TemplateData) error {
+	if p.custom != nil {
+		return p.custom.Execute(w, data)
+	}
+	return p.layout.execute(w, data, p.minify)
+}
+
+// 校验自定义模板时放入 Content 的标记，渲染结果中必须出现
+const templateContentMarker = "txt2html-content-marker"
+
+// 读取磁盘上的分块页面模板，可使用 TemplateData 的全部字段，用作 Converter.Template。
+// 模板必须能解析和渲染，且输出中包含 {{.Content}}，否则正文会丢失
+func ParseTemplateFile(path string) (*template.Template, error) {
+	tmpl, err := template.ParseFiles(path)
+	if err != nil {
+		return nil, fmt.Errorf("无法解析模板: %w", err)
+	}
+	var buf bytes.Buffer
+	sample := TemplateData{Content: templateContentMarker, TotalChunks: 1, CurrentChunk: 1, Columns: 1}
+	if err := tmpl.Execute(&buf, sample); err != nil {
+		return nil, fmt.Errorf("无法渲染模板 %s: %w", path, err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte(templateContentMarker)) {
+		return nil, fmt.Errorf("模板 %s 中没有 {{.Content}}，页面将不含正文", path)
+	}
+	return tmpl, nil
+}
+
+// 计算分块页面的基础大小（不含内容）
+// page 提供整本书共用的字段（文件名、页面宽度等），本函数补上与块序号相关的字段。
+// 切分时总块数和本块的字数统计尚未确定，按最大位数估算；导航链接按上一页/下一页都存在计算，宁可略微高估
+func getBaseHTMLSize(tmpl pageTemplate, page TemplateData, names NamePattern, currentChunk int) int {
+	data := page
+	data.Content = ""
+	data.TotalChunks = math.MaxInt32
+	data.CharCount = math.MaxInt32
+	data.WordCount = math.MaxInt32
+	data.ReadingMinutes = math.MaxInt32
+	data.CurrentChunk = currentChunk
+	data.NextFile = names.format(page.FileName, currentChunk+1, math.MaxInt32)
+	data.FilePattern = names.chunkPattern(page.FileName, math.MaxInt32)
+	if currentChunk > 1 {
+		data.PrevFile = names.format(page.FileName, currentChunk-1, math.MaxInt32)
+	}
+	var counter byteCounter
+	tmpl.execute(&counter, data)
+	return int(counter)
+}
+
+// 只统计写入字节数的 io.Writer，用于计算渲染结果大小而不保留内容
+type byteCounter int64
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	*c += byteCounter(len(p))
+	return len(p), nil
+}
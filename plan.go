@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"txt2html/pkg/txt2html"
+)
+
+// 试运行时报告切分结果：每块的文件名、渲染后的大小、字数和开头所在的章节，以及检测到的章节标题。
+// 各块渲染到 io.Discard 只为统计大小，不写入任何文件。targetSize 为0时（按行数分块）不显示目标大小
+func printPlan(w io.Writer, book *txt2html.Book, targetSize int) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "块\t文件\t大小 (KB)\t字数\t开头章节")
+	var total int64
+	for i, data := range book.Chunks {
+		counter := &countingWriter{w: io.Discard}
+		if err := book.Render(data.CurrentChunk, counter); err != nil {
+			return fmt.Errorf("渲染第 %d 块失败: %w", data.CurrentChunk, err)
+		}
+		total += counter.n
+		stats := book.Stats[i]
+		fmt.Fprintf(tw, "%d\t%s\t%.2f\t%d\t%s\n", data.CurrentChunk, data.OutputFile, float64(counter.n)/1024, stats.WordCount, stats.Chapter)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "共 %d 块，约 %.2f KB", len(book.Chunks), float64(total)/1024)
+	if targetSize > 0 {
+		fmt.Fprintf(w, "，目标大小 %.0f KB", float64(targetSize)/1024)
+	}
+	fmt.Fprintln(w)
+	if len(book.Headings) == 0 {
+		fmt.Fprintln(w, "未检测到章节标题")
+		return nil
+	}
+	fmt.Fprintf(w, "检测到 %d 个章节标题:\n", len(book.Headings))
+	for _, h := range book.Headings {
+		fmt.Fprintf(w, "%s%s（第 %d 块）\n", strings.Repeat("  ", h.Level-1), h.Text, h.Chunk)
+	}
+	return nil
+}
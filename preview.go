@@ -0,0 +1,60 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"txt2html/pkg/txt2html"
+)
+
+// 把只切分出的第一块写入预览页面 path，keep 时拒绝覆盖已存在的文件；open 时生成后用默认浏览器打开
+func convertPreview(path string, book *txt2html.Book, keep, open bool) error {
+	if keep {
+		if err := checkOverwrite(filepath.Dir(path), []string{filepath.Base(path)}); err != nil {
+			return err
+		}
+	}
+	size, err := generateHTML(book, path, book.Chunks[0].CurrentChunk, nil)
+	if err != nil {
+		return fmt.Errorf("生成 %s 失败: %w", path, err)
+	}
+	if book.Truncated {
+		slog.Info(fmt.Sprintf("预览完成! 已生成第一块（约 %.2f KB，读取到第 %d 行），保存到 %s", float64(size)/1024, book.Lines, path))
+	} else {
+		slog.Info(fmt.Sprintf("预览完成! 全部内容只有一块（约 %.2f KB），保存到 %s", float64(size)/1024, path))
+	}
+	if open {
+		// 页面已经生成，打不开浏览器只提示，不算转换失败
+		if err := openInBrowser(path); err != nil {
+			slog.Warn(fmt.Sprintf("无法打开浏览器: %v", err))
+		}
+	}
+	return nil
+}
+
+// 用系统默认的浏览器打开本地文件 path，不等待浏览器退出
+func openInBrowser(path string) error {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return err
+	}
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("open", abs)
+	case "windows":
+		cmd = exec.Command("rundll32", "url.dll,FileProtocolHandler", abs)
+	default:
+		cmd = exec.Command("xdg-open", abs)
+	}
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	// xdg-open 等命令把页面交给浏览器后就退出，程序随即结束，不必等待
+	return cmd.Process.Release()
+}
@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"time"
+)
+
+const progressInterval = 200 * time.Millisecond // 进度刷新的最小间隔
+
+// 统计已读取字节数并在单行内刷新进度的 io.Reader。
+// 统计的是解码前的原始字节，因此能直接与文件大小比较；total 为 0 时（如标准输入）只显示已读取量
+type progressReader struct {
+	r        io.Reader
+	out      io.Writer
+	total    int64
+	read     int64
+	lastShow time.Time
+}
+
+func newProgressReader(r io.Reader, out io.Writer, total int64) *progressReader {
+	return &progressReader{r: r, out: out, total: total}
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	p.read += int64(n)
+	if now := time.Now(); now.Sub(p.lastShow) >= progressInterval {
+		p.lastShow = now
+		p.show()
+	}
+	return n, err
+}
+
+// 用回车覆盖同一行显示当前进度
+func (p *progressReader) show() {
+	if p.total > 0 {
+		fmt.Fprintf(p.out, "\r处理进度: %5.1f%% (%.2f / %.2f MB)", float64(p.read)*100/float64(p.total),
+			float64(p.read)/1024/1024, float64(p.total)/1024/1024)
+	} else {
+		fmt.Fprintf(p.out, "\r已读取: %.2f MB", float64(p.read)/1024/1024)
+	}
+}
+
+// 读取结束后显示最终进度并换行，之后的输出不会接在进度行后面
+func (p *progressReader) finish() {
+	p.show()
+	fmt.Fprintln(p.out)
+}
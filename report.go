@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"os"
+	"time"
+)
+
+// -report 写出的转换结果，供脚本检查是否成功。转换失败时同样写出，status 为 failed
+type report struct {
+	Status         string    `json:"status"` // ok、failed，批量转换中跳过的文件为 skipped
+	Error          string    `json:"error,omitempty"`
+	Input          string    `json:"input"`  // 输入文件或目录，标准输入为 -
+	Output         string    `json:"output"` // 输出目录或文件，-zip-only 时为 zip 文件
+	Encoding       string    `json:"encoding,omitempty"`
+	InputBytes     int64     `json:"inputBytes"`  // 读取的输入字节数，gzip 压缩的输入按压缩后计
+	OutputBytes    int64     `json:"outputBytes"` // 分块HTML的总字节数；EPUB、-single、-preview 时为输出文件的大小
+	Chunks         int       `json:"chunks"`
+	DecodeErrors   int       `json:"decodeErrors"`
+	ControlChars   int       `json:"controlChars"` // 控制字符数，-sanitize 时为去掉的数量
+	Chapters       int       `json:"chapters"`     // 检测到的卷、章、节标题数
+	DryRun         bool      `json:"dryRun,omitempty"`
+	ElapsedSeconds float64   `json:"elapsedSeconds"`
+	Files          []*report `json:"files,omitempty"` // 批量转换时每个文件的结果，上面的数量为各文件之和
+}
+
+// 记下转换结束时的状态和用时
+func (r *report) finish(err error, start time.Time) {
+	r.Status = "ok"
+	if err != nil {
+		r.Status = "failed"
+		r.Error = err.Error()
+	}
+	r.ElapsedSeconds = time.Since(start).Seconds()
+}
+
+// 把批量转换中一个文件的结果计入总数
+func (r *report) add(file *report) {
+	r.Files = append(r.Files, file)
+	r.InputBytes += file.InputBytes
+	r.OutputBytes += file.OutputBytes
+	r.Chunks += file.Chunks
+	r.DecodeErrors += file.DecodeErrors
+	r.ControlChars += file.ControlChars
+	r.Chapters += file.Chapters
+}
+
+func writeReport(path string, r *report) error {
+	content, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(path, append(content, '\n'), 0644)
+}
+
+// 统计读取字节数的 io.Reader
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += int64(n)
+	return n, err
+}
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// -report 在转换成功和失败时都写出结果
+func TestConvertReport(t *testing.T) {
+	dir := chdirTemp(t)
+	read := func(path string) report {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var r report
+		if err := json.Unmarshal(content, &r); err != nil {
+			t.Fatalf("解析 %s: %v", path, err)
+		}
+		return r
+	}
+
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("第一章 开始\n内容\n第二章 继续\n内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-quiet", "-report", "ok.json", "-out", "out", input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	r := read("ok.json")
+	if r.Status != "ok" || r.Chunks != 2 || r.Chapters != 2 || r.Encoding != "utf-8" || r.InputBytes == 0 || r.OutputBytes == 0 {
+		t.Errorf("成功时的结果 = %+v", r)
+	}
+
+	binary := filepath.Join(dir, "binary.txt")
+	if err := os.WriteFile(binary, []byte{0, 1, 2, 3}, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-quiet", "-report", "failed.json", "-out", "out2", binary}); err == nil {
+		t.Fatal("转换二进制文件成功，期望失败")
+	}
+	if r := read("failed.json"); r.Status != "failed" || r.Error == "" {
+		t.Errorf("失败时的结果 = %+v", r)
+	}
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+const batchStateFileName = ".txt2html-batch.json" // 批量转换记录已完成文件的进度文件名，位于输出目录中
+
+// 批量转换的进度：每转换完一个文件就保存一次，中断后以 -resume 重新运行时跳过已完成的文件
+type batchState struct {
+	Files map[string]batchFile `json:"files"` // 键为输入文件相对于输入目录的路径
+}
+
+// 已转换完成的一个输入文件，大小或修改时间变化后需要重新转换
+type batchFile struct {
+	Size    int64     `json:"size"`
+	ModTime time.Time `json:"modTime"`
+	Output  string    `json:"output"` // 输出的目录或文件
+}
+
+// 读取输出目录中的批量转换进度，没有进度时返回空的进度
+func loadBatchState(outputDir string) (*batchState, error) {
+	state := &batchState{Files: map[string]batchFile{}}
+	path := filepath.Join(outputDir, batchStateFileName)
+	content, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return state, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(content, state); err != nil {
+		return nil, fmt.Errorf("无法解析 %s: %w（删除该文件后重新转换）", path, err)
+	}
+	if state.Files == nil {
+		state.Files = map[string]batchFile{}
+	}
+	return state, nil
+}
+
+// 输入文件 rel 是否已按当前的内容转换完成，且输出仍然存在
+func (s *batchState) done(rel string, info os.FileInfo) bool {
+	f, ok := s.Files[rel]
+	if !ok || f.Size != info.Size() || !f.ModTime.Equal(info.ModTime()) {
+		return false
+	}
+	_, err := os.Stat(f.Output)
+	return err == nil
+}
+
+// 记下 rel 已转换完成并保存进度。先写入临时文件再改名替换，
+// 中途被终止时留下的要么是旧的进度，要么是新的进度，不会是写了一半的文件
+func (s *batchState) complete(outputDir, rel string, info os.FileInfo, output string) error {
+	s.Files[rel] = batchFile{Size: info.Size(), ModTime: info.ModTime(), Output: output}
+	content, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(outputDir, batchStateFileName+".*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	// 确保内容落盘后再替换，否则断电时改名可能先于数据写入生效
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), filepath.Join(outputDir, batchStateFileName)); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return nil
+}
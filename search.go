@@ -0,0 +1,267 @@
+package main
+
+import (
+	"html/template"
+	"os"
+	"path/filepath"
+
+	"txt2html/pkg/txt2html"
+)
+
+const (
+	searchIndexFileName = "search-index.js" // 全书搜索索引文件名
+	searchPageFileName  = "search.html"     // 全书搜索页面文件名
+	searchMaxResults    = 200               // 搜索页面最多显示的结果条数
+)
+
+// 搜索页面模板数据结构
+type searchPageData struct {
+	Title           string
+	CenterMaxWidth  int
+	MaxResults      int
+	UILang          string       // 界面文字的语言，与分块页面一致
+	SolidBackground bool         // 纯色背景，与分块页面一致
+	Favicon         template.URL // 页面图标，与分块页面一致
+}
+
+// 搜索页面脚本用到的界面文字
+var searchScriptLabels = []string{"searchIndexError", "searchResult", "noMatches", "matches", "matchesTruncated"}
+
+// 搜索页面模板中使用的界面文字
+func (d searchPageData) Label(key string) string {
+	return txt2html.UILabel(d.UILang, key)
+}
+
+// 搜索页面脚本使用的界面文字
+func (d searchPageData) ScriptLabels() map[string]string {
+	return txt2html.UILabels(d.UILang, searchScriptLabels...)
+}
+
+// 全书搜索页面模板 - 与目录页使用相同的配色变量和居中布局
+const searchTemplate = `<!DOCTYPE html>
+<html lang="{{.Label "lang"}}">
+<head>
+    <meta charset="UTF-8">
+    <meta name="viewport" content="width=device-width, initial-scale=1.0">
+    <title>{{.Title}} - {{.Label "searchBook"}}</title>
+    {{if .Favicon}}<link rel="icon" href="{{.Favicon}}">{{end}}
+    <style>
+        :root {
+            --left-bg: #f5f5f5;   /* 左侧默认背景 */
+            --center-bg: #ffffff; /* 中央内容背景 */
+            --right-bg: #f5f5f5;  /* 右侧默认背景 */
+            --center-max-width: {{.CenterMaxWidth}}px;
+        }
+        body {
+            --g-left: calc(50% - var(--center-max-width) / 2);
+            --g-right: calc(50% + var(--center-max-width) / 2);
+            {{if .SolidBackground}}background: var(--left-bg);{{else}}background: linear-gradient(to right,
+                        var(--left-bg) 0px var(--g-left),
+                        var(--center-bg) var(--g-left) var(--g-right),
+                        var(--right-bg) var(--g-right) 100%);{{end}}
+            color: #333;
+            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
+            padding: 20px;
+            margin: 0;
+            font-size: 16px;
+        }
+        .page-center {
+            max-width: var(--center-max-width);
+            margin: 0 auto;
+            padding: 20px;
+        }
+        .content {
+            padding: 25px;
+            border-radius: 8px;
+            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
+            line-height: 1.6;
+            background-color: var(--center-bg);
+        }
+        .search-box {
+            display: flex;
+            gap: 8px;
+            margin-bottom: 15px;
+        }
+        #searchInput {
+            flex: 1;
+            padding: 6px 10px;
+            border: 1px solid #ccc;
+            border-radius: 4px;
+            font-size: 16px;
+        }
+        button {
+            padding: 6px 15px;
+            border: none;
+            border-radius: 4px;
+            background: #0066cc;
+            color: white;
+            cursor: pointer;
+        }
+        .result-list {
+            list-style: none;
+            margin: 0;
+            padding: 0;
+        }
+        .result-list li {
+            padding: 8px 0;
+            border-bottom: 1px solid #eee;
+        }
+        .result-list a {
+            color: #0066cc;
+            text-decoration: none;
+        }
+        .result-list a:hover {
+            text-decoration: underline;
+        }
+        .result-text {
+            display: block;
+            margin-top: 4px;
+            color: #444;
+            word-break: break-all;
+        }
+        .search-status {
+            color: #666;
+            font-size: 0.9em;
+        }
+        mark {
+            background: #ffeb3b;
+            color: inherit;
+        }
+    </style>
+</head>
+<body>
+    <div class="page-center">
+        <div class="content">
+            <h1>{{.Title}} - {{.Label "searchBook"}}</h1>
+            <p><a href="index.html">{{.Label "backToIndex"}}</a></p>
+            <div class="search-box">
+                <input type="search" id="searchInput" placeholder="{{.Label "searchPlaceholder"}}" aria-label="{{.Label "findText"}}" autofocus>
+                <button onclick="runSearch()">{{.Label "search"}}</button>
+            </div>
+            <p id="searchStatus" class="search-status"></p>
+            <ul id="resultList" class="result-list"></ul>
+        </div>
+    </div>
+
+    <script src="search-index.js"></script>
+    <script>
+        (function() {
+            const maxResults = {{.MaxResults}};
+            const labels = {{.ScriptLabels}};
+            const input = document.getElementById('searchInput');
+            const status = document.getElementById('searchStatus');
+            const list = document.getElementById('resultList');
+            const index = window.txt2htmlSearch;
+
+            // 界面文字，格式中的 %d、%s 依次换成 args
+            function label(key, ...args) {
+                let i = 0;
+                return (labels[key] || '').replace(/%[ds]/g, function() { return args[i++]; });
+            }
+
+            // 在文本中把所有匹配处包上 <mark>，用 DOM 节点拼接，避免把正文当作HTML解析
+            function appendHighlighted(parent, text, lowerQuery) {
+                const lowerText = text.toLowerCase();
+                // 大小写转换后长度改变时无法按下标对应原文，只显示不高亮
+                if (lowerText.length !== text.length) {
+                    parent.appendChild(document.createTextNode(text));
+                    return;
+                }
+                let pos = 0;
+                let found;
+                while ((found = lowerText.indexOf(lowerQuery, pos)) !== -1) {
+                    parent.appendChild(document.createTextNode(text.slice(pos, found)));
+                    const mark = document.createElement('mark');
+                    mark.textContent = text.slice(found, found + lowerQuery.length);
+                    parent.appendChild(mark);
+                    pos = found + lowerQuery.length;
+                }
+                parent.appendChild(document.createTextNode(text.slice(pos)));
+            }
+
+            window.runSearch = function() {
+                list.textContent = '';
+                const query = input.value.trim();
+                if (!index) {
+                    status.textContent = label('searchIndexError', 'search-index.js');
+                    return;
+                }
+                if (!query) {
+                    status.textContent = '';
+                    return;
+                }
+                const lowerQuery = query.toLowerCase();
+                let total = 0;
+                index.lines.forEach(function(entry) {
+                    if (entry[2].toLowerCase().indexOf(lowerQuery) === -1) return;
+                    total++;
+                    if (total > maxResults) return;
+                    const li = document.createElement('li');
+                    const link = document.createElement('a');
+                    // 跳转后由分块页面用页内查找定位并高亮匹配处
+                    link.href = index.files[entry[0] - 1] + '#search=' + encodeURIComponent(query);
+                    link.textContent = label('searchResult', entry[0], entry[1]);
+                    const text = document.createElement('span');
+                    text.className = 'result-text';
+                    appendHighlighted(text, entry[2], lowerQuery);
+                    li.appendChild(link);
+                    li.appendChild(text);
+                    list.appendChild(li);
+                });
+                if (total === 0) {
+                    status.textContent = label('noMatches');
+                } else if (total > maxResults) {
+                    status.textContent = label('matchesTruncated', total, maxResults);
+                } else {
+                    status.textContent = label('matches', total);
+                }
+            };
+
+            input.addEventListener('keydown', function(e) {
+                if (e.key === 'Enter') {
+                    e.preventDefault();
+                    window.runSearch();
+                }
+            });
+        })();
+    </script>
+</body>
+</html>`
+
+// 启动时解析一次的搜索页面模板
+var searchTmpl = template.Must(template.New("searchTemplate").Parse(searchTemplate))
+
+// 在输出目录中生成 search-index.js
+func generateSearchIndex(outputDir string, book *txt2html.Book) error {
+	outputFile, err := os.Create(filepath.Join(outputDir, searchIndexFileName))
+	if err != nil {
+		return err
+	}
+	if err := book.WriteSearchIndex(outputFile); err != nil {
+		outputFile.Close()
+		return err
+	}
+	return outputFile.Close()
+}
+
+// 在输出目录中生成 search.html，页面加载 search-index.js 在整本书中查找
+// 书名、页面宽度、界面语言和背景与 page 一致
+func generateSearchPage(outputDir string, page txt2html.TemplateData) error {
+	outputFile, err := os.Create(filepath.Join(outputDir, searchPageFileName))
+	if err != nil {
+		return err
+	}
+	data := searchPageData{
+		Title:           page.Title,
+		CenterMaxWidth:  page.CenterMaxWidth,
+		MaxResults:      searchMaxResults,
+		UILang:          page.UILang,
+		SolidBackground: page.SolidBackground,
+		Favicon:         page.Favicon,
+	}
+	if err := searchTmpl.Execute(outputFile, data); err != nil {
+		outputFile.Close()
+		return err
+	}
+	return outputFile.Close()
+}
@@ -3,482 +3,1357 @@ package main
 import (
 	"bufio"
 	"bytes"
+	"compress/gzip"
+	"context"
+	"errors"
+	"flag"
 	"fmt"
 	"html/template"
 	"io"
+	"log/slog"
 	"os"
+	"os/signal"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"slices"
+	"strings"
+	"sync"
+	"text/tabwriter"
+	"time"
 
-	"golang.org/x/text/encoding"
-	"golang.org/x/text/encoding/simplifiedchinese"
-	"golang.org/x/text/encoding/unicode"
-	"golang.org/x/text/transform"
+	"txt2html/pkg/txt2html"
 )
 
-const targetHTMLSize = 1024 * 1024 // 目标HTML文件大小：1MB
-const readBufferSize = 4096         // 读取缓冲区大小
+const defaultMaxLineMB = txt2html.DefaultMaxLineSize / 1024 / 1024 // 默认允许的单行最大长度（MB）
+const defaultAnchorLines = 10                                      // 默认每10行插入一个行号锚点
 
-// HTML模板数据结构
-type TemplateData struct {
-	Content      string
-	FileName     string
-	TotalChunks  int
-	CurrentChunk int
+const defaultTOCDepth = 2 // 默认检测到卷、章两级
+
+// 版本号，发布时通过 go build -ldflags "-X main.version=v1.2.3" 注入
+var version = "dev"
+
+// 输出格式
+const (
+	formatHTML = "html"
+	formatEPUB = "epub"
+)
+
+// 纯文本段落的划分方式
+const (
+	paragraphsBlank = "blank" // 空行分隔段落
+	paragraphsLine  = "line"  // 每行一段
+)
+
+// 命令行选项
+type options struct {
+	inputPath     string
+	fileName      string                 // 输入文件名，用于生成分块文件名
+	title         string                 // 页面中显示的书名，为空时使用文件名
+	stdin         bool                   // 从标准输入读取内容
+	encodingName  string                 // 输入编码，为空时单个文件按 utf-8、目录按 auto 处理
+	outputDir     string                 // 输出目录（EPUB 格式时为输出文件），为空时根据输入文件名生成
+	noClean       bool                   // 不删除输出目录中已有的内容
+	force         bool                   // 配合 noClean 使用，允许覆盖已存在的分块文件
+	headingRules  []txt2html.HeadingRule // 卷、章、节标题匹配规则，为空时不检测章节
+	maxLineSize   int                    // 单行最大字节数，超过时读取失败
+	zip           bool                   // 生成完成后将输出目录打包为 zip
+	zipOnly       bool                   // 打包后删除输出目录，只保留 zip
+	gzip          bool                   // 为每个输出文件生成 gzip 预压缩副本
+	checksum      bool                   // 在输出目录中生成所有输出文件的 SHA-256 清单
+	verify        bool                   // 写出后检查各分块的实际大小与目标大小的偏差
+	jobs          int                    // 并行生成HTML的 goroutine 数量
+	maxChunks     int                    // 最多生成的分块数，0 表示不限
+	targetSize    int                    // 每块HTML的目标大小（字节）
+	linesPerChunk int                    // 每块的行数，0 表示按大小分块
+	overlap       int                    // 每块开头重复上一块的行数
+	splitMarker   string                 // 与之完全相同的行开始新的一块，该行不输出
+	revealLines   int                    // 每块先显示的行数，其余部分滚动接近时逐段显示，0 表示整块一次显示
+	width         int                    // 中央内容区最大宽度（px）
+	columns       int                    // 正文分栏数
+	tabWidth      int                    // 制表符宽度
+	expandTabs    bool                   // 把制表符展开为空格
+	justify       bool                   // 正文默认两端对齐
+	indent        bool                   // 正文默认每段首行缩进两个字
+	paragraphs    string                 // 纯文本段落的划分方式：paragraphsBlank 或 paragraphsLine
+	theme         string                 // 页面默认的阅读主题，为空时为 paperwhite
+	uiLang        string                 // 页面界面文字的语言
+	noGradient    bool                   // 页面背景为纯色，两侧不使用渐变
+	favicon       template.URL           // 页面图标的 data URI，为空时使用默认图标
+	configPath    string                 // 使用的配置文件，没有时为空
+	reportPath    string                 // 转换结束后写出 JSON 结果的文件，为空时不写
+	report        *report                // 本次转换的结果，未指定 -report 时为 nil
+	header        template.HTML          // 每页正文上方的页眉
+	footer        template.HTML          // 每页正文下方的页脚
+	markdown      bool                   // 按 Markdown 渲染正文
+	quiet         bool                   // 只输出错误
+	verbose       bool                   // 输出调试信息：分块位置、检测到的标题、解码错误等
+	noSearch      bool                   // 不生成全书搜索索引和搜索页面
+	names         txt2html.NamePattern   // 分块文件名模板
+	rawNames      bool                   // 文件名和默认输出位置保留输入文件名原样，不替换空白等字符
+	recursive     bool                   // 输入为目录时递归处理子目录
+	resumeBatch   bool                   // 批量转换时跳过上次已完成的文件
+	format        string                 // 输出格式：html 或 epub
+	minimal       bool                   // 使用不含阅读设置面板和脚本的精简页面
+	template      *template.Template     // -template 指定的自定义分块页面模板
+	single        bool                   // 全书合并为一个HTML文件，每块是一个可折叠的部分
+	preview       bool                   // 只转换第一块，写满即停止读取
+	dryRun        bool                   // 只切分并报告结果，不写入、不删除任何文件
+	watch         bool                   // 转换后监视输入文件，修改后自动重新转换
+	balance       bool                   // 各块大小尽量相同，不留下很小的最后一块
+	open          bool                   // 预览页面生成后用默认浏览器打开
+	copyAssets    bool                   // 样式和脚本写入共用的文件，不在每页内联
+	minify        bool                   // 压缩页面模板、样式和脚本中的空白和注释
+	decodeMarker  string                 // 无法解码的字节显示为的标记，为空时保留 U+FFFD
+	sanitize      bool                   // 去掉正文中的控制字符
+	fileInfo      bool                   // 在设置面板中显示原文件名、修改时间、转换时间和程序版本
+	anchorLines   int                    // 每隔多少行插入一个行号锚点，0 表示不插入
+	appendMode    bool                   // 只转换上次运行之后新增的内容，接着已有的块编号
+	lineNumbers   bool                   // 在每行前显示原文件中的行号
+	maxBlank      int                    // 连续空行最多保留的行数，0 表示全部保留
+	wrapAt        int                    // 把超过该字符数的行折成多行，0 表示不折行
+}
+
+// 解析命令行参数，返回 nil 表示只需显示帮助或版本信息
+func parseOptions(args []string) (*options, error) {
+	opts := &options{}
+
+	fs := flag.NewFlagSet("txt2html", flag.ContinueOnError)
+	showVersion := fs.Bool("version", false, "显示版本信息后退出")
+	configFile := fs.String("config", "", "配置文件（默认: 输入文件所在目录中的 "+configFileName+"，目录输入时为该目录中的，存在时自动使用）")
+	listEncodings := fs.Bool("list-encodings", false, "列出支持的输入编码及别名后退出")
+	fs.BoolVar(&opts.stdin, "stdin", false, "从标准输入读取内容，此时不需要 <文件名> 参数")
+	fs.StringVar(&opts.title, "title", "", "页面标题、目录页和 EPUB 元数据中显示的书名（默认: 文件名）；不影响分块文件名，不能用于目录输入")
+	fs.StringVar(&opts.fileName, "name", "", "配合 -stdin 使用，指定显示的文件名及输出目录名（默认: stdin）")
+	fs.StringVar(&opts.outputDir, "out", "", "输出目录（默认: <文件名>_<哈希>_html_chunks，哈希取自输入文件的绝对路径，不同目录中的同名文件不会互相覆盖；标准输入时为 <名称>_html_chunks）。已存在且不是为同一输入生成的目录不会被删除，除非指定 -force；-format epub 时为输出的 EPUB 文件（默认: <文件名>.epub）")
+	fs.StringVar(&opts.format, "format", formatHTML, "输出格式：html（分块网页）或 epub（EPUB3 电子书，不使用 -name-pattern）")
+	fs.BoolVar(&opts.noClean, "no-clean", false, "不删除输出目录中已有的内容")
+	fs.BoolVar(&opts.appendMode, "append", false, "追加模式：只转换输入文件在上次 -append 运行之后新增的完整的行（末尾没有换行的一行留到下次），新块接着已有的块编号，已有的分块文件保持不变（原来的最后一块没有指向新块的“下一页”，已有页面显示的仍是上次的总块数，需要完整的导航时去掉 -append 重新转换）；进度记录在输出目录的 "+appendStateFileName+" 中")
+	fs.BoolVar(&opts.force, "force", false, "允许删除不是为同一输入生成的已有输出目录；配合 -no-clean 使用时允许覆盖已存在的分块文件；同时跳过对二进制文件的检查")
+	fs.BoolVar(&opts.zip, "zip", false, "生成完成后将输出目录打包为 <输出目录>.zip")
+	fs.BoolVar(&opts.zipOnly, "zip-only", false, "同 -zip，但打包后删除输出目录")
+	fs.BoolVar(&opts.checksum, "checksum", false, "在输出目录中生成 "+checksumFileName+"，列出其中每个输出文件（包括 -gzip 的预压缩副本）的 SHA-256，可在输出目录中用 sha256sum -c "+checksumFileName+" 校验；不能与 -format epub、-single、-preview 同时使用")
+	fs.BoolVar(&opts.verify, "verify", false, "写出分块后检查每块文件的实际大小，报告与 -size 的平均和最大偏差（最后一块不计），超过目标大小时给出调整 -size 的建议；不能与 -lines、-format epub、-single、-preview、-dry-run 同时使用")
+	fs.BoolVar(&opts.gzip, "gzip", false, "同时为每个输出文件生成预压缩的 <文件名>.gz，供静态服务器以 Content-Encoding: gzip 返回；页面链接仍指向未压缩的文件名")
+	fs.BoolVar(&opts.markdown, "markdown", false, "将输入作为 Markdown 渲染（标题、粗体/斜体、列表、代码块、链接等）；此模式下章节正则只匹配去掉 # 后的标题文字")
+	fs.BoolVar(&opts.lineNumbers, "line-numbers", false, "在每行前以浅色显示它在原文件中的行号（跨分块连续编号），不能与 -markdown 同时使用")
+	fs.IntVar(&opts.wrapAt, "wrap-at", 0, "把超过 N 个字符的行折成多行（尽量在空白或汉字处折开），适合整段只有一行的文本，折后的每行按一行参与 -lines 分块和搜索，行号锚点和 -line-numbers 仍按原文的行；0 表示不折行，不能与 -markdown 同时使用")
+	trimBlank := fs.Bool("trim-blank-lines", false, "把连续的多个空行压缩为最多 -max-blank-lines 行，减少章节之间大段的空白")
+	maxBlank := fs.Int("max-blank-lines", 2, "配合 -trim-blank-lines 使用，连续空行最多保留的行数")
+	fs.BoolVar(&opts.single, "single", false, "把全书合并为一个HTML文件（-out 为该文件，默认: <文件名>.html），每块是一个可折叠的“第 N 部分”，共用一个设置面板；-size、-lines 只决定各部分的大小。不生成目录页、清单和搜索页面")
+	fs.BoolVar(&opts.preview, "preview", false, "快速预览：只生成第一块，读满即停止读取，写入单个HTML文件（-out 为该文件，默认: <文件名>_preview.html），用于在转换大文件前检查编码、分块大小和页面效果。不生成目录页、清单和搜索页面")
+	fs.BoolVar(&opts.dryRun, "dry-run", false, "试运行：切分后列出将生成的分块（文件名、大小、字数、开头章节）和检测到的章节标题，不创建、删除或写入任何文件，可用来试验 -size 等参数")
+	fs.BoolVar(&opts.watch, "watch", false, "转换后继续监视输入文件，每次保存修改后自动重新转换并显示时间，按 Ctrl+C 退出；只用于单个文件，重新转换失败时继续监视")
+	fs.BoolVar(&opts.open, "open", false, "配合 -preview 使用，生成后用系统默认的浏览器打开预览页面")
+	fs.BoolVar(&opts.copyAssets, "copy-assets", false, "把阅读页面的样式和脚本写入共用的 "+txt2html.ReaderStyleFile+" 和 "+txt2html.ReaderScriptFile+"，各分块引用它们而不是各自内联一份，分块很多时可大幅减小总大小")
+	fs.BoolVar(&opts.minify, "minify", false, "去掉页面模板、样式和脚本中的缩进、空行和注释，每块约小 20 KB，-size 按压缩后的大小分块；正文不受影响，不能与 -template 同时使用")
+	fs.StringVar(&opts.decodeMarker, "charset-fallback", "", "把无法按输入编码解码的字节显示为指定的标记（如 [?]），便于在页面中找到出错的位置；默认显示为替换字符 �。无论是否指定，转换结束时都会报告解码错误的数量")
+	fs.BoolVar(&opts.sanitize, "sanitize", false, "去掉正文中除换行和制表符外的控制字符（NUL、换页符、垂直制表符等），它们在页面中显示为方框或打乱排版；不指定时原样保留，转换结束时报告数量。含有空字节的文件会被当作二进制文件拒绝，需同时指定 -force")
+	fs.BoolVar(&opts.fileInfo, "file-info", false, "在每页的设置面板中加入默认折叠的“文件信息”：原文件名、修改时间、转换时间和程序版本，便于存档时追溯来源")
+	fs.BoolVar(&opts.minimal, "minimal", false, "生成只含正文和基本样式的精简页面，不含阅读设置面板和脚本")
+	templateFile := fs.String("template", "", "自定义分块页面模板文件（html/template 语法），代替内置的页面；可使用的字段见 txt2html.TemplateData，必须包含 {{.Content}}")
+	fs.StringVar(&opts.reportPath, "report", "", "转换结束后把结果写入指定的 JSON 文件：状态（ok 或 failed）、错误信息、输入和输出位置、使用的编码、输入和输出字节数、块数、解码错误数、控制字符数、章节数和用时；转换失败时同样写出，目录输入时包括每个文件的结果")
+	fs.BoolVar(&opts.quiet, "quiet", false, "不显示读取进度和转换过程，只输出错误，适合在脚本中使用")
+	fs.BoolVar(&opts.verbose, "verbose", false, "输出调试信息：使用的编码、每块的起止位置和原因、检测到的标题、解码错误所在的行、生成的每个文件，用于排查编码或分块问题")
+	fs.BoolVar(&opts.recursive, "recursive", false, "输入为目录时，同时转换子目录中的 .txt（及 .txt.gz）文件")
+	fs.BoolVar(&opts.resumeBatch, "resume", false, "批量转换目录时继续上次中断的转换：跳过已成功转换、且之后大小和修改时间都没有变化的文件；进度记录在输出目录的 "+batchStateFileName+" 中。选项与上次不同时请不要使用")
+	fs.BoolVar(&opts.noSearch, "no-search", false, "不生成全书搜索索引 search-index.js 和搜索页面 search.html")
+	fs.IntVar(&opts.width, "width", txt2html.DefaultCenterMaxWidth, "中央内容区最大宽度（px）")
+	header := fs.String("header", "", "显示在每页正文上方的页眉文字，例如来源说明")
+	footer := fs.String("footer", "", "显示在每页正文下方的页脚文字，例如版权声明")
+	rawHeader := fs.Bool("raw-header", false, "-header 的内容是可信的HTML，不做转义（-format epub 时须为合法的 XHTML）")
+	rawFooter := fs.Bool("raw-footer", false, "-footer 的内容是可信的HTML，不做转义（-format epub 时须为合法的 XHTML）")
+	fs.IntVar(&opts.tabWidth, "tab-width", txt2html.DefaultTabWidth, "制表符宽度（字符），页面中以 CSS tab-size 显示，不改动原文")
+	fs.BoolVar(&opts.expandTabs, "expand-tabs", false, "把纯文本中的制表符按 -tab-width 展开为空格，用于不支持 tab-size 的阅读器（如部分 EPUB 阅读器）")
+	fs.BoolVar(&opts.justify, "justify", false, "正文默认两端对齐，页面设置中也可以切换；纯文本中一行即一段时效果最好，已按固定宽度断行的文本基本没有变化")
+	fs.BoolVar(&opts.indent, "first-line-indent", false, "每段首行缩进两个字，页面设置中也可以切换；纯文本的段落按 -paragraphs 划分，段首原有的行首空白会被去掉")
+	fs.StringVar(&opts.paragraphs, "paragraphs", paragraphsBlank, "纯文本段落的划分方式，影响首行缩进和段落间距：blank（空行分隔段落，段内的换行只是换行）或 line（每行一段，适合段落之间没有空行的文本，每段前留出可在页面设置中调整的间距）；不能与 -markdown 同时使用")
+	fs.BoolVar(&opts.noGradient, "no-gradient", false, "页面背景使用单一的纯色（左侧背景色），不在正文两侧使用渐变，避免部分显示器上出现色带；设置面板中仍可调整正文背景和两侧背景，目录页和搜索页面同样生效。不能与 -format epub、-minimal 同时使用")
+	faviconFile := fs.String("favicon", "", "页面图标（PNG、ICO、SVG 等图片，最多 64 KB），以 data URI 嵌入每个分块、目录页和搜索页面，收藏后在浏览器中显示；默认为书本图标。-format epub 时不使用")
+	fs.StringVar(&opts.theme, "theme", "", "页面默认的阅读主题："+strings.Join(txt2html.ReaderThemes, "、")+"（默认: "+txt2html.ReaderThemes[0]+"）；读者在设置面板中选过主题后以读者的为准")
+	fs.StringVar(&opts.uiLang, "ui-lang", txt2html.UILanguages[0], "页面界面文字（设置面板、翻页、目录页和搜索页面）的语言："+strings.Join(txt2html.UILanguages, "、")+"，同时作为页面和 EPUB 的语言标记，朗读时据此选择语音")
+	fs.IntVar(&opts.columns, "columns", 1, "正文分栏数，适合宽屏阅读（-width 需相应调大）；页面设置中也可以调整")
+	fs.IntVar(&opts.anchorLines, "anchor-lines", defaultAnchorLines, "每隔多少行插入一个行号锚点，可用 <分块文件>#L<行号> 链接到该位置；0 表示不插入")
+	sizeKB := fs.Int("size", txt2html.DefaultTargetSize/1024, "每个分块HTML文件的目标大小（KB）")
+	fs.IntVar(&opts.linesPerChunk, "lines", 0, "按行数分块：每块包含原文的多少行，代替按 -size 分块，两者不能同时指定；章节标题仍从新的一块开始")
+	fs.BoolVar(&opts.balance, "balance", false, "均衡分块：块数与按 -size 切分时相同（最少），但各块大小尽量相同，不会剩下很小的最后一块；需要多次读取输入，不能用于 -stdin，不能与 -lines、-max-chunks、-append、-preview 同时使用")
+	fs.IntVar(&opts.overlap, "overlap", 0, "在每块开头重复上一块的最后 N 行（淡色显示），翻页后不会丢失上下文；重复的内容计入 -size，从新章节开始的块不重复")
+	fs.IntVar(&opts.revealLines, "reveal-lines", 0, "逐段显示：每块先显示前 N 行，其余部分每 N 行为一段，滚动接近时才显示，很大的块（例如 -max-chunks 的最后一块）也能很快显示出来；浏览器的页内查找和行号链接仍能定位到未显示的部分。0 表示整块一次显示，不能与 -format epub、-minimal、-template 同时使用")
+	fs.IntVar(&opts.maxChunks, "max-chunks", 0, "最多生成的分块数，0 表示不限；各块仍按 -size 或 -lines 切分，达到上限后其余内容全部放入最后一块，最后一块因此会超过目标大小")
+	fs.IntVar(&opts.jobs, "jobs", runtime.NumCPU(), "并行生成HTML文件的任务数")
+	maxLineMB := fs.Int("max-line-mb", defaultMaxLineMB, "允许的单行最大长度（MB），用于没有换行的超长文本")
+	fs.BoolVar(&opts.rawNames, "raw-names", false, "分块文件名（{base}）和默认输出位置保留输入文件名原样；默认把其中的空白替换为 _，把 # ? % & 引号等不便用于网址的字符替换为 -，汉字保留。页面标题和目录页仍显示原来的文件名")
+	namePatternFlag := fs.String("name-pattern", txt2html.DefaultNamePattern, "分块文件名模板，可用占位符 {base}（去掉扩展名的文件名，替换规则见 -raw-names）、{n}（块序号）、{total}（总块数），数字可指定宽度如 {n:04d}")
+	volumePattern := fs.String("volume-regex", txt2html.DefaultVolumePattern, "卷标题的正则表达式，匹配的行总是从新的一块开始；设为空字符串则不检测卷")
+	chapterPattern := fs.String("chapter-regex", txt2html.DefaultChapterPattern, "章节标题的正则表达式，匹配的行总是从新的一块开始；设为空字符串则不检测章节")
+	fs.StringVar(&opts.splitMarker, "split-marker", "", "分块标记：与之完全相同的行（如 ----PAGE----）总是开始新的一块，该行及其后的空行不输出；与按大小或行数分块同时生效，Markdown 中标记行前后须有空行")
+	sectionPattern := fs.String("section-regex", txt2html.DefaultSectionPattern, "节标题的正则表达式，节只加锚点和目录项，不单独分块；仅在 -toc-depth 3 时生效")
+	tocDepth := fs.Int("toc-depth", defaultTOCDepth, "标题检测层级：1=卷，2=卷和章，3=卷、章和节；目录按层级嵌套显示")
+	fs.Usage = func() {
+		out := fs.Output()
+		fmt.Fprintln(out, "用法: go run txt2html.go [选项] <文件名> [编码]")
+		fmt.Fprintln(out, "      go run txt2html.go [选项] <目录> [编码]")
+		fmt.Fprintln(out, "      go run txt2html.go -stdin [-name 名称] [选项] [编码]")
+		var names []string
+		for _, info := range txt2html.Encodings() {
+			names = append(names, info.Name)
+		}
+		fmt.Fprintf(out, "编码: %s 或 auto（自动检测），完整列表见 -list-encodings；单个文件默认 utf-8，目录默认 auto\n", strings.Join(names, "、"))
+		fmt.Fprintln(out, "输入: 纯文本（可以是 gzip 压缩的 .gz），或 EPUB、DOCX 文档（按文件内容识别，提取其中的文字，目录中的标题用作章节）")
+		fmt.Fprintln(out, "示例: go run txt2html.go -out book_html document.txt gbk")
+		fmt.Fprintln(out, "      go run txt2html.go -recursive novels")
+		fmt.Fprintln(out, "      cat book.txt | go run txt2html.go -stdin -name book")
+		fmt.Fprintln(out, "配置文件: JSON 对象，键名与选项名相同（不带 -），可用的键为 encoding（即 [编码] 参数）、title、format、size、lines、overlap、reveal-lines、balance、")
+		fmt.Fprintln(out, "      width、columns、tab-width、wrap-at、justify、first-line-indent、paragraphs、theme、ui-lang、no-gradient、minimal、minify、markdown、sanitize、template、favicon（路径相对于配置文件所在的目录）、header、footer、")
+		fmt.Fprintln(out, "      name-pattern、volume-regex、chapter-regex、section-regex、toc-depth、split-marker，例如 {\"encoding\": \"gbk\", \"size\": 512, \"theme\": \"sepia\"}")
+		fmt.Fprintln(out, "优先级: 命令行中指定的选项和编码 > 配置文件 > 默认值；命令行指定了 -size 或 -lines 时，配置文件中的另一个不生效")
+		fmt.Fprintln(out, "选项:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		if err == flag.ErrHelp {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if *showVersion {
+		fmt.Println(versionString())
+		return nil, nil
+	}
+	if *listEncodings {
+		printEncodings(os.Stdout)
+		return nil, nil
+	}
+
+	positional := fs.Args()
+	if opts.stdin {
+		if opts.fileName == "" {
+			opts.fileName = "stdin"
+		}
+	} else {
+		if len(positional) < 1 {
+			fs.Usage()
+			return nil, nil
+		}
+		opts.inputPath = positional[0]
+		positional = positional[1:]
+		if opts.fileName == "" {
+			// 取绝对路径的最后一段，输入为 "." 之类的目录时也能得到有意义的名称
+			name := opts.inputPath
+			if abs, err := filepath.Abs(name); err == nil {
+				name = abs
+			}
+			opts.fileName = trimGzipSuffix(filepath.Base(name))
+		}
+	}
+	if len(positional) > 0 {
+		opts.encodingName = positional[0]
+	}
+	opts.configPath = *configFile
+	if opts.configPath == "" && !opts.stdin {
+		opts.configPath = findConfig(opts.inputPath)
+	}
+	if opts.configPath != "" {
+		cfg, err := loadConfig(opts.configPath)
+		if err != nil {
+			return nil, err
+		}
+		if err := cfg.apply(fs); err != nil {
+			return nil, err
+		}
+		if opts.encodingName == "" && cfg.Encoding != nil {
+			opts.encodingName = *cfg.Encoding
+		}
+	}
+	if opts.theme != "" && !slices.Contains(txt2html.ReaderThemes, opts.theme) {
+		return nil, fmt.Errorf("不支持的阅读主题: %s（可用: %s）", opts.theme, strings.Join(txt2html.ReaderThemes, "、"))
+	}
+	if opts.paragraphs != paragraphsBlank && opts.paragraphs != paragraphsLine {
+		return nil, fmt.Errorf("不支持的段落划分方式: %s（可用: %s、%s）", opts.paragraphs, paragraphsBlank, paragraphsLine)
+	}
+	if !slices.Contains(txt2html.UILanguages, opts.uiLang) {
+		return nil, fmt.Errorf("不支持的界面语言: %s（可用: %s）", opts.uiLang, strings.Join(txt2html.UILanguages, "、"))
+	}
+	if opts.format != formatHTML && opts.format != formatEPUB {
+		return nil, fmt.Errorf("不支持的输出格式: %s（可用: %s、%s）", opts.format, formatHTML, formatEPUB)
+	}
+	if opts.format == formatEPUB && opts.zip {
+		return nil, fmt.Errorf("-format epub 不能与 -zip、-zip-only 同时使用，EPUB 本身就是压缩包")
+	}
+	if opts.quiet && opts.verbose {
+		return nil, fmt.Errorf("-quiet 不能与 -verbose 同时使用")
+	}
+	if opts.lineNumbers && opts.markdown {
+		return nil, fmt.Errorf("-line-numbers 不能与 -markdown 同时使用")
+	}
+	if opts.paragraphs == paragraphsLine && opts.markdown {
+		return nil, fmt.Errorf("-paragraphs %s 不能与 -markdown 同时使用", paragraphsLine)
+	}
+	if opts.wrapAt < 0 {
+		return nil, fmt.Errorf("-wrap-at 不能为负数: %d", opts.wrapAt)
+	}
+	if opts.wrapAt > 0 && opts.markdown {
+		return nil, fmt.Errorf("-wrap-at 只用于纯文本，不能与 -markdown 同时使用")
+	}
+	if opts.checksum && (opts.format == formatEPUB || opts.single || opts.preview) {
+		return nil, fmt.Errorf("-checksum 为输出目录中的文件生成清单，不能与 -format epub、-single、-preview 同时使用")
+	}
+	if opts.verify {
+		switch {
+		case opts.linesPerChunk > 0:
+			return nil, fmt.Errorf("-verify 检查按 -size 分块的结果，不能与 -lines 同时使用")
+		case opts.format == formatEPUB || opts.single || opts.preview || opts.dryRun:
+			return nil, fmt.Errorf("-verify 检查写出的分块文件，不能与 -format epub、-single、-preview、-dry-run 同时使用")
+		}
+	}
+	if opts.format == formatEPUB && opts.gzip {
+		return nil, fmt.Errorf("-format epub 不能与 -gzip 同时使用，EPUB 本身就是压缩包")
+	}
+	if opts.copyAssets && (opts.format == formatEPUB || opts.minimal || *templateFile != "") {
+		return nil, fmt.Errorf("-copy-assets 只用于默认的阅读页面，不能与 -format epub、-minimal、-template 同时使用")
+	}
+	if opts.single {
+		switch {
+		case opts.format == formatEPUB || opts.minimal:
+			return nil, fmt.Errorf("-single 不能与 -format epub、-minimal 同时使用")
+		case opts.appendMode:
+			return nil, fmt.Errorf("-single 不能与 -append 同时使用")
+		case opts.overlap > 0:
+			return nil, fmt.Errorf("-single 中各部分首尾相接，不能与 -overlap 同时使用")
+		case opts.zip || opts.zipOnly || opts.gzip || opts.copyAssets:
+			return nil, fmt.Errorf("-single 只生成一个文件，不能与 -zip、-zip-only、-gzip、-copy-assets 同时使用")
+		}
+	}
+	if opts.preview {
+		switch {
+		case opts.format == formatEPUB || opts.single:
+			return nil, fmt.Errorf("-preview 不能与 -format epub、-single 同时使用")
+		case opts.appendMode:
+			return nil, fmt.Errorf("-preview 不能与 -append 同时使用")
+		case opts.zip || opts.zipOnly || opts.gzip || opts.copyAssets:
+			return nil, fmt.Errorf("-preview 只生成一个文件，不能与 -zip、-zip-only、-gzip、-copy-assets 同时使用")
+		}
+	} else if opts.open {
+		return nil, fmt.Errorf("-open 需要配合 -preview 使用")
+	}
+	if opts.dryRun && opts.preview {
+		return nil, fmt.Errorf("-dry-run 不生成任何文件，不能与 -preview 同时使用")
+	}
+	if opts.watch {
+		switch {
+		case opts.stdin:
+			return nil, fmt.Errorf("-watch 需要监视输入文件，不能用于 -stdin")
+		case opts.appendMode || opts.dryRun:
+			return nil, fmt.Errorf("-watch 不能与 -append、-dry-run 同时使用")
+		case opts.reportPath != "":
+			return nil, fmt.Errorf("-watch 一直运行到手动退出，不能与 -report 同时使用")
+		}
+	}
+	if opts.noGradient && (opts.format == formatEPUB || opts.minimal) {
+		return nil, fmt.Errorf("-no-gradient 只用于完整的阅读页面，不能与 -format epub、-minimal 同时使用")
+	}
+	if opts.revealLines > 0 && (opts.format == formatEPUB || opts.minimal || *templateFile != "") {
+		return nil, fmt.Errorf("-reveal-lines 依靠阅读页面中的脚本，不能与 -format epub、-minimal、-template 同时使用")
+	}
+	if opts.fileInfo && (opts.format == formatEPUB || opts.minimal) {
+		return nil, fmt.Errorf("-file-info 显示在阅读页面的设置面板中，不能与 -format epub、-minimal 同时使用")
+	}
+	if *templateFile != "" {
+		if opts.minify {
+			return nil, fmt.Errorf("-minify 只压缩内置模板，不能与 -template 同时使用")
+		}
+		if opts.format == formatEPUB || opts.minimal {
+			return nil, fmt.Errorf("-template 不能与 -format epub、-minimal 同时使用")
+		}
+		tmpl, err := txt2html.ParseTemplateFile(*templateFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.template = tmpl
+	}
+	if opts.appendMode {
+		switch {
+		case opts.stdin:
+			return nil, fmt.Errorf("-append 不能与 -stdin 同时使用，需要从上次的位置继续读取输入文件")
+		case opts.format == formatEPUB:
+			return nil, fmt.Errorf("-append 不能与 -format epub 同时使用")
+		case opts.zipOnly:
+			return nil, fmt.Errorf("-append 不能与 -zip-only 同时使用，需要保留输出目录")
+		case opts.maxChunks > 0:
+			return nil, fmt.Errorf("-append 不能与 -max-chunks 同时使用，已有的最后一块不会再修改")
+		case strings.Contains(*namePatternFlag, "{total"):
+			return nil, fmt.Errorf("-append 时文件名模板不能包含 {total}，总块数变化后已有的文件名会对不上")
+		}
+	}
+	if *maxLineMB <= 0 {
+		return nil, fmt.Errorf("-max-line-mb 必须为正整数: %d", *maxLineMB)
+	}
+	opts.maxLineSize = *maxLineMB * 1024 * 1024
+	if opts.zipOnly {
+		opts.zip = true
+	}
+	if opts.width <= 0 {
+		return nil, fmt.Errorf("-width 必须为正整数: %d", opts.width)
+	}
+	if *faviconFile != "" {
+		favicon, err := loadFavicon(*faviconFile)
+		if err != nil {
+			return nil, err
+		}
+		opts.favicon = favicon
+	}
+	opts.header = pageText(*header, *rawHeader)
+	opts.footer = pageText(*footer, *rawFooter)
+	if opts.tabWidth < 1 {
+		return nil, fmt.Errorf("-tab-width 必须为正整数: %d", opts.tabWidth)
+	}
+	if opts.columns < 1 {
+		return nil, fmt.Errorf("-columns 必须为正整数: %d", opts.columns)
+	}
+	if *trimBlank {
+		if *maxBlank < 1 {
+			return nil, fmt.Errorf("-max-blank-lines 必须为正整数: %d", *maxBlank)
+		}
+		opts.maxBlank = *maxBlank
+	}
+	sizeSet := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == "size" {
+			sizeSet = true
+		}
+	})
+	if sizeSet && opts.linesPerChunk > 0 {
+		return nil, fmt.Errorf("-size 和 -lines 不能同时使用")
+	}
+	if *sizeKB <= 0 {
+		return nil, fmt.Errorf("-size 必须为正整数: %d", *sizeKB)
+	}
+	opts.targetSize = *sizeKB * 1024
+	if opts.linesPerChunk < 0 {
+		return nil, fmt.Errorf("-lines 不能为负数: %d", opts.linesPerChunk)
+	}
+	if opts.overlap < 0 {
+		return nil, fmt.Errorf("-overlap 不能为负数: %d", opts.overlap)
+	}
+	if opts.splitMarker != "" && strings.TrimSpace(opts.splitMarker) == "" {
+		return nil, fmt.Errorf("-split-marker 不能只包含空白，否则每个空行都会开始新的一块")
+	}
+	if opts.revealLines < 0 {
+		return nil, fmt.Errorf("-reveal-lines 不能为负数: %d", opts.revealLines)
+	}
+	if opts.balance {
+		switch {
+		case opts.stdin:
+			return nil, fmt.Errorf("-balance 需要多次读取输入，不能用于 -stdin")
+		case opts.linesPerChunk > 0 || opts.maxChunks > 0:
+			return nil, fmt.Errorf("-balance 只用于按 -size 分块，不能与 -lines、-max-chunks 同时使用")
+		case opts.appendMode || opts.preview:
+			return nil, fmt.Errorf("-balance 不能与 -append、-preview 同时使用")
+		}
+	}
+	if opts.maxChunks < 0 {
+		return nil, fmt.Errorf("-max-chunks 不能为负数: %d", opts.maxChunks)
+	}
+	if opts.anchorLines < 0 {
+		return nil, fmt.Errorf("-anchor-lines 不能为负数: %d", opts.anchorLines)
+	}
+	if opts.jobs < 1 {
+		return nil, fmt.Errorf("-jobs 必须为正整数: %d", opts.jobs)
+	}
+	if *tocDepth < txt2html.LevelVolume || *tocDepth > txt2html.LevelSection {
+		return nil, fmt.Errorf("-toc-depth 必须为 1 到 3: %d", *tocDepth)
+	}
+	patterns := []struct {
+		level   int
+		name    string
+		pattern string
+	}{
+		{txt2html.LevelVolume, "卷", *volumePattern},
+		{txt2html.LevelChapter, "章节", *chapterPattern},
+		{txt2html.LevelSection, "节", *sectionPattern},
+	}
+	for _, p := range patterns {
+		if p.level > *tocDepth || p.pattern == "" {
+			continue
+		}
+		re, err := regexp.Compile(p.pattern)
+		if err != nil {
+			return nil, fmt.Errorf("无效的%s正则表达式: %v", p.name, err)
+		}
+		// 节通常很短，单独分块会产生大量小文件
+		opts.headingRules = append(opts.headingRules, txt2html.HeadingRule{
+			Level:    p.level,
+			Pattern:  re,
+			NewChunk: p.level <= txt2html.LevelChapter,
+		})
+	}
+	names, err := txt2html.ParseNamePattern(*namePatternFlag)
+	if err != nil {
+		return nil, err
+	}
+	opts.names = names
+	return opts, nil
 }
 
-// HTML模板内容 - 支持左右两侧展示背景颜色自定义
-const htmlTemplate = ` + "`" + `<!DOCTYPE html>
-<html lang="zh-CN">
-<head>
-    <meta charset="UTF-8">
-    <meta name="viewport" content="width=device-width, initial-scale=1.0">
-    <title>{{.FileName}} - 第{{.CurrentChunk}}部分</title>
-    <style>
-        :root {
-            --left-bg: #f5f5f5;   /* 左侧默认背景 */
-            --center-bg: #ffffff; /* 中央内容背景 */
-            --right-bg: #f5f5f5;  /* 右侧默认背景 */
-            --center-max-width: 1000px;
-        }
-        /* 使用线性渐变在页面两侧显示可配置颜色，中间使用中心背景色 */
-        body {
-            --g-left: calc(50% - var(--center-max-width) / 2);
-            --g-right: calc(50% + var(--center-max-width) / 2);
-            background: linear-gradient(to right,
-                        var(--left-bg) 0px var(--g-left),
-                        var(--center-bg) var(--g-left) var(--g-right),
-                        var(--right-bg) var(--g-right) 100%);
-            color: #333;
-            font-family: 'Segoe UI', Tahoma, Geneva, Verdana, sans-serif;
-            padding: 20px;
-            margin: 0;
-            font-size: 16px;
-        }
-        .controls {
-            margin-bottom: 20px;
-            padding: 15px;
-            background-color: #f5f5f5;
-            border-radius: 8px;
-            display: flex;
-            flex-wrap: wrap;
-            gap: 15px;
-            align-items: center;
-            box-shadow: 0 2px 4px rgba(0,0,0,0.1);
-        }
-        .control-section {
-            display: flex;
-            flex-direction: column;
-            gap: 8px;
-        }
-        .control-group {
-            display: flex;
-            gap: 10px;
-            align-items: center;
-        }
-        button {
-            background-color: #e0e0e0;
-            color: #333;
-            border: none;
-            padding: 8px 16px;
-            border-radius: 4px;
-            cursor: pointer;
-            transition: background-color 0.3s;
-            font-size: 16px;
-        }
-        button:hover {
-            background-color: #ccc;
-        }
-        .page-center {
-            max-width: var(--center-max-width);
-            margin: 0 auto;
-            padding: 20px;
-        }
-        .content {
-            white-space: pre-wrap;
-            word-wrap: break-word;
-            padding: 25px;
-            border-radius: 8px;
-            box-shadow: 0 2px 8px rgba(0,0,0,0.1);
-            min-height: 300px;
-            transition: background-color 0.3s, color 0.3s, line-height 0.3s;
-            line-height: 1.6; /* 默认行距 */
-            background-color: var(--center-bg);
-        }
-        .chunk-info {
-            color: #666;
-            font-size: 0.9em;
-            margin-top: 10px;
-            width: 100%;
-            text-align: right;
-        }
-        .color-options {
-            display: flex;
-            gap: 8px;
-            align-items: center;
-        }
-        .color-preview {
-            width: 20px;
-            height: 20px;
-            border-radius: 4px;
-            border: 1px solid rgba(0,0,0,0.12);
-            box-shadow: 0 1px 2px rgba(0,0,0,0.05);
-            display: inline-block;
-            vertical-align: middle;
-        }
-        .display-value {
-            min-width: 50px;
-            text-align: center;
-        }
-    </style>
-</head>
-<body>
-    <div class="controls">
-        <!-- 字体大小控制 -->
-        <div class="control-section">
-            <span>字体大小调节</span>
-            <div class="control-group">
-                <button onclick="changeFontSize(-1)">A-</button>
-                <span id="fontSizeDisplay" class="display-value">16px</span>
-                <button onclick="changeFontSize(1)">A+</button>
-            </div>
-        </div>
-        
-        <!-- 行距控制 -->
-        <div class="control-section">
-            <span>行距调节</span>
-            <div class="control-group">
-                <button onclick="changeLineHeight(-0.2)">行距-</button>
-                <span id="lineHeightDisplay" class="display-value">1.6</span>
-                <button onclick="changeLineHeight(0.2)">行距+</button>
-            </div>
-        </div>
-        
-        <!-- 字体颜色控制 -->
-        <div class="control-section">
-            <span>字体颜色选择</span>
-            <div class="control-group">
-                <select id="textColorSelect" aria-label="字体颜色选择">
-                    <option value="#111111">黑色 (#111111)</option>
-                    <option value="#2F4F4F">深石板灰（护眼）(#2F4F4F)</option>
-                    <option value="#333333" selected>默认深灰 (#333333)</option>
-                    <option value="#444444">中灰 (#444444)</option>
-                    <option value="#5B4636">温暖棕（护眼）(#5B4636)</option>
-                    <option value="#0066cc">深蓝 (#0066cc)</option>
-                    <option value="#006600">深绿（护眼）(#006600)</option>
-                    <option value="#8a2be2">紫色 (#8a2be2)</option>
-                    <option value="#6B4423">柔和棕（护眼）(#6B4423)</option>
-                    <option value="#4A4A4A">柔和深灰 (#4A4A4A)</option>
-                </select>
-                <span id="textColorPreview" class="color-preview" style="background:#333"></span>
-            </div>
-        </div>
-        
-        <!-- 背景颜色控制（中间/左侧/右侧） -->
-        <div class="control-section">
-            <span>背景颜色选择</span>
-            <div style="display:flex;flex-direction:column;gap:8px;">
-                <div class="control-group">
-                    <span>中间背景</span>
-                    <select id="centerColorSelect" aria-label="中间背景颜色选择">
-                        <option value="#ffffff" selected>白色 (#ffffff)</option>
-                        <option value="#fffdf0">暖白/米色 (#fffdf0)</option>
-                        <option value="#fffbe6">柔和乳白 (#fffbe6)</option>
-                        <option value="#ffffee">浅黄 (#ffffee)</option>
-                        <option value="#f7fff7">护眼绿（浅）(#f7fff7)</option>
-                        <option value="#f6f9ff">护眼蓝（浅）(#f6f9ff)</option>
-                    </select>
-                    <span id="centerColorPreview" class="color-preview" style="background:#ffffff;margin-left:8px"></span>
-                </div>
-                <div class="control-group">
-                    <span>左侧背景</span>
-                    <select id="leftColorSelect" aria-label="左侧背景颜色选择">
-                        <option value="#f5f5f5" selected>浅灰 (#f5f5f5)</option>
-                        <option value="#ffffff">白色 (#ffffff)</option>
-                        <option value="#fffdf0">暖白/米色（护眼）(#fffdf0)</option>
-                        <option value="#fffbe6">柔和乳白（护眼）(#fffbe6)</option>
-                        <option value="#ffffee">浅黄（护眼）(#ffffee)</option>
-                        <option value="#f7fff7">护眼绿（浅）(#f7fff7)</option>
-                        <option value="#f0fff0">浅绿 (#f0fff0)</option>
-                        <option value="#f6f9ff">护眼蓝（浅）(#f6f9ff)</option>
-                        <option value="#f7f0ff">浅紫 (#f7f0ff)</option>
-                        <option value="#eeeae0">米灰 (#eeeae0)</option>
-                    </select>
-                    <span id="leftColorPreview" class="color-preview" style="background:#f5f5f5;margin-left:8px"></span>
-                </div>
-                <div class="control-group">
-                    <span>右侧背景</span>
-                    <select id="rightColorSelect" aria-label="右侧背景颜色选择">
-                        <option value="#f5f5f5" selected>浅灰 (#f5f5f5)</option>
-                        <option value="#ffffff">白色 (#ffffff)</option>
-                        <option value="#fffdf0">暖白/米色（护眼）(#fffdf0)</option>
-                        <option value="#fffbe6">柔和乳白（护眼）(#fffbe6)</option>
-                        <option value="#ffffee">浅黄（护眼）(#ffffee)</option>
-                        <option value="#f7fff7">护眼绿（浅）(#f7fff7)</option>
-                        <option value="#f0fff0">浅绿 (#f0fff0)</option>
-                        <option value="#f6f9ff">护眼蓝（浅）(#f6f9ff)</option>
-                        <option value="#f7f0ff">浅紫 (#f7f0ff)</option>
-                        <option value="#eeeae0">米灰 (#eeeae0)</option>
-                    </select>
-                    <span id="rightColorPreview" class="color-preview" style="background:#f5f5f5;margin-left:8px"></span>
-                </div>
-            </div>
-        </div>
-        
-        <!-- 分页信息 -->
-        <div class="chunk-info">
-            第 {{.CurrentChunk}} / {{.TotalChunks}} 部分
-        </div>
-    </div>
-    
-    <div class="page-center">
-        <div class="content" id="mainContent">
-            {{.Content}}
-        </div>
-    </div>
-
-    <script>
-        // 确保DOM加载完成后执行
-        document.addEventListener('DOMContentLoaded', function() {
-            // 获取元素引用
-            const contentElement = document.getElementById('mainContent');
-            let currentFontSize = 16;
-            let currentLineHeight = 1.6; // 默认行距
-            
-            // 字体颜色切换功能
-            document.querySelectorAll('#textColorOptions .color-option').forEach(option => {
-                option.addEventListener('click', function() {
-                    document.querySelectorAll('#textColorOptions .color-option').forEach(o => o.classList.remove('active'));
-                    this.classList.add('active');
-                    const color = this.getAttribute('data-color');
-                    contentElement.style.color = color;
-                });
-            });
-            
-            // 中央内容背景颜色切换功能（下拉菜单）
-            const centerColorSelect = document.getElementById('centerColorSelect');
-            const centerColorPreview = document.getElementById('centerColorPreview');
-            centerColorSelect.addEventListener('change', function() {
-                const c = this.value;
-                document.documentElement.style.setProperty('--center-bg', c);
-                contentElement.style.backgroundColor = c;
-                centerColorPreview.style.background = c;
-            });
-
-            // 左侧/右侧：使用下拉菜单选择颜色，更新 CSS 变量与预览
-            const leftColorSelect = document.getElementById('leftColorSelect');
-            const rightColorSelect = document.getElementById('rightColorSelect');
-            const leftPreview = document.getElementById('leftColorPreview');
-            const rightPreview = document.getElementById('rightColorPreview');
-
-            leftColorSelect.addEventListener('change', function() {
-                const c = this.value;
-                document.documentElement.style.setProperty('--left-bg', c);
-                leftPreview.style.background = c;
-            });
-            rightColorSelect.addEventListener('change', function() {
-                const c = this.value;
-                document.documentElement.style.setProperty('--right-bg', c);
-                rightPreview.style.background = c;
-            });
-
-            // 字体颜色选择（下拉菜单，10色）
-            const textColorSelect = document.getElementById('textColorSelect');
-            const textColorPreview = document.getElementById('textColorPreview');
-            textColorSelect.addEventListener('change', function() {
-                const c = this.value;
-                contentElement.style.color = c;
-                textColorPreview.style.background = c;
-            });
-            
-            // 字体大小调节功能
-            window.changeFontSize = function(change) {
-                currentFontSize += change;
-                // 限制字体大小范围
-                if (currentFontSize < 10) currentFontSize = 10;
-                if (currentFontSize > 36) currentFontSize = 36;
-                
-                contentElement.style.fontSize = currentFontSize + "px";
-                document.getElementById("fontSizeDisplay").textContent = currentFontSize + "px";
-            };
-            
-            // 行距调节功能
-            window.changeLineHeight = function(change) {
-                currentLineHeight += change;
-                // 限制行距范围（0.8到3.0之间）
-                if (currentLineHeight < 0.8) currentLineHeight = 0.8;
-                if (currentLineHeight > 3.0) currentLineHeight = 3.0;
-                
-                // 保留一位小数显示
-                const displayValue = currentLineHeight.toFixed(1);
-                contentElement.style.lineHeight = currentLineHeight;
-                document.getElementById("lineHeightDisplay").textContent = displayValue;
-            };
-        });
-    </script>
-</body>
-</html>`
-
-// 计算HTML模板的基础大小（不含内容）
-func getBaseHTMLSize(fileName string, totalChunks, currentChunk int) int {
-	data := TemplateData{
-		Content:      "",
-		FileName:     fileName,
-		TotalChunks:  totalChunks,
-		CurrentChunk: currentChunk,
-	}
-	tmpl, _ := template.New("htmlTemplate").Parse(htmlTemplate)
-	var buf io.Writer = &bytes.Buffer{}
-	tmpl.Execute(buf, data)
-	return buf.(*bytes.Buffer).Len()
+// 页眉、页脚的内容：默认按纯文本转义，raw 时原样作为HTML插入
+func pageText(text string, raw bool) template.HTML {
+	if raw {
+		return template.HTML(text)
+	}
+	return template.HTML(template.HTMLEscapeString(text))
 }
 
-func main() {
-	if len(os.Args) < 2 {
-		fmt.Println("用法: go run txt2html.go <文件名> [编码]")
-		fmt.Println("示例: go run txt2html.go document.txt gbk")
-		return
+// 每行列出一种输入编码：名称、别名和说明
+func printEncodings(w io.Writer) {
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	for _, info := range txt2html.Encodings() {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", info.Name, strings.Join(txt2html.EncodingAliases(info.Name), ", "), info.Description)
 	}
+	fmt.Fprintf(tw, "%s\t\t根据 BOM 和内容自动检测 UTF-8、UTF-16 或 GBK\n", txt2html.AutoEncoding)
+	tw.Flush()
+	fmt.Fprintln(w, "名称不区分大小写，空格、下划线和连字符可以省略，如 UTF8、GB_2312")
+}
 
-	inputFilePath := os.Args[1]
-	encodingName := "utf-8"
-	if len(os.Args) > 2 {
-		encodingName = os.Args[2]
+// 版本号及构建使用的 Go 版本和目标平台。未注入版本号时，
+// 以 go install 安装的模块版本为准
+func versionString() string {
+	v := version
+	if info, ok := debug.ReadBuildInfo(); ok && v == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		v = info.Main.Version
 	}
+	return fmt.Sprintf("txt2html %s (%s %s/%s)", v, runtime.Version(), runtime.GOOS, runtime.GOARCH)
+}
 
-	if _, err := os.Stat(inputFilePath); os.IsNotExist(err) {
-		fmt.Printf("错误: 文件不存在 - %s\n", inputFilePath)
-		return
+// 检查即将写入的文件是否已存在，用于 -no-clean 模式下防止误覆盖
+func checkOverwrite(outputDir string, fileNames []string) error {
+	for _, name := range fileNames {
+		path := filepath.Join(outputDir, name)
+		if _, err := os.Stat(path); err == nil {
+			return fmt.Errorf("文件已存在: %s（使用 -force 允许覆盖）", path)
+		}
 	}
+	return nil
+}
+
+func main() {
+	if err := run(os.Args[1:]); err != nil {
+		fmt.Fprintf(os.Stderr, "错误: %v\n", err)
+		os.Exit(1)
+	}
+}
 
-	inputFile, err := os.Open(inputFilePath)
+// 执行一次完整的转换，任何失败都以 error 返回，由 main 决定退出码
+func run(args []string) error {
+	opts, err := parseOptions(args)
 	if err != nil {
-		fmt.Printf("无法打开文件: %v\n", err)
-		return
+		return err
+	}
+	if opts == nil {
+		return nil
+	}
+	level := slog.LevelInfo
+	switch {
+	case opts.quiet:
+		level = slog.LevelError
+	case opts.verbose:
+		level = slog.LevelDebug
 	}
-	defer inputFile.Close()
+	slog.SetDefault(newConsoleLogger(os.Stdout, os.Stderr, level))
+	if opts.configPath != "" {
+		slog.Info(fmt.Sprintf("使用配置文件: %s", opts.configPath))
+	}
+	if opts.watch {
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+		defer stop()
+		return watchInput(ctx, opts, watchInterval)
+	}
+	if opts.reportPath == "" {
+		return convertInput(opts)
+	}
+
+	start := time.Now()
+	opts.report = &report{Input: opts.inputPath}
+	if opts.stdin {
+		opts.report.Input = stdinSource
+	}
+	err = convertInput(opts)
+	opts.report.finish(err, start)
+	if writeErr := writeReport(opts.reportPath, opts.report); writeErr != nil {
+		writeErr = fmt.Errorf("无法写入转换结果 %s: %w", opts.reportPath, writeErr)
+		if err == nil {
+			return writeErr
+		}
+		slog.Error(writeErr.Error())
+	}
+	return err
+}
+
+// 按输入是文件、目录还是标准输入选择转换方式，并确定默认的输出位置
+func convertInput(opts *options) error {
+	if !opts.stdin {
+		info, err := os.Stat(opts.inputPath)
+		if os.IsNotExist(err) {
+			return fmt.Errorf("文件不存在 - %s", opts.inputPath)
+		}
+		if err == nil && info.IsDir() {
+			if opts.preview {
+				return fmt.Errorf("-preview 只用于单个文件，不能用于目录输入")
+			}
+			if opts.outputDir == "" {
+				opts.outputDir = defaultOutputPath(opts.outputName(), opts.format, opts.single, true, "")
+			}
+			return convertDir(opts)
+		}
+	}
+	if opts.resumeBatch {
+		return fmt.Errorf("-resume 只用于批量转换目录，单个文件可使用 -append 只转换新增的内容")
+	}
+	if opts.outputDir == "" {
+		source := ""
+		if !opts.stdin {
+			source = sourceID(opts)
+		}
+		name := opts.outputName()
+		opts.outputDir = defaultOutputPath(name, opts.format, opts.single, false, source)
+		if opts.preview {
+			opts.outputDir = name[:len(name)-len(filepath.Ext(name))] + "_preview.html"
+		}
+	}
+	return convert(opts)
+}
+
+// 默认输出位置使用的文件名，未指定 -raw-names 时与分块文件名一样替换空白等字符
+func (opts *options) outputName() string {
+	if opts.rawNames {
+		return opts.fileName
+	}
+	return txt2html.SafeFileName(opts.fileName)
+}
+
+// 未指定 -out 时的输出位置：HTML 为 <文件名>_<哈希>_html_chunks 目录，哈希取自输入文件的标识 source，
+// source 为空时（标准输入）不加哈希；EPUB 为去掉扩展名的 <文件名>.epub；批量转换目录时为存放各文件输出的目录
+func defaultOutputPath(fileName, format string, single, isDir bool, source string) string {
+	switch {
+	case single && isDir:
+		return fileName + "_html"
+	case single:
+		return fileName[:len(fileName)-len(filepath.Ext(fileName))] + ".html"
+	case format == formatEPUB && isDir:
+		return fileName + "_epub"
+	case format == formatEPUB:
+		return fileName[:len(fileName)-len(filepath.Ext(fileName))] + ".epub"
+	case source != "" && !isDir:
+		return fileName + "_" + sourceHash(source) + "_html_chunks"
+	default:
+		return fileName + "_html_chunks"
+	}
+}
+
+// 转换单个文件或标准输入
+func convert(opts *options) error {
+	fileName := opts.fileName
+	encodingName := opts.encodingName
+	if encodingName == "" {
+		encodingName = "utf-8"
+	}
+	if encodingName = txt2html.CanonicalEncoding(encodingName); encodingName == "" {
+		return fmt.Errorf("不支持的编码: %s（使用 -list-encodings 查看支持的编码）", opts.encodingName)
+	}
+
+	// 追加模式下读取上次的进度，没有进度时按普通方式完整转换一次
+	outputDir := opts.outputDir
+	var state *appendState
+	if opts.appendMode {
+		var err error
+		if state, err = loadAppendState(outputDir); err != nil {
+			return err
+		}
+		if state != nil {
+			if opts.encodingName != "" && encodingName != txt2html.AutoEncoding && encodingName != state.Encoding {
+				return fmt.Errorf("编码 %s 与上次转换使用的 %s 不同", opts.encodingName, state.Encoding)
+			}
+			encodingName = state.Encoding
+		}
+	}
+
+	// 打开输入：标准输入不可 seek，但切分只需单遍读取，因此两种来源的处理完全相同
+	var input io.Reader
+	var inputSize int64 // 输入的字节数，标准输入时未知为0
+	var compressed bool // 输入为 gzip 压缩数据，读取时先解压
+	var inputFile *os.File
+	var document *extractedText // 从 EPUB、DOCX 中提取的文本，普通文本为 nil
+	provenance := &txt2html.Provenance{SourceFile: "标准输入", GeneratedAt: time.Now(), Generator: versionString()}
+	if opts.stdin {
+		slog.Info(fmt.Sprintf("处理标准输入: %s", fileName))
+		// 先确认有内容再准备输出目录，空输入不留下空的输出
+		buffered := bufio.NewReader(os.Stdin)
+		magic, err := buffered.Peek(len(gzipMagic))
+		if len(magic) == 0 && err == io.EOF {
+			return fmt.Errorf("标准输入为空，没有可转换的内容")
+		}
+		compressed = bytes.Equal(magic, gzipMagic)
+		input = buffered
+	} else {
+		if _, err := os.Stat(opts.inputPath); os.IsNotExist(err) {
+			return fmt.Errorf("文件不存在 - %s", opts.inputPath)
+		}
+		var err error
+		inputFile, err = os.Open(opts.inputPath)
+		if err != nil {
+			return fmt.Errorf("无法打开文件: %w", err)
+		}
+		defer inputFile.Close()
 
-	// 删除旧的输出目录（确保生成新文件）
-	outputDir := filepath.Base(inputFilePath) + "_html_chunks"
-	os.RemoveAll(outputDir)
-	os.MkdirAll(outputDir, 0755)
+		fileInfo, err := inputFile.Stat()
+		if err != nil {
+			return fmt.Errorf("无法读取文件信息: %w", err)
+		}
+		slog.Info(fmt.Sprintf("处理文件: %s (%.2f MB)", inputFile.Name(), float64(fileInfo.Size())/1024/1024))
+		input = inputFile
+		inputSize = fileInfo.Size()
+		provenance.SourceFile = filepath.Base(opts.inputPath)
+		provenance.SourceModTime = fileInfo.ModTime()
+		if inputSize == 0 {
+			return fmt.Errorf("文件为空，没有可转换的内容 - %s", opts.inputPath)
+		}
+		// 按文件头而不是扩展名判断，.gz 文件解压后保存的或改了名的压缩文件都能正确处理
+		compressed = isGzipFile(inputFile)
+		if compressed && opts.appendMode {
+			return fmt.Errorf("-append 不支持 gzip 压缩的输入，压缩数据无法从上次的位置继续读取")
+		}
+		// 输出写入前会先删除或清空同名的文件，-format epub 转换 EPUB 输入时默认的输出就是输入本身
+		if outInfo, err := os.Stat(outputDir); err == nil && os.SameFile(fileInfo, outInfo) {
+			return fmt.Errorf("输出位置与输入文件相同: %s，请用 -out 指定其他位置", outputDir)
+		}
+		// EPUB 和 DOCX 先提取出纯文本，之后与普通文本的处理完全相同
+		if !compressed {
+			kind, zr, err := detectDocument(inputFile, inputSize)
+			if err != nil {
+				return err
+			}
+			if kind != "" {
+				if opts.appendMode {
+					return fmt.Errorf("-append 不支持 %s 输入，提取出的文本无法从上次的位置继续读取", kind)
+				}
+				if document, err = extractDocument(zr, kind); err != nil {
+					return err
+				}
+				slog.Info(fmt.Sprintf("已从 %s 中提取文本: %d 行，目录中有 %d 个标题", kind, document.lines, len(document.titles)))
+				if encodingName != "utf-8" && encodingName != txt2html.AutoEncoding {
+					slog.Warn(fmt.Sprintf("%s 中的文本总是 UTF-8，忽略指定的编码 %s", kind, opts.encodingName))
+				}
+				encodingName = "utf-8"
+				input = bytes.NewReader(document.text)
+				inputSize = int64(len(document.text))
+			}
+		}
+		if opts.appendMode {
+			var offset int64
+			if state != nil {
+				offset = state.Offset
+				switch {
+				case inputSize < offset:
+					return fmt.Errorf("输入文件比上次转换时短，可能已被修改，请去掉 -append 重新转换")
+				case inputSize == offset:
+					slog.Info(fmt.Sprintf("没有新增内容，保持 %s 不变", outputDir))
+					return nil
+				}
+			}
+			// 只读到此刻的文件末尾的最后一个完整行：转换期间继续写入的内容和还没写完的一行都留到下次，
+			// 记录的进度与实际读取的内容一致，不会重复转换
+			end, err := completeLinesEnd(inputFile, offset, inputSize, appendNewline(inputFile, encodingName))
+			if err != nil {
+				return fmt.Errorf("无法读取输入: %w", err)
+			}
+			if end < inputSize {
+				slog.Info(fmt.Sprintf("末尾的 %d 字节还不是完整的一行，留到下次追加时转换", inputSize-end))
+			}
+			if end == offset {
+				slog.Info(fmt.Sprintf("没有新增的完整行，保持 %s 不变", outputDir))
+				return nil
+			}
+			if state != nil {
+				slog.Info(fmt.Sprintf("追加模式: 从第 %d 字节继续，已有 %d 块", offset, len(state.Chunks)))
+			}
+			inputSize = end
+			input = io.NewSectionReader(inputFile, offset, end-offset)
+		}
+	}
 
-	fileInfo, _ := inputFile.Stat()
-	fmt.Printf("处理文件: %s (%.2f MB)\n", inputFile.Name(), float64(fileInfo.Size())/1024/1024)
+	if opts.dryRun {
+		slog.Info(fmt.Sprintf("试运行: 不会写入 %s", outputDir))
+	}
 
-	decoder := getEncodingDecoder(encodingName)
-	if decoder == nil {
-		fmt.Printf("不支持的编码: %s\n", encodingName)
-		return
+	// -report 的各项结果随处理过程填入，未指定时填入的结果直接丢弃
+	rep := opts.report
+	if rep == nil {
+		rep = &report{}
 	}
+	rep.Output = outputDir
+	rep.DryRun = opts.dryRun
+	inputCounter := &countingReader{r: input}
+	input = inputCounter
 
-	reader := transform.NewReader(inputFile, decoder.NewDecoder())
-	scanner := bufio.NewScanner(reader)
-	scanner.Buffer(make([]byte, readBufferSize), readBufferSize)
+	// 调试信息逐行输出，与在同一行刷新的进度混在一起难以阅读
+	var progress *progressReader
+	if !opts.quiet && !opts.verbose {
+		total := inputSize
+		if state != nil {
+			total -= state.Offset
+		}
+		progress = newProgressReader(input, os.Stdout, total)
+		input = progress
+	}
+	// 进度按解压前的字节数统计，才能与文件大小比较；编码检测等都在解压后的内容上进行
+	if compressed {
+		gz, err := gzip.NewReader(input)
+		if err != nil {
+			return fmt.Errorf("无法解压输入: %w", err)
+		}
+		defer gz.Close()
+		input = gz
+	}
 
-	// 先预读计算总块数（粗略估计）
-	var totalLines int
-	tempReader := transform.NewReader(inputFile, decoder.NewDecoder())
-	tempScanner := bufio.NewScanner(tempReader)
-	for tempScanner.Scan() {
-		totalLines++
+	converter := &txt2html.Converter{
+		FileName:        fileName,
+		Title:           opts.title,
+		Encoding:        encodingName,
+		NamePattern:     opts.names,
+		HeadingRules:    opts.headingRules,
+		Markdown:        opts.markdown,
+		CenterMaxWidth:  opts.width,
+		Columns:         opts.columns,
+		TabWidth:        opts.tabWidth,
+		ExpandTabs:      opts.expandTabs,
+		Justify:         opts.justify,
+		FirstLineIndent: opts.indent,
+		LineParagraphs:  opts.paragraphs == paragraphsLine,
+		Theme:           opts.theme,
+		UILang:          opts.uiLang,
+		SolidBackground: opts.noGradient,
+		Favicon:         opts.favicon,
+		Header:          opts.header,
+		Footer:          opts.footer,
+		AnchorLines:     opts.anchorLines,
+		LineNumbers:     opts.lineNumbers,
+		DecodeMarker:    opts.decodeMarker,
+		Sanitize:        opts.sanitize,
+		MaxLineSize:     opts.maxLineSize,
+		TargetSize:      opts.targetSize,
+		LinesPerChunk:   opts.linesPerChunk,
+		Overlap:         opts.overlap,
+		SplitMarker:     opts.splitMarker,
+		RevealLines:     opts.revealLines,
+		MaxChunks:       opts.maxChunks,
+		FirstChunkOnly:  opts.preview,
+		MaxBlankLines:   opts.maxBlank,
+		WrapAt:          opts.wrapAt,
+		RawNames:        opts.rawNames,
+		AllowBinary:     opts.force,
+		Minify:          opts.minify,
+		Logger:          slog.Default(),
+		// 分块以外的输出文件，分块文件名不能与它们相同
+		ReservedNames: []string{indexFileName, manifestFileName},
+	}
+	if opts.fileInfo {
+		converter.Provenance = provenance
+	}
+	// 文档自带的标题补充章节正则，优先级最低：也符合卷、章、节正则的标题行仍按原来的层级。
+	// 没有检测章节时（-chapter-regex 为空或 -toc-depth 1）不使用
+	if document != nil && opts.title == "" && document.title != "" {
+		converter.Title = document.title
+	}
+	if document != nil && slices.ContainsFunc(opts.headingRules, func(rule txt2html.HeadingRule) bool { return rule.Level == txt2html.LevelChapter }) {
+		if pattern := documentTitlePattern(document.titles); pattern != nil {
+			converter.HeadingRules = append(slices.Clip(opts.headingRules), txt2html.HeadingRule{
+				Level:    txt2html.LevelChapter,
+				Pattern:  pattern,
+				NewChunk: true,
+			})
+		}
+	}
+	switch {
+	case opts.format == formatEPUB:
+		converter.Layout = txt2html.LayoutXHTML
+		converter.NamePattern = epubNamePattern
+		converter.ReservedNames = nil
+	case opts.minimal:
+		converter.Layout = txt2html.LayoutMinimal
+	default:
+		converter.Template = opts.template
+		if opts.copyAssets {
+			converter.SharedAssets = true
+			converter.ReservedNames = append(converter.ReservedNames, txt2html.ReaderStyleFile, txt2html.ReaderScriptFile)
+		}
+	}
+	// 目录页与分块文件在同一目录中，EPUB、-single 和 -preview 不生成目录页
+	if opts.format == formatHTML && !opts.single && !opts.preview {
+		converter.IndexPage = indexFileName
+	}
+	if opts.single || opts.preview {
+		converter.ReservedNames = nil
+	} else if opts.format == formatHTML && !opts.noSearch {
+		converter.SearchPage = searchPageFileName
+		converter.SearchIndex = true
+		converter.ReservedNames = append(converter.ReservedNames, searchIndexFileName, searchPageFileName)
+	}
+	if state != nil {
+		converter.Resume = txt2html.Resume{
+			Chunks:   state.files(),
+			Lines:    state.Lines,
+			Headings: len(state.Headings),
+		}
+		// 新的索引由已有的索引加上新增内容组成，生成时会覆盖原文件，所以先读入内存
+		if converter.SearchIndex {
+			if old, err := os.ReadFile(filepath.Join(outputDir, searchIndexFileName)); err == nil {
+				converter.Resume.SearchIndex = bytes.NewReader(old)
+			}
+		}
 	}
-	inputFile.Seek(0, 0) // 重置文件指针
 
-	// 估算总块数
-	estimatedTotalChunks := (totalLines * 100) / 30000 // 估算值，实际会动态调整
-	if estimatedTotalChunks < 1 {
-		estimatedTotalChunks = 1
+	// 单遍读取内容并按HTML大小分割，每块写满后立即暂存到磁盘，
+	// 切分结束时即得到准确的总块数
+	var book *txt2html.Book
+	var err error
+	if opts.balance {
+		// 第一遍读取时显示进度，之后各遍从文件开头重新读取
+		passes := 0
+		book, err = converter.SplitBalanced(func() (io.Reader, error) {
+			passes++
+			if passes == 1 {
+				return input, nil
+			}
+			if passes == 2 {
+				if progress != nil {
+					progress.finish()
+					progress = nil
+				}
+				slog.Info("均衡分块: 正在重新切分以找到合适的大小")
+			}
+			if document != nil {
+				return bytes.NewReader(document.text), nil
+			}
+			if _, err := inputFile.Seek(0, io.SeekStart); err != nil {
+				return nil, err
+			}
+			if compressed {
+				return gzip.NewReader(inputFile)
+			}
+			return inputFile, nil
+		})
+	} else {
+		book, err = converter.Split(input)
+	}
+	if progress != nil {
+		progress.finish()
+	}
+	rep.InputBytes = inputCounter.n
+	if err != nil {
+		if errors.Is(err, bufio.ErrTooLong) {
+			return fmt.Errorf("分割文件失败: %w（请使用 -max-line-mb 调大上限）", err)
+		}
+		if errors.Is(err, txt2html.ErrTargetTooSmall) {
+			return fmt.Errorf("%w；请调大 -size，或使用模板小得多的 -minimal 页面", err)
+		}
+		if errors.Is(err, txt2html.ErrEmpty) {
+			return fmt.Errorf("%w，没有可转换的内容", err)
+		}
+		if errors.Is(err, txt2html.ErrBinary) {
+			return fmt.Errorf("%w；如果是其他编码的文本请指定编码（如 gbk 或 auto），确认无误时可使用 -force 跳过检查", err)
+		}
+		return fmt.Errorf("分割文件失败: %w", err)
 	}
+	defer book.Close()
+	if encodingName == txt2html.AutoEncoding {
+		slog.Info(fmt.Sprintf("检测到编码: %s", book.Encoding))
+	}
+	rep.Encoding = book.Encoding
+	rep.Chunks = len(book.Chunks)
+	rep.DecodeErrors = book.DecodeErrors
+	rep.ControlChars = book.ControlChars
+	rep.Chapters = len(book.Headings)
 
-	baseHTMLSize := getBaseHTMLSize(filepath.Base(inputFilePath), estimatedTotalChunks, 1)
-	remainingSize := targetHTMLSize - baseHTMLSize
-	if remainingSize < 0 {
-		remainingSize = 1024 // 确保至少能容纳一些内容
+	if opts.dryRun {
+		targetSize := converter.TargetSize
+		if opts.linesPerChunk > 0 {
+			targetSize = 0
+		}
+		if err := printPlan(os.Stdout, book, targetSize); err != nil {
+			return err
+		}
+		reportInputProblems(book, opts.sanitize)
+		return nil
 	}
 
-	var currentContent string
-	var chunkNumber int = 1
-	var allChunks []string
+	// 切分成功后才删除旧的输出目录（确保生成新文件），-no-clean 或追加时保留已有内容。
+	// 输入有问题时不留下只有来源记录的空目录
+	if opts.format == formatEPUB || opts.single || opts.preview {
+		// EPUB、合并的页面和预览页面只生成一个文件，只需确保所在目录存在
+		if err := os.MkdirAll(filepath.Dir(outputDir), 0755); err != nil {
+			return fmt.Errorf("无法创建输出目录 %s: %w", filepath.Dir(outputDir), err)
+		}
+	} else {
+		// 只有本次新建或清空的目录才记为本程序所有，-no-clean 写入的已有目录保持原样
+		source := sourceID(opts)
+		_, statErr := os.Stat(outputDir)
+		owned := os.IsNotExist(statErr)
+		if !opts.noClean && state == nil {
+			if err := cleanOutputDir(outputDir, source, opts.force); err != nil {
+				return err
+			}
+			owned = true
+		}
+		if err := os.MkdirAll(outputDir, 0755); err != nil {
+			return fmt.Errorf("无法创建输出目录 %s: %w", outputDir, err)
+		}
+		if owned {
+			if err := writeOutputMarker(outputDir, source); err != nil {
+				return fmt.Errorf("无法写入 %s: %w", filepath.Join(outputDir, outputMarkerFileName), err)
+			}
+		}
+		// 保留的旧进度与本次结果无关，留着会让之后的 -append 接错位置
+		if !opts.appendMode {
+			os.Remove(filepath.Join(outputDir, appendStateFileName))
+		}
+	}
 
-	// 读取内容并按HTML大小分割
-	for scanner.Scan() {
-		line := scanner.Text() + "\n"
-		escapedLine := template.HTMLEscapeString(line)
-		lineSize := len(escapedLine)
+	chunkData := book.Chunks
+	actualTotalChunks := len(chunkData)
+	// 只生成一个文件的格式，报告中记录该文件的大小
+	if opts.format == formatEPUB || opts.single || opts.preview {
+		var err error
+		switch {
+		case opts.format == formatEPUB:
+			err = convertEPUB(outputDir, book)
+		case opts.single:
+			err = convertSingle(outputDir, book, opts.noClean && !opts.force)
+		default:
+			err = convertPreview(outputDir, book, opts.noClean && !opts.force, opts.open)
+		}
+		if err == nil {
+			rep.OutputBytes = getFileSize(outputDir)
+			reportInputProblems(book, opts.sanitize)
+		}
+		return err
+	}
 
-		// 如果添加当前行会超过目标大小，则生成新文件
-		if len(currentContent)+lineSize > remainingSize {
-			allChunks = append(allChunks, currentContent)
-			currentContent = escapedLine
-			chunkNumber++
-			remainingSize = targetHTMLSize - getBaseHTMLSize(filepath.Base(inputFilePath), estimatedTotalChunks, chunkNumber)
-			if remainingSize < 0 {
-				remainingSize = 1024
+	// 保留已有内容时，拒绝覆盖同名的分块文件，除非指定了 -force。
+	// 追加时目录页等文件本来就要重新生成，只检查新的分块
+	if (opts.noClean || state != nil) && !opts.force {
+		var fileNames []string
+		if state == nil {
+			fileNames = append(fileNames, converter.ReservedNames...)
+		}
+		for _, data := range chunkData {
+			fileNames = append(fileNames, data.OutputFile)
+		}
+		if opts.gzip {
+			for _, name := range fileNames {
+				fileNames = append(fileNames, name+gzipSuffix)
 			}
-		} else {
-			currentContent += escapedLine
 		}
+		if err := checkOverwrite(outputDir, fileNames); err != nil {
+			return err
+		}
+	}
+
+	var sums *checksums
+	if opts.checksum {
+		sums = newChecksums()
+	}
+	sizes, err := renderChunks(book, outputDir, opts.jobs, opts.gzip, sums)
+	rep.OutputBytes = sizes.total()
+	if err != nil {
+		return err
+	}
+	if opts.verify {
+		verifySizes(outputDir, book.Chunks, converter.TargetSize)
+	}
+
+	if converter.SharedAssets {
+		if err := txt2html.WriteReaderAssets(outputDir, opts.minify); err != nil {
+			return fmt.Errorf("写入 %s、%s 失败: %w", txt2html.ReaderStyleFile, txt2html.ReaderScriptFile, err)
+		}
+		slog.Info(fmt.Sprintf("已生成: %s、%s", filepath.Join(outputDir, txt2html.ReaderStyleFile), filepath.Join(outputDir, txt2html.ReaderScriptFile)))
 	}
 
-	// 添加最后一块内容
-	if currentContent != "" {
-		allChunks = append(allChunks, currentContent)
+	// 目录页和清单覆盖全书，追加时包括已有的块
+	stats := book.Stats
+	headings := book.Headings
+	if opts.appendMode {
+		if state == nil {
+			state = &appendState{}
+		}
+		state.add(book, inputSize, sizes)
+		chunkData, stats, sizes = state.allChunks(book, outputDir, sizes)
+		headings = state.Headings
 	}
 
-	// 修正总块数
-	actualTotalChunks := len(allChunks)
+	// 生成目录页，链接所有分块文件
+	indexPath := filepath.Join(outputDir, indexFileName)
+	if err := generateIndex(outputDir, chunkData, sizes.html, headings); err != nil {
+		return fmt.Errorf("生成 %s 失败: %w", indexPath, err)
+	}
+	slog.Info(fmt.Sprintf("已生成: %s", indexPath))
 
-	// 生成所有HTML文件
-	for i, content := range allChunks {
-		fileName := fmt.Sprintf("%s_chunk_%d.html",
-			filepath.Base(inputFilePath[:len(inputFilePath)-len(filepath.Ext(inputFilePath))]),
-			i+1)
-		outputPath := filepath.Join(outputDir, fileName)
+	// 生成机器可读的分块清单
+	manifestPath := filepath.Join(outputDir, manifestFileName)
+	if err := generateManifest(outputDir, chunkData, stats, sizes); err != nil {
+		return fmt.Errorf("生成 %s 失败: %w", manifestPath, err)
+	}
+	slog.Info(fmt.Sprintf("已生成: %s", manifestPath))
 
-		data := TemplateData{
-			Content:      content,
-			FileName:     filepath.Base(inputFilePath),
-			TotalChunks:  actualTotalChunks,
-			CurrentChunk: i + 1,
+	// 生成全书搜索索引和搜索页面
+	if converter.SearchIndex {
+		searchIndexPath := filepath.Join(outputDir, searchIndexFileName)
+		if err := generateSearchIndex(outputDir, book); err != nil {
+			return fmt.Errorf("生成 %s 失败: %w", searchIndexPath, err)
+		}
+		slog.Info(fmt.Sprintf("已生成: %s (约 %.2f KB)", searchIndexPath, float64(getFileSize(searchIndexPath))/1024))
+		searchPagePath := filepath.Join(outputDir, searchPageFileName)
+		if err := generateSearchPage(outputDir, book.Chunks[0]); err != nil {
+			return fmt.Errorf("生成 %s 失败: %w", searchPagePath, err)
 		}
+		slog.Info(fmt.Sprintf("已生成: %s", searchPagePath))
+	}
 
-		generateHTML(outputPath, data)
-		fmt.Printf("已生成: %s (约 %.2f KB)\n", outputPath, float64(getFileSize(outputPath))/1024)
+	// 分块已在渲染时压缩，这里压缩其余输出文件并汇总压缩率
+	if opts.gzip {
+		for _, name := range converter.ReservedNames {
+			if _, err := gzipFile(filepath.Join(outputDir, name)); err != nil {
+				return fmt.Errorf("压缩 %s 失败: %w", name, err)
+			}
+		}
+		original, compressed := gzipTotals(outputDir, converter.ReservedNames)
+		for i := range chunkData {
+			original += sizes.html[i]
+			compressed += sizes.gzip[i]
+		}
+		ratio := 0.0
+		if original > 0 {
+			ratio = float64(compressed) / float64(original) * 100
+		}
+		slog.Info(fmt.Sprintf("已压缩: %d 个文件，%.2f KB → %.2f KB（压缩后为原大小的 %.1f%%）",
+			len(converter.ReservedNames)+len(chunkData), float64(original)/1024, float64(compressed)/1024, ratio))
 	}
 
-	fmt.Printf("处理完成! 共生成 %d 个文件，保存到 %s\n", actualTotalChunks, outputDir)
+	// 校验清单最后生成，列出此前写入的所有输出文件，打包时一并放入。
+	// 本次渲染的分块已在写入时计算，其余文件（包括追加时已有的分块）读回计算
+	if sums != nil {
+		files := append([]string{}, converter.ReservedNames...)
+		for _, data := range chunkData[:len(chunkData)-len(book.Chunks)] {
+			files = append(files, data.OutputFile)
+		}
+		if opts.gzip {
+			for _, name := range files {
+				files = append(files, name+gzipSuffix)
+			}
+			for _, data := range book.Chunks {
+				files = append(files, data.OutputFile+gzipSuffix)
+			}
+		}
+		for _, name := range files {
+			if err := sums.addFile(outputDir, name); err != nil {
+				return fmt.Errorf("计算 %s 的校验和失败: %w", name, err)
+			}
+		}
+		checksumPath := filepath.Join(outputDir, checksumFileName)
+		if err := sums.write(outputDir); err != nil {
+			return fmt.Errorf("生成 %s 失败: %w", checksumPath, err)
+		}
+		slog.Info(fmt.Sprintf("已生成: %s", checksumPath))
+	}
+
+	// 最后才保存进度，中途失败时下次仍从上次的位置重新追加
+	if opts.appendMode {
+		if err := state.save(outputDir); err != nil {
+			return fmt.Errorf("保存 %s 失败: %w", appendStateFileName, err)
+		}
+	}
+
+	savedTo := outputDir
+	if opts.zip {
+		zipPath := filepath.Clean(outputDir) + ".zip"
+		if err := zipDir(outputDir, zipPath); err != nil {
+			return fmt.Errorf("打包 %s 失败: %w", zipPath, err)
+		}
+		slog.Info(fmt.Sprintf("已打包: %s (约 %.2f KB)", zipPath, float64(getFileSize(zipPath))/1024))
+		if opts.zipOnly {
+			if err := os.RemoveAll(outputDir); err != nil {
+				return fmt.Errorf("无法删除输出目录 %s: %w", outputDir, err)
+			}
+			savedTo = zipPath
+		}
+	}
+	rep.Output = savedTo
+
+	slog.Info(fmt.Sprintf("处理完成! 共生成 %d 个文件（约 %.2f KB），保存到 %s", actualTotalChunks, float64(rep.OutputBytes)/1024, savedTo))
+	reportInputProblems(book, opts.sanitize)
+	return nil
 }
 
-func getEncodingDecoder(encodingName string) encoding.Encoding {
-	switch encodingName {
-	case "utf-8", "utf8":
-		return unicode.UTF8
-	case "utf-16", "utf16":
-		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
-	case "utf-16be":
-		return unicode.UTF16(unicode.BigEndian, unicode.IgnoreBOM)
-	case "utf-16le":
-		return unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM)
-	case "gbk", "ansi":
-		return simplifiedchinese.GBK
+// 输入中有无法解码的内容时提示数量，帮助判断是否选错了编码；同时报告控制字符的数量
+func reportInputProblems(book *txt2html.Book, sanitize bool) {
+	if book.DecodeErrors > 0 {
+		slog.Warn(fmt.Sprintf("检测到 %d 处解码错误（按 %s 解码），数量较多时可能选错了编码，可尝试其他编码或 auto", book.DecodeErrors, book.Encoding))
+	}
+	switch {
+	case book.ControlChars == 0:
+	case sanitize:
+		slog.Info(fmt.Sprintf("已去掉 %d 个控制字符", book.ControlChars))
 	default:
-		return nil
+		slog.Warn(fmt.Sprintf("正文中有 %d 个控制字符（如 NUL、换页符），在页面中可能显示为方框，可使用 -sanitize 去掉", book.ControlChars))
 	}
 }
 
-func generateHTML(outputPath string, data TemplateData) error {
-	outputFile, err := os.Create(outputPath)
+// 把切分结果打包为 EPUB 文件 epubPath
+func convertEPUB(epubPath string, book *txt2html.Book) error {
+	if err := writeEPUB(epubPath, book); err != nil {
+		return fmt.Errorf("生成 %s 失败: %w", epubPath, err)
+	}
+	slog.Info(fmt.Sprintf("已生成: %s (约 %.2f KB)", epubPath, float64(getFileSize(epubPath))/1024))
+	slog.Info(fmt.Sprintf("处理完成! 共 %d 个分块，保存到 %s", len(book.Chunks), epubPath))
+	return nil
+}
+
+// 把全书合并为一个页面写入 path，keep 时拒绝覆盖已存在的文件
+func convertSingle(path string, book *txt2html.Book, keep bool) error {
+	if keep {
+		if err := checkOverwrite(filepath.Dir(path), []string{filepath.Base(path)}); err != nil {
+			return err
+		}
+	}
+	outputFile, err := os.Create(path)
 	if err != nil {
-		return err
+		return fmt.Errorf("生成 %s 失败: %w", path, err)
+	}
+	w := &countingWriter{w: outputFile}
+	if err := book.RenderSingle(w, filepath.Base(path)); err != nil {
+		outputFile.Close()
+		return fmt.Errorf("生成 %s 失败: %w", path, err)
+	}
+	if err := outputFile.Close(); err != nil {
+		return fmt.Errorf("生成 %s 失败: %w", path, err)
+	}
+	slog.Info(fmt.Sprintf("处理完成! 共 %d 个部分（约 %.2f KB），保存到 %s", len(book.Chunks), float64(w.n)/1024, path))
+	return nil
+}
+
+// 使用 jobs 个 goroutine 并行渲染并写入所有分块。每块只依赖自己的正文和元数据，
+// 因此可以任意顺序完成，"已生成" 的输出顺序也不固定。等所有任务结束后再汇总报告失败的分块
+// gzip 时每块写完后立即生成预压缩副本；sums 不为 nil 时在写入的同时计算每块的校验和。
+// 返回各块写出的字节数，目录页、清单和压缩汇总直接使用，不必再逐个 Stat 分块文件
+func renderChunks(book *txt2html.Book, outputDir string, jobs int, gzip bool, sums *checksums) (chunkSizes, error) {
+	tasks := make(chan int)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var errs []error
+	sizes := chunkSizes{html: make([]int64, len(book.Chunks))}
+	if gzip {
+		sizes.gzip = make([]int64, len(book.Chunks))
+	}
+
+	for w := 0; w < jobs; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range tasks {
+				size, gzipSize, err := renderChunk(book, outputDir, book.Chunks[i], gzip, sums)
+				// 每个任务只写自己的下标，不需要加锁
+				sizes.html[i] = size
+				if gzip {
+					sizes.gzip[i] = gzipSize
+				}
+				if err != nil {
+					mu.Lock()
+					errs = append(errs, err)
+					mu.Unlock()
+				}
+			}
+		}()
+	}
+	for i := range book.Chunks {
+		tasks <- i
+	}
+	close(tasks)
+	wg.Wait()
+
+	if len(errs) > 0 {
+		return sizes, fmt.Errorf("%d 个分块生成失败:\n%w", len(errs), errors.Join(errs...))
+	}
+	return sizes, nil
+}
+
+// 各块写出的字节数，与分块一一对应；gzip 为预压缩副本的字节数，没有生成副本时为 nil
+type chunkSizes struct {
+	html []int64
+	gzip []int64
+}
+
+// 所有分块文件的总字节数
+func (s chunkSizes) total() int64 {
+	var total int64
+	for _, size := range s.html {
+		total += size
 	}
-	defer outputFile.Close()
+	return total
+}
 
-	tmpl, err := template.New("htmlTemplate").Parse(htmlTemplate)
+// 渲染一块并写入对应的HTML文件，返回写入的字节数和预压缩副本的字节数（不压缩时为0）
+func renderChunk(book *txt2html.Book, outputDir string, data txt2html.TemplateData, gzip bool, sums *checksums) (int64, int64, error) {
+	outputPath := filepath.Join(outputDir, data.OutputFile)
+	size, err := generateHTML(book, outputPath, data.CurrentChunk, sums)
 	if err != nil {
-		return err
+		return 0, 0, fmt.Errorf("生成 %s 失败: %w", outputPath, err)
 	}
+	if gzip {
+		compressed, err := gzipFile(outputPath)
+		if err != nil {
+			return size, 0, fmt.Errorf("压缩 %s 失败: %w", outputPath, err)
+		}
+		slog.Debug("已生成分块", "file", outputPath, "bytes", size, "gzipBytes", compressed)
+		return size, compressed, nil
+	}
+	slog.Debug("已生成分块", "file", outputPath, "bytes", size)
+	return size, 0, nil
+}
+
+// 渲染第 chunk 块写入 outputPath，返回写入的字节数。sums 不为 nil 时同时计算写入内容的校验和，
+// 文件成功关闭后才记入
+func generateHTML(book *txt2html.Book, outputPath string, chunk int, sums *checksums) (int64, error) {
+	outputFile, err := os.Create(outputPath)
+	if err != nil {
+		return 0, err
+	}
+	var out io.Writer = outputFile
+	done := func() {}
+	if sums != nil {
+		out, done = sums.tee(filepath.Base(outputPath), outputFile)
+	}
+	w := &countingWriter{w: out}
+	if err := book.Render(chunk, w); err != nil {
+		outputFile.Close()
+		return w.n, err
+	}
+	// 磁盘写满等错误可能要到关闭文件时才会暴露
+	if err := outputFile.Close(); err != nil {
+		return w.n, err
+	}
+	done()
+	return w.n, nil
+}
+
+// 统计写入字节数的 io.Writer，写完后不必再 Stat 文件就能知道大小
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
 
-	return tmpl.Execute(outputFile, data)
+func (c *countingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
 }
 
 func getFileSize(path string) int64 {
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// 各种失败都由 run 以 error 返回（main 据此输出到标准错误并以状态码 1 退出），不会只打印一条消息后当作成功
+func TestRunErrors(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte("第一章 开始\n内容\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	// 普通文件不能作为目录，用来制造打开和写入失败
+	blocker := filepath.Join(dir, "blocker")
+	if err := os.WriteFile(blocker, []byte("x"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	long := filepath.Join(dir, "long.txt")
+	if err := os.WriteFile(long, []byte(strings.Repeat("a", 2*1024*1024)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		args []string
+		want string // 错误消息中应包含的内容，为空时只要求返回错误
+	}{
+		{"文件不存在", []string{"-quiet", filepath.Join(dir, "missing.txt")}, "文件不存在"},
+		{"无法打开文件", []string{"-quiet", filepath.Join(blocker, "book.txt")}, "无法打开文件"},
+		{"不支持的编码", []string{"-quiet", input, "no-such-encoding"}, "不支持的编码"},
+		{"无法清理输出目录", []string{"-quiet", "-out", filepath.Join(blocker, "out"), input}, "无法清理输出目录"},
+		{"无法创建输出目录", []string{"-quiet", "-no-clean", "-out", filepath.Join(blocker, "out"), input}, "无法创建输出目录"},
+		{"单个页面无法写入", []string{"-quiet", "-single", "-out", filepath.Join(blocker, "sub", "book.html"), input}, "无法创建输出目录"},
+		{"单行过长", []string{"-quiet", "-max-line-mb", "1", "-out", filepath.Join(dir, "out"), long}, "请使用 -max-line-mb 调大上限"},
+		{"无效的参数", []string{"-quiet", "-size", "abc", input}, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := run(tt.args)
+			if err == nil {
+				t.Fatalf("run(%q) 成功，期望失败", tt.args)
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("run(%q) = %v，期望包含 %q", tt.args, err, tt.want)
+			}
+		})
+	}
+}
+
+// 输入或参数有问题导致切分失败时不创建输出目录，不会留下只有来源记录的空目录
+func TestRunSplitErrorLeavesNoOutput(t *testing.T) {
+	dir := chdirTemp(t)
+	files := map[string][]byte{
+		"book.txt":   []byte("第一章 开始\n内容\n"),
+		"binary.txt": {0, 1, 2, 3, 0, 0, 0xff, 0xfe, 0, 7},
+		"empty.txt":  []byte("\xef\xbb\xbf"), // 去掉 BOM 后为空
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name string
+		args []string
+	}{
+		{"目标大小过小", []string{"-size", "64", "book.txt"}},
+		{"二进制文件", []string{"binary.txt"}},
+		{"没有内容", []string{"empty.txt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out := filepath.Join(dir, "out")
+			args := append([]string{"-quiet", "-out", out}, tt.args...)
+			if err := run(args); err == nil {
+				t.Fatalf("run(%q) 成功，期望失败", args)
+			}
+			if _, err := os.Stat(out); !os.IsNotExist(err) {
+				t.Errorf("失败后仍创建了输出目录 %s", out)
+			}
+		})
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"path/filepath"
+
+	"txt2html/pkg/txt2html"
+)
+
+// 平均大小低于目标的这一比例时提示原因，章节分块等情况下分块本来就会偏小
+const verifyUndersizeRatio = 0.8
+
+// 分块大小的检查结果，大小都按写出的文件计算
+type sizeCheck struct {
+	Chunks    int     // 参与比较的块数，不含最后一块
+	AvgRatio  float64 // 平均大小与目标大小之比
+	MaxRatio  float64 // 最大的一块与目标大小之比
+	MaxChunk  int     // 最大的一块的序号
+	Oversized int     // 超过目标大小的块数
+	MaxOver   int64   // 超过目标大小最多的字节数
+}
+
+// 读取写出的分块文件，与目标大小比较。最后一块只装剩下的内容，不参与比较；只有一块时返回 ok 为 false
+func checkSizes(outputDir string, chunks []txt2html.TemplateData, target int) (check sizeCheck, ok bool) {
+	if len(chunks) < 2 || target <= 0 {
+		return sizeCheck{}, false
+	}
+	var sum float64
+	for _, data := range chunks[:len(chunks)-1] {
+		size := getFileSize(filepath.Join(outputDir, data.OutputFile))
+		ratio := float64(size) / float64(target)
+		sum += ratio
+		if ratio > check.MaxRatio {
+			check.MaxRatio, check.MaxChunk = ratio, data.CurrentChunk
+		}
+		if over := size - int64(target); over > 0 {
+			check.Oversized++
+			check.MaxOver = max(check.MaxOver, over)
+		}
+		check.Chunks++
+	}
+	check.AvgRatio = sum / float64(check.Chunks)
+	return check, true
+}
+
+// -verify：报告写出的分块与目标大小的偏差，超过目标时给出调整 -size 的建议
+func verifySizes(outputDir string, chunks []txt2html.TemplateData, target int) {
+	check, ok := checkSizes(outputDir, chunks, target)
+	if !ok {
+		slog.Info("大小检查: 只有一块，没有可与目标大小比较的分块")
+		return
+	}
+	slog.Info(fmt.Sprintf("大小检查: %d 块（不含最后一块）平均为目标大小 %.0f KB 的 %.1f%%，最大为 %.1f%%（第 %d 块）",
+		check.Chunks, float64(target)/1024, check.AvgRatio*100, check.MaxRatio*100, check.MaxChunk))
+	switch {
+	case check.Oversized > 0:
+		slog.Warn(fmt.Sprintf("%d 块超过目标大小，最多超出 %d 字节：页面模板大小的估算偏小，需要严格限制大小时可把 -size 调小 %d KB",
+			check.Oversized, check.MaxOver, (check.MaxOver+1023)/1024))
+	case check.AvgRatio < verifyUndersizeRatio:
+		slog.Info("多数分块明显小于目标大小，通常是章节标题从新的一块开始、或单行较长无法放入所致，不表示估算有误")
+	}
+}
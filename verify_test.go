@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"txt2html/pkg/txt2html"
+)
+
+// 按写出的文件大小与目标比较，最后一块不计
+func TestCheckSizes(t *testing.T) {
+	dir := t.TempDir()
+	var chunks []txt2html.TemplateData
+	for i, size := range []int{900, 1100, 1000, 10} {
+		name := fmt.Sprintf("c%d.html", i+1)
+		if err := os.WriteFile(filepath.Join(dir, name), make([]byte, size), 0644); err != nil {
+			t.Fatal(err)
+		}
+		chunks = append(chunks, txt2html.TemplateData{CurrentChunk: i + 1, OutputFile: name})
+	}
+	check, ok := checkSizes(dir, chunks, 1000)
+	if !ok {
+		t.Fatal("checkSizes 没有结果")
+	}
+	if check.Chunks != 3 || math.Abs(check.AvgRatio-1) > 1e-9 || math.Abs(check.MaxRatio-1.1) > 1e-9 || check.MaxChunk != 2 ||
+		check.Oversized != 1 || check.MaxOver != 100 {
+		t.Errorf("checkSizes = %+v", check)
+	}
+	if _, ok := checkSizes(dir, chunks[:1], 1000); ok {
+		t.Error("只有一块时也得到了结果")
+	}
+}
+
+// -verify 在转换后报告大小，实际生成的分块不超过目标大小
+func TestConvertVerify(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "book.txt")
+	if err := os.WriteFile(input, []byte(strings.Repeat("一行测试文本，用来填满分块。\n", 20000)), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := run([]string{"-quiet", "-verify", "-size", "128", "-out", "out", input}); err != nil {
+		t.Fatalf("转换: %v", err)
+	}
+	paths, err := filepath.Glob(filepath.Join(dir, "out", "book_chunk_*.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) < 2 {
+		t.Fatalf("只生成了 %d 块", len(paths))
+	}
+	for _, path := range paths {
+		if size := getFileSize(path); size > 128*1024 {
+			t.Errorf("%s 为 %d 字节，超过目标大小", filepath.Base(path), size)
+		}
+	}
+	if err := run([]string{"-quiet", "-verify", "-lines", "10", "-out", "lines", input}); err == nil {
+		t.Error("-verify 与 -lines 同时使用时没有报错")
+	}
+}
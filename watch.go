@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"os"
+	"time"
+)
+
+const watchInterval = 500 * time.Millisecond // -watch 检查输入文件的间隔
+
+// 输入文件的修改时间和大小，任一变化都视为文件已修改
+type fileState struct {
+	modTime time.Time
+	size    int64
+}
+
+func statFile(path string) (fileState, bool, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileState{}, false, err
+	}
+	return fileState{info.ModTime(), info.Size()}, info.IsDir(), nil
+}
+
+// 先转换一次，之后每隔 interval 检查输入文件，修改后重新转换，直到 ctx 结束。
+// 编辑器保存时可能分几次写入，文件连续两次检查都没有变化才开始转换；
+// 保存时先删除再改名的文件短暂不存在，这时等待下一次检查。重新转换失败只记录错误，继续等待下一次修改
+func watchInput(ctx context.Context, opts *options, interval time.Duration) error {
+	last, isDir, err := statFile(opts.inputPath)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("文件不存在 - %s", opts.inputPath)
+	}
+	if err != nil {
+		return err
+	}
+	if isDir {
+		return fmt.Errorf("-watch 只用于单个文件，不能用于目录输入")
+	}
+	if err := convertInput(opts); err != nil {
+		return err
+	}
+	// 只在第一次转换后打开浏览器
+	opts.open = false
+	slog.Info(fmt.Sprintf("[%s] 正在监视 %s，修改后自动重新转换，按 Ctrl+C 退出", time.Now().Format(time.TimeOnly), opts.inputPath))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	pending := false // 已发现修改，等待文件不再变化
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+		state, _, err := statFile(opts.inputPath)
+		if err != nil {
+			continue
+		}
+		if state != last {
+			last = state
+			pending = true
+			continue
+		}
+		if !pending {
+			continue
+		}
+		pending = false
+		start := time.Now()
+		if err := convertInput(opts); err != nil {
+			slog.Error(fmt.Sprintf("[%s] 重新转换失败: %v", time.Now().Format(time.TimeOnly), err))
+			continue
+		}
+		slog.Info(fmt.Sprintf("[%s] 已重新生成，用时 %.1f 秒", time.Now().Format(time.TimeOnly), time.Since(start).Seconds()))
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// -watch 时输入文件修改后自动重新转换，ctx 结束后正常返回
+func TestWatchInput(t *testing.T) {
+	dir := chdirTemp(t)
+	input := filepath.Join(dir, "a.txt")
+	if err := os.WriteFile(input, []byte("第一版\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	opts, err := parseOptions([]string{"-quiet", "-watch", "-out", "out", input})
+	if err != nil {
+		t.Fatalf("parseOptions: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() {
+		done <- watchInput(ctx, opts, 10*time.Millisecond)
+	}()
+	page := filepath.Join(dir, "out", "a_chunk_1.html")
+	waitFor := func(text string) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for time.Now().Before(deadline) {
+			if content, err := os.ReadFile(page); err == nil && strings.Contains(string(content), text) {
+				return
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+		t.Fatalf("等待 %q 超时", text)
+	}
+	waitFor("第一版")
+	if err := os.WriteFile(input, []byte("第二版，内容更长\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	waitFor("第二版")
+
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("watchInput: %v", err)
+	}
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// 本程序在输出目录中记录来源和进度的文件，只供再次转换时使用，不放入压缩包
+var toolStateFiles = map[string]bool{
+	outputMarkerFileName: true,
+	appendStateFileName:  true,
+	batchStateFileName:   true,
+}
+
+// 将目录 dir 压缩为 zipPath。压缩包内以目录名作为顶层文件夹并保留相对路径，
+// 解压后目录页与分块之间的相对链接依然有效。各级目录中的 toolStateFiles 不打包
+func zipDir(dir, zipPath string) error {
+	zipFile, err := os.Create(zipPath)
+	if err != nil {
+		return err
+	}
+
+	zw := zip.NewWriter(zipFile)
+	root := filepath.Base(filepath.Clean(dir))
+	walkErr := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || toolStateFiles[d.Name()] {
+			return nil
+		}
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		return addFileToZip(zw, path, filepath.ToSlash(filepath.Join(root, rel)))
+	})
+	if walkErr != nil {
+		zw.Close()
+		zipFile.Close()
+		return walkErr
+	}
+
+	if err := zw.Close(); err != nil {
+		zipFile.Close()
+		return err
+	}
+	return zipFile.Close()
+}
+
+// 将单个文件以 name 为路径写入压缩包
+func addFileToZip(zw *zip.Writer, path, name string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return err
+	}
+	header.Name = name
+	header.Method = zip.Deflate
+
+	w, err := zw.CreateHeader(header)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(w, file)
+	return err
+}
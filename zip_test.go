@@ -0,0 +1,49 @@
+package main
+
+import (
+	"archive/zip"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// 压缩包中只有生成的页面，记录来源和进度的文件不打包
+func TestZipDirSkipsToolStateFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "book")
+	files := []string{
+		"index.html",
+		outputMarkerFileName,
+		appendStateFileName,
+		batchStateFileName,
+		filepath.Join("sub", "a_chunk_1.html"),
+		filepath.Join("sub", outputMarkerFileName),
+	}
+	for _, name := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte("x"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	zipPath := dir + ".zip"
+	if err := zipDir(dir, zipPath); err != nil {
+		t.Fatalf("zipDir: %v", err)
+	}
+
+	zr, err := zip.OpenReader(zipPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	var names []string
+	for _, f := range zr.File {
+		names = append(names, f.Name)
+	}
+	slices.Sort(names)
+	if want := []string{"book/index.html", "book/sub/a_chunk_1.html"}; !slices.Equal(names, want) {
+		t.Errorf("压缩包中的文件 = %q，期望 %q", names, want)
+	}
+}